@@ -1,32 +1,39 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"runtime"
+	"strconv"
+	"strings"
 
 	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
 
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
 	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/hdkey"
+	"github.com/meshplus/goduck/internal/pki"
+	"github.com/meshplus/goduck/internal/ports"
 	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/supervisor"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
 	"github.com/urfave/cli/v2"
 )
 
-type Release struct {
-	Bitxhub []string `json:"bitxhub"`
-	Pier    []string `json:"pier"`
+var consensusFlag = &cli.StringFlag{
+	Name:  "consensus",
+	Usage: "Specify the consensus plugin of nodes, e.g. solo, raft or rbft (default: consensus_type of the modify config)",
 }
 
-var bxhConfigMap = map[string]string{
-	"v1.6.1": "v1.6.1",
-	"v1.7.0": "v1.7.0",
-	"v1.8.0": "v1.8.0",
-	"v1.9.0": "v1.8.0",
+var stopTimeoutFlag = &cli.DurationFlag{
+	Name:  "timeout",
+	Value: supervisor.DefaultStopTimeout,
+	Usage: "How long to wait for processes or containers to exit before killing them",
 }
 
 func bitxhubCMD() *cli.Command {
@@ -37,7 +44,7 @@ func bitxhubCMD() *cli.Command {
 			{
 				Name:  "start",
 				Usage: "Start BitXHub nodes",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "type",
 						Value: types.TypeBinary,
@@ -56,23 +63,43 @@ func bitxhubCMD() *cli.Command {
 						Value:   "v1.6.1",
 						Usage:   "BitXHub version",
 					},
-				},
+					&cli.StringFlag{
+						Name:  "subnet",
+						Value: bitxhub.DefaultSubnet,
+						Usage: "Specify the subnet of the docker network for nodes in cluster mode, only useful for docker",
+					},
+					consensusFlag,
+				}, genesisFlags...),
 				Action: startBitXHub,
 			},
 			{
-				Name:   "stop",
-				Usage:  "Stop BitXHub nodes",
+				Name:  "stop",
+				Usage: "Stop BitXHub nodes",
+				Flags: []cli.Flag{
+					stopTimeoutFlag,
+				},
 				Action: stopBitXHub,
 			},
 			{
-				Name:   "clean",
-				Usage:  "Clean BitXHub nodes",
+				Name:  "restart",
+				Usage: "Restart BitXHub nodes started in binary",
+				Flags: []cli.Flag{
+					stopTimeoutFlag,
+				},
+				Action: restartBitXHub,
+			},
+			{
+				Name:  "clean",
+				Usage: "Clean BitXHub nodes",
+				Flags: []cli.Flag{
+					stopTimeoutFlag,
+				},
 				Action: cleanBitXHub,
 			},
 			{
 				Name:  "config",
 				Usage: "Generate configuration for BitXHub nodes",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:  "target",
 						Usage: "Specify the directory to where to put the generated configuration files, default: $repo/bitxhub/.bitxhub/",
@@ -86,9 +113,15 @@ func bitxhubCMD() *cli.Command {
 						Value:   "v1.6.1",
 						Usage:   "BitXHub version",
 					},
-				},
+					consensusFlag,
+					&cli.StringFlag{
+						Name:  "seed",
+						Usage: "Derive node, account, CA and agency keys from the seed, so that the same seed and config always give the same pids, accounts, genesis admins and CA",
+					},
+				}, genesisFlags...),
 				Action: generateBitXHubConfig,
 			},
+			bitxhubNodeCMD(),
 		},
 	}
 }
@@ -101,10 +134,25 @@ func stopBitXHub(ctx *cli.Context) error {
 	if !fileutil.Exist(filepath.Join(repoPath, types.PlaygroundScript)) {
 		return fmt.Errorf("please `goduck init` first")
 	}
-	args := make([]string, 0)
-	args = append(args, filepath.Join(repoPath, types.PlaygroundScript), "down")
 
-	return utils.ExecuteShell(args, repoPath)
+	if _, err := bitxhub.StopBinaryNodes(repoPath, ctx.Duration("timeout")); err != nil {
+		return fmt.Errorf("stop binary nodes error:%w", err)
+	}
+
+	if err := bitxhub.StopDockerNodes(repoPath, ctx.Duration("timeout")); err != nil {
+		return fmt.Errorf("stop docker nodes error:%w", err)
+	}
+
+	return nil
+}
+
+func restartBitXHub(ctx *cli.Context) error {
+	repoPath, err := repo.PathRoot()
+	if err != nil {
+		return fmt.Errorf("parse repo path error:%w", err)
+	}
+
+	return bitxhub.RestartBinaryNodes(repoPath, ctx.Duration("timeout"))
 }
 
 func startBitXHub(ctx *cli.Context) error {
@@ -112,6 +160,7 @@ func startBitXHub(ctx *cli.Context) error {
 	configPath := ctx.String("configPath")
 	target := ctx.String("target")
 	version := ctx.String("version")
+	subnet := ctx.String("subnet")
 
 	repoPath, err := repo.PathRoot()
 	if err != nil {
@@ -121,48 +170,73 @@ func startBitXHub(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoPath, "release.json"))
+	release, err := versions.LookupBitXHub(version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
-	}
-
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport BitXHub verison")
-	}
-
 	if configPath == "" {
-		configPath = filepath.Join(repoPath, fmt.Sprintf("bxh_config/%s/%s", bxhConfigMap[version], types.BxhModifyConfig))
+		configPath = filepath.Join(repoPath, fmt.Sprintf("bxh_config/%s/%s", release.ConfigDir, types.BxhModifyConfig))
 	}
 
 	if target == "" {
 		target = filepath.Join(repoPath, fmt.Sprintf("bitxhub/.bitxhub"))
 	}
 
-	if typ == types.TypeBinary {
-		err := bitxhub.DownloadBitxhubBinary(repoPath, version)
-		if err != nil {
-			return fmt.Errorf("download binary error:%w", err)
-		}
+	if typ != types.TypeBinary && typ != types.TypeDocker {
+		return fmt.Errorf("unsupported type %s, should be binary or docker", typ)
 	}
 
-	args := make([]string, 0)
-	args = append(args, filepath.Join(repoPath, types.PlaygroundScript), "up")
-	args = append(args, version, typ, configPath, target)
-	return utils.ExecuteShell(args, repoPath)
+	genesis, err := genesisSpecFromFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	return startNodes(repoPath, target, configPath, version, typ, subnet, ctx.String("consensus"), genesis)
 }
 
-func AdjustVersion(version string, release []string) bool {
-	for _, bxhRelease := range release {
-		if version == bxhRelease {
-			return true
+// startNodes regenerates configuration of BitXHub nodes if needed and starts them in typ
+func startNodes(repoPath, target, configPath, version, typ, subnet, consensus string, genesis *GenesisSpec) error {
+	err := bitxhub.DownloadBitxhubBinary(repoPath, version)
+	if err != nil {
+		return fmt.Errorf("download binary error:%w", err)
+	}
+
+	if err := bitxhub.PrepareBitxhubBinary(repoPath, version); err != nil {
+		return err
+	}
+
+	mode, num, err := readNodeLayout(configPath)
+	if err != nil {
+		return err
+	}
+	rewrite, err := utils.GetModifyConfigValue(configPath, "rewrite")
+	if err != nil {
+		return err
+	}
+
+	nodeRepo := filepath.Join(target, bitxhub.NodeNames(mode, num)[0])
+	if fileutil.Exist(nodeRepo) {
+		color.Blue("BitXHub %s configuration file already exists", mode)
+		color.Blue("reinitializing would overwrite your configuration? (%s)", rewrite)
+	}
+
+	if !fileutil.Exist(nodeRepo) || rewrite == "true" {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if err := configBitXHub(repoPath, target, configPath, version, consensus, genesis, ""); err != nil {
+			return err
 		}
+	} else if !genesis.IsDefault() || consensus != "" {
+		color.Yellow("the existing configuration is used, consensus and genesis options are ignored")
+	}
+
+	if typ == types.TypeDocker {
+		return bitxhub.StartDockerNodes(repoPath, target, version, mode, configPath, subnet, num)
 	}
-	return false
+
+	return bitxhub.StartBinaryNodes(repoPath, target, version, mode, num)
 }
 
 func cleanBitXHub(ctx *cli.Context) error {
@@ -173,6 +247,19 @@ func cleanBitXHub(ctx *cli.Context) error {
 	if !fileutil.Exist(filepath.Join(repoPath, types.PlaygroundScript)) {
 		return fmt.Errorf("please `goduck init` first")
 	}
+
+	if _, err := bitxhub.StopBinaryNodes(repoPath, ctx.Duration("timeout")); err != nil {
+		return fmt.Errorf("stop binary nodes error:%w", err)
+	}
+
+	if err := bitxhub.StopDockerNodes(repoPath, ctx.Duration("timeout")); err != nil {
+		return fmt.Errorf("stop docker nodes error:%w", err)
+	}
+
+	if err := bitxhub.CleanDockerNodes(repoPath); err != nil {
+		return fmt.Errorf("clean docker nodes error:%w", err)
+	}
+
 	args := make([]string, 0)
 	args = append(args, filepath.Join(repoPath, types.PlaygroundScript), "clean")
 	return utils.ExecuteShell(args, repoPath)
@@ -191,22 +278,58 @@ func generateBitXHubConfig(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoPath, "release.json"))
+	release, err := versions.LookupBitXHub(version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	if target == "" {
+		target = filepath.Join(repoPath, fmt.Sprintf("bitxhub/.bitxhub"))
+	}
+
+	if configPath == "" {
+		configPath = filepath.Join(repoPath, fmt.Sprintf("bxh_config/%s/%s", release.ConfigDir, types.BxhModifyConfig))
+	}
+
+	genesis, err := genesisSpecFromFlags(ctx)
+	if err != nil {
 		return err
 	}
 
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport BitXHub verison")
+	return configBitXHub(repoPath, target, configPath, version, ctx.String("consensus"), genesis, ctx.String("seed"))
+}
+
+// configBitXHub generates configuration of BitXHub nodes into target by the modify config,
+// consensus overrides consensus_type of the modify config if it is not empty,
+// node, account, CA and agency keys are derived from seed if it is not empty
+func configBitXHub(repoPath, target, configPath, version, consensus string, genesis *GenesisSpec, seed string) error {
+	release, err := versions.LookupBitXHub(version)
+	if err != nil {
+		return err
 	}
 
-	if target == "" {
-		target = filepath.Join(repoPath, fmt.Sprintf("bitxhub/.bitxhub"))
+	mode, num, err := readNodeLayout(configPath)
+	if err != nil {
+		return err
+	}
+	if consensus == "" {
+		if consensus, err = utils.GetModifyConfigValue(configPath, "consensus_type"); err != nil {
+			return err
+		}
+	} else {
+		f, err := ioutil.TempFile("", "bxh_modify_config_*.toml")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		if err := utils.SetModifyConfigValue(configPath, f.Name(), "consensus_type", consensus); err != nil {
+			return err
+		}
+		configPath = f.Name()
+	}
+	if err := checkConsensus(release, mode, consensus, num); err != nil {
+		return err
 	}
 
 	if _, err := os.Stat(target); os.IsNotExist(err) {
@@ -215,10 +338,6 @@ func generateBitXHubConfig(ctx *cli.Context) error {
 		}
 	}
 
-	if configPath == "" {
-		configPath = filepath.Join(repoPath, fmt.Sprintf("bxh_config/%s/%s", bxhConfigMap[version], types.BxhModifyConfig))
-	}
-
 	err = bitxhub.DownloadBitxhubBinary(repoPath, version)
 	if err != nil {
 		return fmt.Errorf("download binary error:%w", err)
@@ -227,11 +346,177 @@ func generateBitXHubConfig(ctx *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("extract binary error:%w", err)
 	}
-	binPath := filepath.Join(repoPath, fmt.Sprintf("bin/%s", fmt.Sprintf("bitxhub_%s_%s", runtime.GOOS, version)))
+	binPath := bitxhub.BinaryPath(repoPath, version)
 	fmt.Println(binPath)
 
 	args := make([]string, 0)
-	args = append(args, filepath.Join(repoPath, types.BxhConfigRepo, bxhConfigMap[version], types.BitxhubConfigScript))
+	args = append(args, filepath.Join(repoPath, types.BxhConfigRepo, release.ConfigDir, types.BitxhubConfigScript))
 	args = append(args, "-t", target, "-b", binPath, "-p", configPath)
-	return utils.ExecuteShell(args, repoPath)
+	if err := utils.ExecuteShell(args, repoPath); err != nil {
+		return err
+	}
+
+	// the script only copies solo and raft plugins
+	for _, name := range bitxhub.NodeNames(mode, num) {
+		if err := bitxhub.CopyConsensusPlugin(binPath, filepath.Join(target, name), consensus); err != nil {
+			return err
+		}
+	}
+
+	if keys := hdkey.New(seed).Child(types.BitXHub); keys != nil {
+		ca, err := seedCA(target, keys)
+		if err != nil {
+			return fmt.Errorf("derive CA: %w", err)
+		}
+		if err := rekeyNodes(target, bitxhub.NodeNames(mode, num), ca, keys); err != nil {
+			return fmt.Errorf("derive node keys: %w", err)
+		}
+	}
+
+	if err := reserveNodePorts(repoPath, target, bitxhub.NodeNames(mode, num)); err != nil {
+		return err
+	}
+
+	if genesis.IsDefault() {
+		return nil
+	}
+
+	return applyGenesis(repoPath, target, bitxhub.NodeNames(mode, num), genesis)
+}
+
+// reserveNodePorts records the ports the config script took from the modify config in the port manifest,
+// after checking that no other node is assigned them and that they are free on this machine
+func reserveNodePorts(repoPath, target string, names []string) error {
+	manifest, err := ports.Load(ports.ManifestPath(repoPath))
+	if err != nil {
+		return err
+	}
+	manifest.Release(target)
+
+	allocator := ports.NewAllocator(manifest)
+	for _, name := range names {
+		p, err := configuredPorts(filepath.Join(target, name))
+		if err != nil {
+			return err
+		}
+		if err := allocator.Reserve(p, ports.IsLocal(p.Host)); err != nil {
+			return fmt.Errorf("ports of %s: %w, change them in the modify config", name, err)
+		}
+	}
+
+	if err := manifest.Save(ports.ManifestPath(repoPath)); err != nil {
+		return fmt.Errorf("save port manifest: %w", err)
+	}
+
+	return nil
+}
+
+// seedCA creates the CA and the agency in target with keys derived by keys, as the config generator of
+// `goduck up` does, in place of the CA a previous config left there
+func seedCA(target string, keys *hdkey.Deriver) (*pki.Authority, error) {
+	old, err := filepath.Glob(filepath.Join(target, "*.crl"))
+	if err != nil {
+		return nil, err
+	}
+	old = append(old, repo.GetCACertPath(target), repo.GetCAPrivKeyPath(target), filepath.Join(target, pki.IndexName),
+		repo.GetCertPath(repo.AgencyName, target), repo.GetPrivKeyPath(repo.AgencyName, target))
+	for _, p := range old {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("remove %s: %w", filepath.Base(p), err)
+		}
+	}
+
+	return pki.Init(target, "Hyperchain", 0, keys)
+}
+
+// rekeyNodes replaces the node and account keys the config script generated by the ones derived by keys,
+// node certs are issued again by the agency of ca, and the pids and accounts in network.toml and the genesis
+// of every node are rewritten
+func rekeyNodes(target string, names []string, ca *pki.Authority, keys *hdkey.Deriver) error {
+	replacer := make([]string, 0, 4*len(names))
+	for _, name := range names {
+		certRoot := filepath.Join(target, name, types.TlsCerts)
+		oldPid, err := getPidFromPrivateKey(repo.GetPrivKeyPath(pki.RoleNode, certRoot))
+		if err != nil {
+			return err
+		}
+		oldAddr, err := getAddressFromPrivateKey(repo.GetPrivKeyPath(repo.KeyPriv, certRoot), crypto.Secp256k1)
+		if err != nil {
+			return err
+		}
+
+		// node keys are derived by ca along the paths the config generator of `goduck up` uses,
+		// and certs are issued with ca.cert and agency.cert of ca
+		for _, p := range []string{repo.GetPrivKeyPath(pki.RoleNode, certRoot), repo.GetCertPath(pki.RoleNode, certRoot)} {
+			if err := os.Remove(p); err != nil {
+				return err
+			}
+		}
+		org := strings.ToUpper(name[:1]) + name[1:]
+		if _, err := ca.Issue(&pki.Request{Name: name, Role: pki.RoleNode, Org: org, Dir: certRoot}); err != nil {
+			return fmt.Errorf("issue cert of %s: %w", name, err)
+		}
+		account, err := keys.Key(crypto.Secp256k1, "account", name)
+		if err != nil {
+			return err
+		}
+		if err := writePrivKey(repo.KeyPriv, certRoot, account); err != nil {
+			return err
+		}
+		// BitXHub nodes decrypt key.json with the default password when they start
+		if err := asym.StorePrivateKey(account, filepath.Join(target, name, repo.KeyName), repo.KeyPassword); err != nil {
+			return err
+		}
+
+		pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath(pki.RoleNode, certRoot))
+		if err != nil {
+			return err
+		}
+		addr, err := account.PublicKey().Address()
+		if err != nil {
+			return err
+		}
+		replacer = append(replacer, oldPid, pid, oldAddr, addr.String())
+	}
+
+	r := strings.NewReplacer(replacer...)
+	for _, name := range names {
+		for _, file := range []string{repo.NetworkConfigName, repo.BitXHubConfigName} {
+			path := filepath.Join(target, name, file)
+			if !fileutil.Exist(path) {
+				continue
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, []byte(r.Replace(string(data))), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readNodeLayout reads the mode and the number of nodes from the modify config
+func readNodeLayout(configPath string) (string, int, error) {
+	mode, err := utils.GetModifyConfigValue(configPath, "mode")
+	if err != nil {
+		return "", 0, err
+	}
+	if mode != types.ClusterMode {
+		return mode, 1, nil
+	}
+
+	numStr, err := utils.GetModifyConfigValue(configPath, "num")
+	if err != nil {
+		return "", 0, err
+	}
+	num, err := strconv.Atoi(numStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid node number %s: %w", numStr, err)
+	}
+
+	return mode, num, nil
 }
@@ -0,0 +1,383 @@
+package bitxhub
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeskyblue/go-sh"
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/supervisor"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/pelletier/go-toml"
+)
+
+// BinaryPath returns the directory of the extracted BitXHub binary
+func BinaryPath(repoPath, version string) string {
+	return filepath.Join(repoPath, "bin", fmt.Sprintf("bitxhub_%s_%s", runtime.GOOS, version))
+}
+
+// StatePath returns the path of the file which records the BitXHub nodes started by goduck
+func StatePath(repoPath string) string {
+	return filepath.Join(repoPath, types.BitXHub, types.BitxhubStateFile)
+}
+
+func pidPath(repoPath string) string {
+	return filepath.Join(repoPath, types.BitXHub, types.BitxhubPidFile)
+}
+
+// VersionPath returns the path of the file which records the versions of BitXHub nodes started by goduck
+func VersionPath(repoPath string) string {
+	return filepath.Join(repoPath, types.BitXHub, types.BitxhubVersionFile)
+}
+
+// NodeNames returns the repo names of nodes under the target directory
+func NodeNames(mode string, num int) []string {
+	if mode == types.SoloMode {
+		return []string{"nodeSolo"}
+	}
+
+	names := make([]string, 0, num)
+	for i := 1; i <= num; i++ {
+		names = append(names, "node"+strconv.Itoa(i))
+	}
+
+	return names
+}
+
+// PrepareBitxhubBinary makes the downloaded BitXHub binary runnable on current system
+func PrepareBitxhubBinary(repoPath, version string) error {
+	if err := ExtractBitxhubBinary(repoPath, version); err != nil {
+		return fmt.Errorf("extract binary error:%w", err)
+	}
+
+	if runtime.GOOS == types.DarwinSystem {
+		binPath := BinaryPath(repoPath, version)
+		err := sh.Command("/bin/bash", "-c", fmt.Sprintf("install_name_tool -change @rpath/libwasmer.dylib %s/libwasmer.dylib %s/bitxhub", binPath, binPath)).Run()
+		if err != nil {
+			return fmt.Errorf("change libwasmer path: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// StartBinaryNodes starts BitXHub nodes in target as daemon processes and records their states in repo,
+// the consensus plugin of each node is the one its bitxhub.toml names
+func StartBinaryNodes(repoPath, target, version, mode string, num int) error {
+	running, err := BinaryNodesRunning(repoPath)
+	if err != nil {
+		return err
+	}
+	if running {
+		return fmt.Errorf("BitXHub already run in daemon processes")
+	}
+
+	color.Blue("======> Start bitxhub %s by binary", mode)
+	states := make([]*supervisor.State, 0, num)
+	for _, name := range NodeNames(mode, num) {
+		state, err := startBinaryNode(repoPath, target, version, mode, name)
+		if err == nil {
+			states = append(states, state)
+			err = supervisor.SaveStates(StatePath(repoPath), pidPath(repoPath), states)
+		}
+		if err != nil {
+			// the nodes started so far would hold their ports against a retry
+			if stopErr := stopStarted(repoPath, states); stopErr != nil {
+				return fmt.Errorf("%w, and stop started nodes: %v", err, stopErr)
+			}
+			return err
+		}
+	}
+
+	if err := writeVersion(repoPath, version, len(states)); err != nil {
+		return err
+	}
+
+	color.Blue("You can use the \"goduck status list\" command to check the status of the startup BitXHub node.")
+	return nil
+}
+
+// stopStarted stops the nodes StartBinaryNodes started before one of them failed and removes their states
+func stopStarted(repoPath string, states []*supervisor.State) error {
+	for _, s := range states {
+		if !s.Running() {
+			continue
+		}
+		if err := supervisor.Stop(s, supervisor.DefaultStopTimeout); err != nil {
+			return err
+		}
+		fmt.Printf("node %s(pid:%d) exit\n", s.Name, s.Pid)
+	}
+
+	return supervisor.RemoveStates(StatePath(repoPath), pidPath(repoPath))
+}
+
+// StartBinaryNode starts the BitXHub node name in target next to the nodes recorded in repo, which are
+// left running
+func StartBinaryNode(repoPath, target, version, name string) error {
+	states, err := supervisor.LoadStates(StatePath(repoPath))
+	if err != nil {
+		return err
+	}
+	for _, s := range states {
+		if s.Name == name && s.Running() {
+			return fmt.Errorf("node %s already runs with pid %d", name, s.Pid)
+		}
+	}
+
+	color.Blue("======> Start bitxhub %s by binary", name)
+	state, err := startBinaryNode(repoPath, target, version, types.ClusterMode, name)
+	if err != nil {
+		return err
+	}
+
+	kept := make([]*supervisor.State, 0, len(states)+1)
+	for _, s := range states {
+		if s.Name != name {
+			kept = append(kept, s)
+		}
+	}
+	states = append(kept, state)
+	if err := supervisor.SaveStates(StatePath(repoPath), pidPath(repoPath), states); err != nil {
+		return err
+	}
+
+	return writeVersion(repoPath, version, len(states))
+}
+
+// StopBinaryNode stops the recorded BitXHub node name and drops it from the states in repo
+func StopBinaryNode(repoPath, name string, timeout time.Duration) error {
+	states, err := supervisor.LoadStates(StatePath(repoPath))
+	if err != nil {
+		return err
+	}
+
+	kept := make([]*supervisor.State, 0, len(states))
+	for _, s := range states {
+		if s.Name != name {
+			kept = append(kept, s)
+			continue
+		}
+		if s.Running() {
+			if err := supervisor.Stop(s, timeout); err != nil {
+				return err
+			}
+			fmt.Printf("node %s(pid:%d) exit\n", s.Name, s.Pid)
+		}
+	}
+	if len(kept) == len(states) {
+		return nil
+	}
+	if len(kept) == 0 {
+		return supervisor.RemoveStates(StatePath(repoPath), pidPath(repoPath))
+	}
+	if err := supervisor.SaveStates(StatePath(repoPath), pidPath(repoPath), kept); err != nil {
+		return err
+	}
+
+	return writeVersion(repoPath, kept[0].Version, len(kept))
+}
+
+// startBinaryNode starts the BitXHub node name in target as a daemon process with the consensus plugin its
+// bitxhub.toml names
+func startBinaryNode(repoPath, target, version, mode, name string) (*supervisor.State, error) {
+	binPath := BinaryPath(repoPath, version)
+	var env []string
+	if runtime.GOOS == types.LinuxSystem {
+		env = append(env, fmt.Sprintf("LD_LIBRARY_PATH=%s:%s", os.Getenv("LD_LIBRARY_PATH"), binPath))
+	}
+
+	nodeRepo := filepath.Join(target, name)
+	if !fileutil.Exist(nodeRepo) {
+		return nil, fmt.Errorf("configuration of %s does not exist in %s", name, target)
+	}
+
+	consensus, err := NodeConsensus(nodeRepo)
+	if err != nil {
+		return nil, err
+	}
+	if consensus == "" {
+		consensus = versions.DefaultConsensus(mode)
+	}
+	if err := CopyConsensusPlugin(binPath, nodeRepo, consensus); err != nil {
+		return nil, err
+	}
+
+	state, err := supervisor.Start(&supervisor.Spec{
+		Name:      name,
+		Component: types.BitXHub,
+		Version:   version,
+		Bin:       filepath.Join(binPath, types.BitXHub),
+		Args:      []string{"--repo", nodeRepo, "start"},
+		Env:       env,
+		Dir:       target,
+		Repo:      nodeRepo,
+		Log:       filepath.Join(repoPath, types.BitXHub, types.LogsDir, name+".log"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Start bitxhub %s, pid: %d, log: %s\n", name, state.Pid, state.Log)
+
+	return state, nil
+}
+
+// BinaryNodesRunning reports whether any BitXHub node recorded in repo is still running
+func BinaryNodesRunning(repoPath string) (bool, error) {
+	states, err := supervisor.LoadStates(StatePath(repoPath))
+	if err != nil {
+		return false, err
+	}
+	for _, s := range states {
+		if s.Running() {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// StopBinaryNodes stops the BitXHub nodes recorded in repo, SIGKILL is sent to nodes which are alive after timeout
+func StopBinaryNodes(repoPath string, timeout time.Duration) ([]*supervisor.State, error) {
+	states, err := supervisor.LoadStates(StatePath(repoPath))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(states) == 0 {
+		return nil, nil
+	}
+
+	color.Blue("======> Stop bitxhub")
+	for _, s := range states {
+		if !s.Running() {
+			fmt.Printf("node %s(pid:%d) has exited\n", s.Name, s.Pid)
+			continue
+		}
+
+		if err := supervisor.Stop(s, timeout); err != nil {
+			return nil, err
+		}
+		fmt.Printf("node %s(pid:%d) exit\n", s.Name, s.Pid)
+	}
+
+	if err := supervisor.RemoveStates(StatePath(repoPath), pidPath(repoPath)); err != nil {
+		return nil, err
+	}
+
+	return states, nil
+}
+
+// RestartBinaryNodes stops the recorded BitXHub nodes and starts them again with the same arguments
+func RestartBinaryNodes(repoPath string, timeout time.Duration) error {
+	states, err := StopBinaryNodes(repoPath, timeout)
+	if err != nil {
+		return err
+	}
+
+	if len(states) == 0 {
+		return fmt.Errorf("no BitXHub node started by goduck in binary mode")
+	}
+
+	color.Blue("======> Restart bitxhub")
+	newStates := make([]*supervisor.State, 0, len(states))
+	for _, s := range states {
+		state, err := supervisor.Start(s.Spec())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Start bitxhub %s, pid: %d, log: %s\n", state.Name, state.Pid, state.Log)
+
+		newStates = append(newStates, state)
+		if err := supervisor.SaveStates(StatePath(repoPath), pidPath(repoPath), newStates); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestartBinaryNode stops the recorded BitXHub node and starts it again with the same arguments
+func RestartBinaryNode(repoPath, name string, timeout time.Duration) error {
+	states, err := supervisor.LoadStates(StatePath(repoPath))
+	if err != nil {
+		return err
+	}
+
+	for i, s := range states {
+		if s.Name != name {
+			continue
+		}
+		if s.Running() {
+			if err := supervisor.Stop(s, timeout); err != nil {
+				return err
+			}
+		}
+		state, err := supervisor.Start(s.Spec())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restart bitxhub %s, pid: %d, log: %s\n", state.Name, state.Pid, state.Log)
+		states[i] = state
+
+		return supervisor.SaveStates(StatePath(repoPath), pidPath(repoPath), states)
+	}
+
+	return fmt.Errorf("node %s is not started by goduck in binary mode", name)
+}
+
+// CopyConsensusPlugin copies the consensus plugin from the BitXHub binary directory into plugins/ of the node
+func CopyConsensusPlugin(binPath, nodeRepo, consensus string) error {
+	pluginDir := filepath.Join(nodeRepo, "plugins")
+	plugin := fmt.Sprintf("%s.so", consensus)
+	if fileutil.Exist(filepath.Join(pluginDir, plugin)) {
+		return nil
+	}
+
+	if err := os.MkdirAll(pluginDir, 0755); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(binPath, plugin))
+	if err != nil {
+		return fmt.Errorf("read consensus plugin %s: %w", plugin, err)
+	}
+
+	return ioutil.WriteFile(filepath.Join(pluginDir, plugin), data, 0755)
+}
+
+// NodeConsensus returns the consensus named by the order plugin of bitxhub.toml in the node repo,
+// empty if it names none
+func NodeConsensus(nodeRepo string) (string, error) {
+	tree, err := toml.LoadFile(filepath.Join(nodeRepo, repo.BitXHubConfigName))
+	if err != nil {
+		return "", fmt.Errorf("load bitxhub config: %w", err)
+	}
+
+	if plugin, ok := tree.Get("order.plugin").(string); ok && plugin != "" {
+		return strings.TrimSuffix(filepath.Base(plugin), ".so"), nil
+	}
+	if typ, ok := tree.Get("order.type").(string); ok {
+		return typ, nil
+	}
+
+	return "", nil
+}
+
+func writeVersion(repoPath, version string, num int) error {
+	var content string
+	for i := 0; i < num; i++ {
+		content += version + "\n"
+	}
+
+	return ioutil.WriteFile(VersionPath(repoPath), []byte(content), 0644)
+}
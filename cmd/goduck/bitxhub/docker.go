@@ -0,0 +1,340 @@
+package bitxhub
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/hosts"
+	"github.com/meshplus/goduck/internal/orchestrator"
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/pelletier/go-toml"
+)
+
+// DefaultSubnet is the subnet of the network BitXHub containers in cluster mode are attached to
+const DefaultSubnet = "172.19.0.0/16"
+
+type nodePorts struct {
+	jsonrpc []string
+	grpc    []string
+	gateway []string
+	pprof   []string
+	monitor []string
+}
+
+func cidPath(repoPath string) string {
+	return filepath.Join(repoPath, types.BitXHub, types.BitxhubCidFile)
+}
+
+// NetworkName returns the name of the docker network BitXHub nodes of the repo are attached to
+func NetworkName(repoPath string) string {
+	return fmt.Sprintf("%s_p2p", repo.ProjectID(repoPath))
+}
+
+// StartDockerNodes starts BitXHub nodes in target as docker containers labeled with the project ID of repo,
+// in cluster mode nodes take addresses of subnet from the second one on
+func StartDockerNodes(repoPath, target, version, mode, configPath, subnet string, num int) error {
+	o, err := orchestrator.New(repo.ProjectID(repoPath))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	containers, err := o.List(ctx, types.BitXHub)
+	if err != nil {
+		return err
+	}
+	if len(containers) != 0 {
+		return fmt.Errorf("BitXHub containers already exist, please clean them first")
+	}
+
+	release, err := versions.BitXHubOf(version)
+	if err != nil {
+		return err
+	}
+
+	names := NodeNames(mode, num)
+	nodePorts, err := readNodePorts(configPath, len(names))
+	if err != nil {
+		return err
+	}
+	manifest, err := ports.Load(ports.ManifestPath(repoPath))
+	if err != nil {
+		return err
+	}
+
+	image := fmt.Sprintf(types.BitxhubImage, version)
+	network := ""
+	var ips []string
+	if mode == types.ClusterMode {
+		if subnet == "" {
+			subnet = DefaultSubnet
+		}
+		gateway, err := orchestrator.SubnetIP(subnet, 1)
+		if err != nil {
+			return err
+		}
+		for i := range names {
+			ip, err := orchestrator.SubnetIP(subnet, i+2)
+			if err != nil {
+				return err
+			}
+			ips = append(ips, ip)
+		}
+
+		network = NetworkName(repoPath)
+		if err := o.EnsureNetwork(ctx, types.BitXHub, &orchestrator.NetworkSpec{
+			Name:    network,
+			Subnet:  subnet,
+			Gateway: gateway,
+		}); err != nil {
+			return err
+		}
+	} else {
+		image = fmt.Sprintf(types.BitxhubSoloImage, version)
+	}
+
+	color.Blue("======> Start bitxhub %s mode by docker", mode)
+	var cids []string
+	for i, name := range names {
+		nodeRepo := filepath.Join(target, name)
+		if !fileutil.Exist(nodeRepo) {
+			return fmt.Errorf("configuration of %s does not exist in %s", name, target)
+		}
+
+		// ports allocated by the config generator take precedence over the modify config
+		ip := ""
+		p2pPort := strconv.Itoa(4001 + i)
+		hostPorts := []string{nodePorts.grpc[i], nodePorts.gateway[i], nodePorts.pprof[i], nodePorts.monitor[i]}
+		if release.HasJsonRpc() && len(nodePorts.jsonrpc) > i {
+			hostPorts = append(hostPorts, nodePorts.jsonrpc[i])
+		}
+		if allocated := manifest.Lookup(nodeRepo); allocated != nil {
+			p2pPort = strconv.Itoa(allocated.P2P)
+			hostPorts = []string{strconv.Itoa(allocated.Grpc), strconv.Itoa(allocated.Gateway),
+				strconv.Itoa(allocated.Pprof), strconv.Itoa(allocated.Monitor)}
+			if release.HasJsonRpc() {
+				hostPorts = append(hostPorts, strconv.Itoa(allocated.JsonRpc))
+			}
+		}
+		if mode == types.ClusterMode {
+			ip = ips[i]
+			hostPorts = append(hostPorts, p2pPort)
+			if err := rewriteDockerHosts(nodeRepo, ips); err != nil {
+				return err
+			}
+		}
+
+		var publish []string
+		for _, p := range hostPorts {
+			publish = append(publish, fmt.Sprintf("%s:%s", p, p))
+		}
+
+		cid, err := o.Up(ctx, &orchestrator.ContainerSpec{
+			Name:       containerName(name),
+			Component:  types.BitXHub,
+			Image:      image,
+			WorkingDir: "/root/.bitxhub",
+			Binds:      nodeBinds(nodeRepo),
+			Volumes: map[string]string{
+				fmt.Sprintf("%s_%s_storage", repo.ProjectID(repoPath), name): "/root/.bitxhub/storage",
+			},
+			Ports:   publish,
+			Network: network,
+			IP:      ip,
+			// BitXHub nodes are restarted by docker as their compose files did
+			RestartPolicy: "always",
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Start bitxhub %s, container: %s\n", name, cid[:12])
+		cids = append(cids, cid[:12])
+	}
+
+	if err := ioutil.WriteFile(cidPath(repoPath), []byte(strings.Join(cids, "\n")+"\n"), 0644); err != nil {
+		return err
+	}
+
+	if err := writeVersion(repoPath, version, len(cids)); err != nil {
+		return err
+	}
+
+	color.Blue("You can use the \"goduck status list\" command to check the status of the startup BitXHub node.")
+	return nil
+}
+
+// DockerNodesExist reports whether any BitXHub container of the repo exists, nothing exists if docker is not available
+func DockerNodesExist(repoPath string) (bool, error) {
+	o, err := orchestrator.New(repo.ProjectID(repoPath))
+	if err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+	if !o.Available(ctx) {
+		return false, nil
+	}
+
+	containers, err := o.List(ctx, types.BitXHub)
+	if err != nil {
+		return false, err
+	}
+
+	return len(containers) != 0, nil
+}
+
+// StopDockerNodes stops BitXHub containers of the repo, nothing is done if docker is not available
+func StopDockerNodes(repoPath string, timeout time.Duration) error {
+	o, err := orchestrator.New(repo.ProjectID(repoPath))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !o.Available(ctx) {
+		return nil
+	}
+
+	return o.Stop(ctx, types.BitXHub, "", timeout)
+}
+
+// RestartDockerNode restarts the BitXHub container of the node
+func RestartDockerNode(repoPath, name string, timeout time.Duration) error {
+	o, err := orchestrator.New(repo.ProjectID(repoPath))
+	if err != nil {
+		return err
+	}
+
+	return o.Restart(context.Background(), types.BitXHub, containerName(name), timeout)
+}
+
+// CleanDockerNodes removes BitXHub containers, volumes and network of the repo
+func CleanDockerNodes(repoPath string) error {
+	o, err := orchestrator.New(repo.ProjectID(repoPath))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !o.Available(ctx) {
+		return nil
+	}
+
+	return o.Down(ctx, types.BitXHub)
+}
+
+func containerName(name string) string {
+	if name == NodeNames(types.SoloMode, 1)[0] {
+		return "bitxhub_solo"
+	}
+
+	return "bitxhub_" + name
+}
+
+func nodeBinds(nodeRepo string) []string {
+	binds := []string{"/var/run/:/host/var/run/"}
+	for _, file := range []string{repo.BitXHubConfigName, repo.NetworkConfigName, repo.KeyName, "order.toml", types.TlsCerts} {
+		if !fileutil.Exist(filepath.Join(nodeRepo, file)) {
+			continue
+		}
+		binds = append(binds, fmt.Sprintf("%s:/root/.bitxhub/%s", filepath.Join(nodeRepo, file), file))
+	}
+
+	return binds
+}
+
+// rewriteDockerHosts points the peers in network.toml to the container addresses, the p2p addresses of node i+1
+// on this machine are moved to ips[i] with their ports kept
+func rewriteDockerHosts(nodeRepo string, ips []string) error {
+	path := filepath.Join(nodeRepo, repo.NetworkConfigName)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	network := &struct {
+		Nodes []struct {
+			ID    uint64   `toml:"id"`
+			Hosts []string `toml:"hosts"`
+		} `toml:"nodes"`
+	}{}
+	if err := toml.Unmarshal(data, network); err != nil {
+		return fmt.Errorf("unmarshal network config: %w", err)
+	}
+
+	content := string(data)
+	for i, ip := range ips {
+		matched := false
+		for _, node := range network.Nodes {
+			if node.ID != uint64(i+1) {
+				continue
+			}
+			for _, addr := range node.Hosts {
+				// addresses are like /ip4/127.0.0.1/tcp/4001/p2p/
+				fields := strings.Split(addr, "/")
+				if len(fields) < 5 || fields[3] != "tcp" {
+					continue
+				}
+				if fields[2] == ip {
+					matched = true
+					continue
+				}
+				if !ports.IsLocal(fields[2]) {
+					continue
+				}
+				fields[1], fields[2] = hosts.Protocol(ip), ip
+				content = strings.Replace(content, fmt.Sprintf("\"%s\"", addr), fmt.Sprintf("\"%s\"", strings.Join(fields, "/")), -1)
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("no p2p address of node%d on this machine is found in %s", i+1, path)
+		}
+	}
+
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
+
+// readNodePorts reads ports of nodes from the modify config, nodes without their own
+// section take the ports next to the previous node like bxh_config.sh does
+func readNodePorts(configPath string, num int) (*nodePorts, error) {
+	ports := &nodePorts{}
+	for key, dst := range map[string]*[]string{
+		"jsonrpc_port": &ports.jsonrpc,
+		"grpc_port":    &ports.grpc,
+		"gateway_port": &ports.gateway,
+		"pprof_port":   &ports.pprof,
+		"monitor_port": &ports.monitor,
+	} {
+		values, err := utils.GetModifyConfigValues(configPath, key)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			if key == "jsonrpc_port" {
+				continue
+			}
+			return nil, fmt.Errorf("%s is not found in %s", key, configPath)
+		}
+		for i := len(values); i < num; i++ {
+			prev, err := strconv.Atoi(values[i-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %s: %w", key, values[i-1], err)
+			}
+			values = append(values, strconv.Itoa(prev+1))
+		}
+		*dst = values
+	}
+
+	return ports, nil
+}
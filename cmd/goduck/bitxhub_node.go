@@ -0,0 +1,628 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	crypto2 "github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/bxh"
+	"github.com/meshplus/goduck/internal/hosts"
+	"github.com/meshplus/goduck/internal/pki"
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/tomledit"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+func bitxhubNodeCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "node",
+		Usage: "Add, remove and list vp nodes of a running BitXHub cluster, only useful for v1.8.0+",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "add",
+				Usage: "Generate a node, register it by a NodeMgr proposal and start it once the proposal is approved",
+				Flags: nodeProposalFlags(
+					&cli.StringFlag{
+						Name:  "host",
+						Value: "127.0.0.1",
+						Usage: "Specify the host other nodes reach the node at",
+					},
+				),
+				Action: addBitXHubNode,
+			},
+			{
+				Name:      "remove",
+				Usage:     "Log out a node by a NodeMgr proposal, then stop it and drop it from the cluster",
+				ArgsUsage: "<node name, e.g. node5>",
+				Flags:     nodeProposalFlags(stopTimeoutFlag),
+				Action:    removeBitXHubNode,
+			},
+			{
+				Name:  "list",
+				Usage: "List the nodes registered to the node manager",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "List nodes in json",
+					},
+				}, governanceFlags()...),
+				Action: listBitXHubNodes,
+			},
+		},
+	}
+}
+
+// nodeProposalFlags are the flags of the commands submitting NodeMgr proposals
+func nodeProposalFlags(flags ...cli.Flag) []cli.Flag {
+	flags = append(flags,
+		&cli.StringFlag{
+			Aliases: []string{"version", "v"},
+			Usage:   "BitXHub version (default: the version of the running nodes)",
+		},
+		&cli.BoolFlag{
+			Name:  "auto-approve",
+			Usage: "Approve the proposal with every admin key goduck generated",
+		},
+		&cli.DurationFlag{
+			Name:  "approve-wait",
+			Value: time.Minute,
+			Usage: "Specify how long the proposal is waited for to be approved",
+		},
+	)
+
+	return append(flags, governanceFlags()...)
+}
+
+func addBitXHubNode(ctx *cli.Context) error {
+	repoRoot, target, release, err := nodeCluster(ctx)
+	if err != nil {
+		return err
+	}
+	nodes, err := localNodes(repoRoot)
+	if err != nil {
+		return err
+	}
+	consensus, err := bitxhub.NodeConsensus(nodes[0])
+	if err != nil {
+		return err
+	}
+	network, err := readNetworkConfig(nodes[0])
+	if err != nil {
+		return err
+	}
+
+	// the node takes the next id, so an add which failed halfway picks up the node it generated
+	var id uint64
+	for _, n := range network.Nodes {
+		if n.ID > id {
+			id = n.ID
+		}
+	}
+	id++
+	name := "node" + strconv.FormatUint(id, 10)
+	nodeRepo := filepath.Join(target, name)
+	host := ctx.String("host")
+	if err := hosts.Check(host); err != nil {
+		return err
+	}
+
+	if fileutil.Exist(nodeRepo) {
+		fmt.Printf("%s exists, it is added with its configuration\n", name)
+	} else {
+		color.Blue("======> Generate %s", name)
+		if err := generateNewNode(repoRoot, target, nodes, name, host, int(id), consensus, release); err != nil {
+			return fmt.Errorf("generate %s: %w", name, err)
+		}
+	}
+
+	node, err := localNetworkNode(repoRoot, nodeRepo, id, host)
+	if err != nil {
+		return err
+	}
+	peers := append(network.Nodes, node)
+	if err := writeNetworkConfig(nodeRepo, &NetworkConfig{ID: id, N: uint64(len(network.Nodes)), New: true, Nodes: peers}); err != nil {
+		return err
+	}
+
+	client, key, err := governanceClient(ctx)
+	if err != nil {
+		return err
+	}
+	registered, err := registeredNode(client, key, node.Pid)
+	if err != nil {
+		return err
+	}
+	if registered == nil || registered.Status != bxh.NodeAvailable {
+		err := approveNodeProposal(ctx, client, key, node.Pid, func() (string, error) {
+			return client.RegisterNode(key, &bxh.Node{VPNodeId: id, Pid: node.Pid, Account: node.Account, NodeType: bxh.NodeTypeVP})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := bitxhub.StartBinaryNode(repoRoot, target, release.String(), name); err != nil {
+		return err
+	}
+
+	local, err := localNodes(repoRoot)
+	if err != nil {
+		return err
+	}
+	if err := updateNetworkConfigs(local, peers); err != nil {
+		return err
+	}
+	color.Green("Node %s(%s) joins the cluster with id %d", name, node.Pid, id)
+
+	return nil
+}
+
+func removeBitXHubNode(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expect <node name>")
+	}
+	repoRoot, target, _, err := nodeCluster(ctx)
+	if err != nil {
+		return err
+	}
+	name := ctx.Args().First()
+	nodeRepo := filepath.Join(target, name)
+	if !fileutil.Exist(filepath.Join(nodeRepo, repo.BitXHubConfigName)) {
+		return fmt.Errorf("node %s does not exist in %s", name, target)
+	}
+	pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath(pki.RoleNode, filepath.Join(nodeRepo, types.TlsCerts)))
+	if err != nil {
+		return err
+	}
+
+	client, key, err := governanceClient(ctx)
+	if err != nil {
+		return err
+	}
+	registered, err := registeredNode(client, key, pid)
+	if err != nil {
+		return err
+	}
+	if registered != nil && registered.Status != bxh.NodeUnavailable {
+		err := approveNodeProposal(ctx, client, key, pid, func() (string, error) {
+			return client.LogoutNode(key, pid)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	color.Blue("======> Remove %s", name)
+	if err := bitxhub.StopBinaryNode(repoRoot, name, ctx.Duration("timeout")); err != nil {
+		return err
+	}
+
+	var others []string
+	nodes, err := localNodes(repoRoot)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if n != nodeRepo {
+			others = append(others, n)
+		}
+	}
+	if len(others) != 0 {
+		network, err := readNetworkConfig(others[0])
+		if err != nil {
+			return err
+		}
+		var peers []*NetworkNodes
+		for _, n := range network.Nodes {
+			if n.Pid != pid {
+				peers = append(peers, n)
+			}
+		}
+		if err := updateNetworkConfigs(others, peers); err != nil {
+			return err
+		}
+	}
+
+	manifest, err := ports.Load(ports.ManifestPath(repoRoot))
+	if err != nil {
+		return err
+	}
+	manifest.Release(nodeRepo)
+	if err := manifest.Save(ports.ManifestPath(repoRoot)); err != nil {
+		return fmt.Errorf("save port manifest: %w", err)
+	}
+
+	ca, err := pki.Open(target)
+	if err != nil {
+		return err
+	}
+	if _, err := ca.Find(name); err == nil {
+		if _, err := ca.Revoke(name, "removed from the cluster"); err != nil {
+			return fmt.Errorf("revoke cert of %s: %w", name, err)
+		}
+	}
+
+	if err := os.RemoveAll(nodeRepo); err != nil {
+		return err
+	}
+	color.Green("Node %s(%s) is removed from the cluster", name, pid)
+
+	return nil
+}
+
+func listBitXHubNodes(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	client, key, err := governanceClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := client.Nodes(key)
+	if err != nil {
+		return fmt.Errorf("list nodes: %w", err)
+	}
+
+	if ctx.Bool("json") {
+		data, err := json.MarshalIndent(nodes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(nodes) == 0 {
+		fmt.Println("no nodes")
+		return nil
+	}
+	local, err := localNodes(repoRoot)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string)
+	for _, nodeRepo := range local {
+		pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath(pki.RoleNode, filepath.Join(nodeRepo, types.TlsCerts)))
+		if err == nil {
+			names[pid] = filepath.Base(nodeRepo)
+		}
+	}
+	rows := [][]string{{"Id", "Name", "Pid", "Account", "Type", "Status"}}
+	for _, n := range nodes {
+		name := names[n.Pid]
+		if name == "" {
+			name = "-"
+		}
+		rows = append(rows, []string{strconv.FormatUint(n.VPNodeId, 10), name, n.Pid, n.Account, n.NodeType, n.Status})
+	}
+	PrintTable(rows, true)
+
+	return nil
+}
+
+// nodeCluster returns the goduck repo, the directory of the running BitXHub cluster and its release, nodes are
+// only added to clusters started in binary
+func nodeCluster(ctx *cli.Context) (string, string, *versions.BitXHub, error) {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return "", "", nil, err
+	}
+	target := filepath.Join(repoRoot, types.BitXHub, ".bitxhub")
+
+	nodes, err := localNodes(repoRoot)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(nodes) == 0 {
+		return "", "", nil, fmt.Errorf("no BitXHub nodes in %s, start a cluster first", target)
+	}
+	if filepath.Base(nodes[0]) == "nodeSolo" {
+		return "", "", nil, fmt.Errorf("nodes can not be added to or removed from BitXHub in solo mode")
+	}
+	docker, err := bitxhub.DockerNodesExist(repoRoot)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if docker {
+		return "", "", nil, fmt.Errorf("nodes can only be added to or removed from BitXHub started in binary")
+	}
+
+	version := ctx.String("version")
+	if version == "" {
+		version = runningBitXHubVersion(repoRoot)
+	}
+	if version == "" {
+		return "", "", nil, fmt.Errorf("no BitXHub nodes started by goduck, specify the version with --version")
+	}
+	release, err := versions.BitXHubOf(version)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if !release.NodeManagement() {
+		return "", "", nil, fmt.Errorf("BitXHub %s has no node manager, nodes can be added since v1.8.0", release.Version)
+	}
+
+	return repoRoot, target, release, nil
+}
+
+// generateNewNode generates the repo of node name with certs issued by the agency in target, ports which none
+// of the nodes take and the genesis of the first node
+func generateNewNode(repoRoot, target string, nodes []string, name, host string, id int, consensus string, release *versions.BitXHub) error {
+	ca, err := pki.Open(target)
+	if err != nil {
+		return err
+	}
+	manifest, err := ports.Load(ports.ManifestPath(repoRoot))
+	if err != nil {
+		return err
+	}
+
+	// nodes configured by the config script are not in the manifest, their ports are taken from their configs
+	taken := &ports.Manifest{Nodes: append([]*ports.NodePorts{}, manifest.Nodes...)}
+	for _, nodeRepo := range nodes {
+		if manifest.Lookup(nodeRepo) != nil {
+			continue
+		}
+		p, err := configuredPorts(nodeRepo)
+		if err != nil {
+			return err
+		}
+		taken.Nodes = append(taken.Nodes, p)
+	}
+
+	b := &BitXHubConfigGenerator{
+		typ:       types.TypeBinary,
+		mode:      types.ClusterMode,
+		consensus: consensus,
+		target:    target,
+		version:   release.String(),
+		release:   release,
+	}
+	nodeRepo := filepath.Join(target, name)
+	if _, _, err := b.generateNodeConfig(target, types.ClusterMode, ca, host, id, map[string]int{host: id}, ports.NewAllocator(taken)); err != nil {
+		os.RemoveAll(nodeRepo)
+		return err
+	}
+	manifest.Nodes = append(manifest.Nodes, taken.Lookup(nodeRepo))
+	if err := manifest.Save(ports.ManifestPath(repoRoot)); err != nil {
+		return fmt.Errorf("save port manifest: %w", err)
+	}
+
+	return copyGenesis(nodeRepo, nodes[0])
+}
+
+// configuredPorts returns the ports in bitxhub.toml of the node in nodeRepo, with the host and p2p port of the
+// node in its network.toml
+func configuredPorts(nodeRepo string) (*ports.NodePorts, error) {
+	tree, err := toml.LoadFile(filepath.Join(nodeRepo, repo.BitXHubConfigName))
+	if err != nil {
+		return nil, fmt.Errorf("load bitxhub config: %w", err)
+	}
+	port := func(key string) int {
+		p, _ := tree.Get(key).(int64)
+		return int(p)
+	}
+	p := &ports.NodePorts{
+		Repo:    nodeRepo,
+		Host:    "127.0.0.1",
+		JsonRpc: port("port.jsonrpc"),
+		Grpc:    port("port.grpc"),
+		Gateway: port("port.gateway"),
+		Pprof:   port("port.pprof"),
+		Monitor: port("port.monitor"),
+	}
+
+	network, err := readNetworkConfig(nodeRepo)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range network.Nodes {
+		if n.ID != network.ID || len(n.Hosts) == 0 {
+			continue
+		}
+		// hosts are like /ip4/127.0.0.1/tcp/4001/p2p/
+		fields := strings.Split(n.Hosts[0], "/")
+		for i := 0; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "ip4", "ip6", "dns4":
+				p.Host = fields[i+1]
+			case "tcp":
+				p.P2P, _ = strconv.Atoi(fields[i+1])
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// copyGenesis replaces the genesis in bitxhub.toml of the node in nodeRepo by the one of the node in srcRepo
+func copyGenesis(nodeRepo, srcRepo string) error {
+	src, err := tomledit.Load(filepath.Join(srcRepo, repo.BitXHubConfigName))
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(nodeRepo, repo.BitXHubConfigName)
+	doc, err := tomledit.Load(path)
+	if err != nil {
+		return err
+	}
+	if err := doc.CopyTable("genesis", src); err != nil {
+		return err
+	}
+
+	return doc.Save(path)
+}
+
+// localNetworkNode returns the entry of the node in nodeRepo in network.toml, which is reached at host
+func localNetworkNode(repoRoot, nodeRepo string, id uint64, host string) (*NetworkNodes, error) {
+	certRoot := filepath.Join(nodeRepo, types.TlsCerts)
+	pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath(pki.RoleNode, certRoot))
+	if err != nil {
+		return nil, fmt.Errorf("get pid from private key: %w", err)
+	}
+	addr, err := getAddressFromPrivateKey(repo.GetPrivKeyPath(repo.KeyPriv, certRoot), crypto2.Secp256k1)
+	if err != nil {
+		return nil, fmt.Errorf("get address from private key: %w", err)
+	}
+
+	manifest, err := ports.Load(ports.ManifestPath(repoRoot))
+	if err != nil {
+		return nil, err
+	}
+	p := manifest.Lookup(nodeRepo)
+	if p == nil {
+		return nil, fmt.Errorf("no ports of %s in the port manifest", filepath.Base(nodeRepo))
+	}
+
+	return &NetworkNodes{
+		ID:      id,
+		Pid:     pid,
+		Hosts:   []string{hosts.P2PAddr(host, p.P2P, "")},
+		Account: addr,
+	}, nil
+}
+
+// registeredNode returns the node of pid registered to the node manager, nil if there is none
+func registeredNode(client *bxh.Client, key crypto2.PrivateKey, pid string) (*bxh.Node, error) {
+	nodes, err := client.Nodes(key)
+	if err != nil {
+		return nil, fmt.Errorf("list nodes: %w", err)
+	}
+	for _, n := range nodes {
+		if n.Pid == pid {
+			return n, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// approveNodeProposal submits the NodeMgr proposal on the node of pid unless one is open, approves it with the
+// local admin keys if --auto-approve is set, and waits until it is approved
+func approveNodeProposal(ctx *cli.Context, client *bxh.Client, key crypto2.PrivateKey, pid string, submit func() (string, error)) error {
+	proposals, err := client.Proposals(key, bxh.ProposalProposed)
+	if err != nil {
+		return fmt.Errorf("list proposals: %w", err)
+	}
+	id := ""
+	for _, p := range proposals {
+		if p.Typ == "NodeMgr" && p.ObjId == pid {
+			id = p.Id
+		}
+	}
+	if id == "" {
+		if id, err = submit(); err != nil {
+			return fmt.Errorf("submit proposal on node %s: %w", pid, err)
+		}
+		color.Green("Proposal %s on node %s is submitted", id, pid)
+	} else {
+		fmt.Printf("proposal %s on node %s is open\n", id, pid)
+	}
+
+	if ctx.Bool("auto-approve") {
+		repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+		if err != nil {
+			return err
+		}
+		admins, err := adminKeys(repoRoot)
+		if err != nil {
+			return err
+		}
+		if len(admins) == 0 {
+			return fmt.Errorf("no admin keys in %s to approve proposals with", repoRoot)
+		}
+		if _, err := voteAll(client, admins, id, true, "approved by goduck"); err != nil {
+			return err
+		}
+	} else {
+		color.Yellow("Approve proposal %s with `goduck governance vote --id %s --approve`, goduck waits %s for it", id, id, ctx.Duration("approve-wait"))
+	}
+
+	if err := waitProposalClosed(client, &AdminKey{Key: key}, id, ctx.Duration("approve-wait")); err != nil {
+		return fmt.Errorf("%w, run the command again once it is approved", err)
+	}
+
+	return nil
+}
+
+func readNetworkConfig(nodeRepo string) (*NetworkConfig, error) {
+	data, err := ioutil.ReadFile(filepath.Join(nodeRepo, repo.NetworkConfigName))
+	if err != nil {
+		return nil, fmt.Errorf("read network config: %w", err)
+	}
+
+	network := &NetworkConfig{}
+	if err := toml.Unmarshal(data, network); err != nil {
+		return nil, fmt.Errorf("unmarshal network config: %w", err)
+	}
+
+	return network, nil
+}
+
+// writeNetworkConfig writes network to network.toml of the node in nodeRepo, an existing file is edited in place
+// so that its comments and the keys goduck does not know are kept
+func writeNetworkConfig(nodeRepo string, network *NetworkConfig) error {
+	path := filepath.Join(nodeRepo, repo.NetworkConfigName)
+	if !fileutil.Exist(path) {
+		data, err := toml.Marshal(*network)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, data, 0644)
+	}
+
+	doc, err := tomledit.Load(path)
+	if err != nil {
+		return err
+	}
+	if err := doc.Set("id", network.ID); err != nil {
+		return err
+	}
+	if err := doc.Set("n", network.N); err != nil {
+		return err
+	}
+	if err := doc.Set("new", network.New); err != nil {
+		return err
+	}
+	if err := doc.SetTables("nodes", network.Nodes); err != nil {
+		return err
+	}
+
+	return doc.Save(path)
+}
+
+// updateNetworkConfigs sets the peers in network.toml of the nodes, which all keep their ids and no longer
+// join as new nodes
+func updateNetworkConfigs(nodeRepos []string, peers []*NetworkNodes) error {
+	color.Blue("======> Update network config of %d nodes", len(nodeRepos))
+	for _, nodeRepo := range nodeRepos {
+		network, err := readNetworkConfig(nodeRepo)
+		if err != nil {
+			return err
+		}
+		network.N = uint64(len(peers))
+		network.New = false
+		network.Nodes = peers
+		if err := writeNetworkConfig(nodeRepo, network); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/pki"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/urfave/cli/v2"
+)
+
+// certTimeFormat is how validity of certs is shown
+const certTimeFormat = "2006-01-02 15:04:05"
+
+var caDirFlag = &cli.StringFlag{
+	Name:  "ca-dir",
+	Usage: "Specify the directory of the CA and its index of issued certs (default: $repo/bitxhub/.bitxhub)",
+}
+
+var certDaysFlag = &cli.IntFlag{
+	Name:  "days",
+	Usage: "Specify the validity of certs in days (default: 50 years, or the validity of the renewed cert)",
+}
+
+func certCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "cert",
+		Usage: "Manage the CA of BitXHub nodes and the certs it issues",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "ca",
+				Usage: "Manage the CA",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "init",
+						Usage: "Create the CA and the default agency, or index the certs of an existing CA",
+						Flags: []cli.Flag{
+							caDirFlag,
+							certDaysFlag,
+							&cli.StringFlag{
+								Name:  "org",
+								Value: "Hyperchain",
+								Usage: "Specify the organization of the CA",
+							},
+						},
+						Action: certCAInit,
+					},
+				},
+			},
+			{
+				Name:  "issue",
+				Usage: "Generate a key and issue a cert for an agency, a node or a pier",
+				Flags: []cli.Flag{
+					caDirFlag,
+					certDaysFlag,
+					&cli.StringFlag{
+						Name:     "role",
+						Usage:    fmt.Sprintf("Specify the role of the cert, one of %s", strings.Join(pki.Roles, ", ")),
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "name",
+						Usage:    "Specify the name of the cert, e.g. node5, which is unique among valid certs",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "org",
+						Usage: "Specify the organization of the cert (default: the capitalized name)",
+					},
+					&cli.StringFlag{
+						Name:  "issuer",
+						Usage: "Specify the agency issuing node and pier certs (default: agency)",
+					},
+					&cli.StringFlag{
+						Name:  "target",
+						Usage: "Specify the directory to write the key and cert (default: $ca-dir for agencies, $ca-dir/$name/certs otherwise)",
+					},
+				},
+				Action: certIssue,
+			},
+			{
+				Name:      "renew",
+				Usage:     "Issue a new cert with the key and subject of a cert",
+				ArgsUsage: "<name|serial>",
+				Flags:     []cli.Flag{caDirFlag, certDaysFlag},
+				Action:    certRenew,
+			},
+			{
+				Name:      "revoke",
+				Usage:     "Revoke a cert and update the CRL of its issuer",
+				ArgsUsage: "<name|serial>",
+				Flags: []cli.Flag{
+					caDirFlag,
+					&cli.StringFlag{
+						Name:  "reason",
+						Usage: "Specify why the cert is revoked",
+					},
+				},
+				Action: certRevoke,
+			},
+			{
+				Name:  "list",
+				Usage: "List the certs issued by the CA",
+				Flags: []cli.Flag{
+					caDirFlag,
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "List certs in json",
+					},
+				},
+				Action: certList,
+			},
+			{
+				Name:      "inspect",
+				Usage:     "Show the details of a cert and whether it is trusted by the CA",
+				ArgsUsage: "<name|serial|path>",
+				Flags:     []cli.Flag{caDirFlag},
+				Action:    certInspect,
+			},
+			certCheckCMD(),
+			certRotateCMD(),
+		},
+	}
+}
+
+func caDir(ctx *cli.Context) (string, error) {
+	if dir := ctx.String("ca-dir"); dir != "" {
+		return dir, nil
+	}
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(repoRoot, "bitxhub", ".bitxhub"), nil
+}
+
+func openCA(ctx *cli.Context) (*pki.Authority, error) {
+	dir, err := caDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return pki.Open(dir)
+}
+
+func certValidity(ctx *cli.Context) (time.Duration, error) {
+	days := ctx.Int("days")
+	if days < 0 {
+		return 0, fmt.Errorf("days must be positive")
+	}
+
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+func certCAInit(ctx *cli.Context) error {
+	dir, err := caDir(ctx)
+	if err != nil {
+		return err
+	}
+	validity, err := certValidity(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pki.Exist(dir) {
+		ca, err := pki.Open(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("CA already exists in %s, %d certs are indexed in %s\n", dir, len(ca.Records), pki.IndexName)
+		return nil
+	}
+
+	if _, err := pki.Init(dir, ctx.String("org"), validity, nil); err != nil {
+		return err
+	}
+	color.Green("CA and agency are created in %s", dir)
+
+	return nil
+}
+
+func certIssue(ctx *cli.Context) error {
+	ca, err := openCA(ctx)
+	if err != nil {
+		return err
+	}
+	validity, err := certValidity(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, err := ca.Issue(&pki.Request{
+		Name:     ctx.String("name"),
+		Role:     ctx.String("role"),
+		Org:      ctx.String("org"),
+		Issuer:   ctx.String("issuer"),
+		Dir:      ctx.String("target"),
+		Validity: validity,
+	})
+	if err != nil {
+		return err
+	}
+	color.Green("%s cert %s is issued by %s with serial %s", r.Role, r.Name, r.Issuer, r.Serial)
+	fmt.Printf("cert: %s\nkey: %s\n", ca.Path(r.Cert), ca.Path(r.Key))
+
+	return nil
+}
+
+func certRenew(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expect <name|serial>")
+	}
+	ca, err := openCA(ctx)
+	if err != nil {
+		return err
+	}
+	validity, err := certValidity(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, err := ca.Renew(ctx.Args().First(), validity)
+	if err != nil {
+		return err
+	}
+	color.Green("%s cert %s is renewed with serial %s until %s", r.Role, r.Name, r.Serial, r.NotAfter.Local().Format(certTimeFormat))
+	if r.Role != pki.RoleCA && r.Role != pki.RoleAgency {
+		return nil
+	}
+	// issued certs hold copies of the renewed one, which are outdated now
+	for _, child := range ca.Records {
+		if child.Issuer == r.Name && child.Status == pki.StatusValid && child.Role != pki.RoleAgency {
+			color.Yellow("copy %s to the certs directory of %s", ca.Path(r.Cert), child.Name)
+		}
+	}
+
+	return nil
+}
+
+func certRevoke(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expect <name|serial>")
+	}
+	ca, err := openCA(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, err := ca.Revoke(ctx.Args().First(), ctx.String("reason"))
+	if err != nil {
+		return err
+	}
+	issuer, err := ca.Find(r.Issuer)
+	if err != nil {
+		return err
+	}
+	color.Green("%s cert %s with serial %s is revoked, the CRL is %s", r.Role, r.Name, r.Serial, ca.CRLPath(issuer))
+
+	return nil
+}
+
+func certList(ctx *cli.Context) error {
+	ca, err := openCA(ctx)
+	if err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		data, err := json.MarshalIndent(ca.Records, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	table := [][]string{{"Name", "Role", "Issuer", "Serial", "Not After", "Status", "Cert"}}
+	now := time.Now()
+	for _, r := range ca.Records {
+		status := r.Status
+		if status == pki.StatusValid && r.Expired(now) {
+			status = "expired"
+		}
+		table = append(table, []string{r.Name, r.Role, r.Issuer, r.Serial, r.NotAfter.Local().Format(certTimeFormat), status, ca.Path(r.Cert)})
+	}
+	PrintTable(table, true)
+
+	return nil
+}
+
+func certInspect(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expect <name|serial|path>")
+	}
+	ref := ctx.Args().First()
+
+	dir, err := caDir(ctx)
+	if err != nil {
+		return err
+	}
+	var ca *pki.Authority
+	if pki.Exist(dir) {
+		if ca, err = pki.Open(dir); err != nil {
+			return err
+		}
+	}
+
+	path := ref
+	if !fileutil.Exist(path) {
+		if ca == nil {
+			return fmt.Errorf("%s is not a cert file and there is no CA in %s", ref, dir)
+		}
+		r, err := ca.Find(ref)
+		if err != nil {
+			return err
+		}
+		path = ca.Path(r.Cert)
+	}
+	c, err := pki.ReadCert(path)
+	if err != nil {
+		return err
+	}
+
+	fingerprint := sha256.Sum256(c.Raw)
+	table := [][]string{
+		{"File", path},
+		{"Subject", c.Subject.String()},
+		{"Issuer", c.Issuer.String()},
+		{"Serial", fmt.Sprintf("%x", c.SerialNumber)},
+		{"Not Before", c.NotBefore.Local().Format(certTimeFormat)},
+		{"Not After", c.NotAfter.Local().Format(certTimeFormat)},
+		{"CA", fmt.Sprintf("%t", c.IsCA)},
+		{"Key Usage", strings.Join(keyUsages(c.KeyUsage), ", ")},
+		{"SHA256", hex.EncodeToString(fingerprint[:])},
+	}
+	PrintTable(table, false)
+
+	if ca == nil {
+		return nil
+	}
+	if err := ca.Verify(c); err != nil {
+		color.Red("not trusted by the CA in %s: %s", dir, err)
+		return nil
+	}
+	color.Green("trusted by the CA in %s", dir)
+
+	return nil
+}
+
+func keyUsages(usage x509.KeyUsage) []string {
+	names := []string{"DigitalSignature", "ContentCommitment", "KeyEncipherment", "DataEncipherment",
+		"KeyAgreement", "CertSign", "CRLSign", "EncipherOnly", "DecipherOnly"}
+	var usages []string
+	for i, name := range names {
+		if usage&(1<<uint(i)) != 0 {
+			usages = append(usages, name)
+		}
+	}
+
+	return usages
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeskyblue/go-sh"
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub/pkg/cert"
+	"github.com/meshplus/goduck/internal/inventory"
+	"github.com/meshplus/goduck/internal/pki"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+// localHost is the host of certs found in the repo
+const localHost = "local"
+
+// CertExpiry is the expiry of a cert in the certs directory of a node or pier
+type CertExpiry struct {
+	Component string    `json:"component"`
+	Node      string    `json:"node"`
+	Host      string    `json:"host"`
+	File      string    `json:"file"`
+	Subject   string    `json:"subject"`
+	NotAfter  time.Time `json:"not_after"`
+	DaysLeft  int       `json:"days_left"`
+}
+
+func certCheckCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "check",
+		Usage: "Report days to expiry of the certs of local nodes and piers, and of the ones in the deploy inventory",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "bitxhub-target",
+				Usage: "Specify the directory of BitXHub node repos (default: $repo/bitxhub/.bitxhub)",
+			},
+			&cli.StringFlag{
+				Name:  "pier-target",
+				Usage: "Specify the directory of pier repos (default: $repo/pier)",
+			},
+			&cli.IntFlag{
+				Name:  "warn-days",
+				Value: 30,
+				Usage: "Specify how many days before expiry a cert is reported",
+			},
+			&cli.BoolFlag{
+				Name:  "no-remote",
+				Usage: "Skip the nodes and piers of the deploy inventory",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Report certs in json",
+			},
+		},
+		Action: certCheck,
+	}
+}
+
+func certCheck(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	bxhTarget := ctx.String("bitxhub-target")
+	if bxhTarget == "" {
+		bxhTarget = filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+	}
+	pierTarget := ctx.String("pier-target")
+	if pierTarget == "" {
+		pierTarget = filepath.Join(repoRoot, types.Pier)
+	}
+	warnDays := ctx.Int("warn-days")
+
+	var certs []*CertExpiry
+	for _, local := range []struct{ component, pattern string }{
+		{types.BitXHub, filepath.Join(bxhTarget, "*", types.TlsCerts, "*.cert")},
+		{types.Pier, filepath.Join(pierTarget, ".pier_*", types.TlsCerts, "*.cert")},
+	} {
+		found, err := localCertExpiries(local.component, local.pattern)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, found...)
+	}
+
+	var unreachable []string
+	if !ctx.Bool("no-remote") {
+		inv, err := inventory.Load(repoRoot)
+		if err != nil {
+			return err
+		}
+		var remotes []*inventory.Host
+		var components []string
+		if inv.BitXHub != nil {
+			for _, node := range inv.BitXHub.Nodes {
+				remotes, components = append(remotes, node), append(components, types.BitXHub)
+			}
+		}
+		for _, pier := range inv.Piers {
+			remotes, components = append(remotes, pier), append(components, types.Pier)
+		}
+		for i, h := range remotes {
+			found, err := remoteCertExpiries(components[i], h)
+			if err != nil {
+				color.Red("check certs of %s %s at %s: %s", components[i], h.Name, h.Host, err)
+				unreachable = append(unreachable, h.Name)
+				continue
+			}
+			certs = append(certs, found...)
+		}
+	}
+
+	sort.SliceStable(certs, func(i, j int) bool {
+		return certs[i].DaysLeft < certs[j].DaysLeft
+	})
+
+	if ctx.Bool("json") {
+		if certs == nil {
+			certs = []*CertExpiry{}
+		}
+		data, err := json.MarshalIndent(certs, "", "  ")
+		if err != nil {
+			return err
+		}
+		// the report is the whole output in json, so errors are not returned after it
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(certs) == 0 {
+		fmt.Println("no certs found")
+	} else {
+		table := [][]string{{"Component", "Node", "Host", "File", "Subject", "Not After", "Days Left"}}
+		for _, c := range certs {
+			table = append(table, []string{c.Component, c.Node, c.Host, c.File, c.Subject,
+				c.NotAfter.Local().Format(certTimeFormat), strconv.Itoa(c.DaysLeft)})
+		}
+		PrintTable(table, true)
+	}
+
+	expiring := 0
+	for _, c := range certs {
+		switch {
+		case c.DaysLeft < 0:
+			color.Red("%s of %s %s at %s is expired", c.File, c.Component, c.Node, c.Host)
+		case c.DaysLeft < warnDays:
+			color.Yellow("%s of %s %s at %s expires in %d days", c.File, c.Component, c.Node, c.Host, c.DaysLeft)
+		default:
+			continue
+		}
+		expiring++
+	}
+	if len(unreachable) != 0 {
+		return fmt.Errorf("certs of %s are not checked", strings.Join(unreachable, ", "))
+	}
+	if expiring != 0 {
+		return fmt.Errorf("%d certs expire within %d days, run `goduck cert rotate` to reissue them", expiring, warnDays)
+	}
+
+	return nil
+}
+
+// localCertExpiries reads the certs matching pattern, which is like <target>/<node>/certs/*.cert
+func localCertExpiries(component, pattern string) ([]*CertExpiry, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*CertExpiry
+	for _, p := range paths {
+		c, err := pki.ReadCert(p)
+		if err != nil {
+			return nil, err
+		}
+		node := filepath.Base(filepath.Dir(filepath.Dir(p)))
+		certs = append(certs, newCertExpiry(component, strings.TrimPrefix(node, ".pier_"), localHost, filepath.Base(p), c.Subject.Organization, c.NotAfter))
+	}
+
+	return certs, nil
+}
+
+// remoteCertExpiries reads the certs in the certs directory of the repo on the server over ssh
+func remoteCertExpiries(component string, h *inventory.Host) ([]*CertExpiry, error) {
+	// every cert is printed after a line naming its file
+	script := fmt.Sprintf(`cd %s/%s 2>/dev/null || exit 0; for f in *.cert; do [ -f "$f" ] && echo "# $f" && cat "$f"; done; true`, h.Repo, types.TlsCerts)
+	out, err := sh.Command("ssh", h.Who(), script).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*CertExpiry
+	var file string
+	var block bytes.Buffer
+	flush := func() error {
+		if file == "" {
+			return nil
+		}
+		c, err := cert.ParseCert(block.Bytes())
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", file, err)
+		}
+		certs = append(certs, newCertExpiry(component, h.Name, h.Host, file, c.Subject.Organization, c.NotAfter))
+		return nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# ") {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			file = strings.TrimPrefix(line, "# ")
+			block.Reset()
+			continue
+		}
+		block.WriteString(line + "\n")
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+func newCertExpiry(component, node, host, file string, orgs []string, notAfter time.Time) *CertExpiry {
+	return &CertExpiry{
+		Component: component,
+		Node:      node,
+		Host:      host,
+		File:      file,
+		Subject:   strings.Join(orgs, ","),
+		NotAfter:  notAfter,
+		DaysLeft:  int(math.Floor(time.Until(notAfter).Hours() / 24)),
+	}
+}
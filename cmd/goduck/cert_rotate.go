@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/codeskyblue/go-sh"
+	"github.com/fatih/color"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/inventory"
+	"github.com/meshplus/goduck/internal/pki"
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/supervisor"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+// rotatedNode is a node whose cert is reissued
+type rotatedNode struct {
+	name string
+	// root is the local repo of the node
+	root string
+}
+
+func certRotateCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "rotate",
+		Usage: "Reissue node and agency certs from the existing CA, distribute them and restart nodes one at a time",
+		Flags: []cli.Flag{
+			certDaysFlag,
+			&cli.BoolFlag{
+				Name:  "remote",
+				Usage: "Rotate the certs of the BitXHub cluster in the deploy inventory instead of the local one",
+			},
+			&cli.DurationFlag{
+				Name:  "wait",
+				Value: time.Minute,
+				Usage: "Specify how long to wait for a restarted node to listen before the next one is restarted",
+			},
+			&cli.BoolFlag{
+				Name:  "no-restart",
+				Usage: "Only reissue and distribute certs, nodes take them when they restart",
+			},
+		},
+		Action: certRotate,
+	}
+}
+
+func certRotate(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	validity, err := certValidity(ctx)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+	var inv *inventory.Inventory
+	if ctx.Bool("remote") {
+		if inv, err = inventory.Load(repoRoot); err != nil {
+			return err
+		}
+		if inv.BitXHub == nil {
+			return fmt.Errorf("no BitXHub cluster in %s, deploy it by `goduck deploy bitxhub` first", inventory.Path(repoRoot))
+		}
+		dir = inv.BitXHub.Config
+	}
+
+	ca, err := pki.Open(dir)
+	if err != nil {
+		return err
+	}
+	nodes, err := rotateCerts(ca, validity)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no node cert is issued by the CA in %s", dir)
+	}
+
+	if inv != nil {
+		return rotateRemoteNodes(inv.BitXHub, nodes, ctx.Bool("no-restart"), ctx.Duration("wait"))
+	}
+	if ctx.Bool("no-restart") {
+		return nil
+	}
+
+	return rotateLocalNodes(repoRoot, nodes, ctx.Duration("wait"))
+}
+
+// rotateCerts renews the agencies and then the node certs they issued, which keep their keys and so their pids
+func rotateCerts(ca *pki.Authority, validity time.Duration) ([]*rotatedNode, error) {
+	color.Blue("======> Reissue certs from the CA in %s", ca.Dir())
+	var agencies, nodes []*pki.Record
+	for _, r := range ca.Records {
+		if r.Status != pki.StatusValid {
+			continue
+		}
+		switch r.Role {
+		case pki.RoleAgency:
+			agencies = append(agencies, r)
+		case pki.RoleNode:
+			nodes = append(nodes, r)
+		}
+	}
+
+	for _, r := range agencies {
+		renewed, err := ca.Renew(r.Serial, validity)
+		if err != nil {
+			return nil, fmt.Errorf("renew agency %s: %w", r.Name, err)
+		}
+		fmt.Printf("agency %s is renewed until %s\n", renewed.Name, renewed.NotAfter.Local().Format(certTimeFormat))
+	}
+
+	var rotated []*rotatedNode
+	var until time.Time
+	for _, r := range nodes {
+		renewed, err := ca.Renew(r.Serial, validity)
+		if err != nil {
+			return nil, fmt.Errorf("renew node %s: %w", r.Name, err)
+		}
+		issuer, err := ca.Find(renewed.Issuer)
+		if err != nil {
+			return nil, err
+		}
+
+		// nodes read their agency as agency.cert next to node.cert, the CA is refreshed in case it was renewed
+		certDir := filepath.Dir(ca.Path(renewed.Cert))
+		for dst, src := range map[string]string{
+			repo.GetCertPath(repo.AgencyName, certDir): ca.Path(issuer.Cert),
+			repo.GetCACertPath(certDir):                repo.GetCACertPath(ca.Dir()),
+		} {
+			data, err := ioutil.ReadFile(src)
+			if err != nil {
+				return nil, err
+			}
+			if err := ioutil.WriteFile(dst, data, 0644); err != nil {
+				return nil, err
+			}
+		}
+		fmt.Printf("node %s is renewed until %s\n", renewed.Name, renewed.NotAfter.Local().Format(certTimeFormat))
+
+		rotated = append(rotated, &rotatedNode{name: renewed.Name, root: filepath.Dir(certDir)})
+		until = renewed.NotAfter
+	}
+
+	if root, err := ca.Find(pki.RoleCA); err == nil && root.NotAfter.Before(until) {
+		color.Yellow("the CA expires at %s before the reissued certs, renew it by `goduck cert renew ca` and rotate again",
+			root.NotAfter.Local().Format(certTimeFormat))
+	}
+
+	return rotated, nil
+}
+
+// rotateLocalNodes restarts the running local nodes one by one, waiting for each to listen again
+func rotateLocalNodes(repoRoot string, nodes []*rotatedNode, wait time.Duration) error {
+	states, err := supervisor.LoadStates(bitxhub.StatePath(repoRoot))
+	if err != nil {
+		return err
+	}
+	binary := make(map[string]bool)
+	for _, s := range states {
+		binary[s.Name] = s.Running()
+	}
+	docker, err := bitxhub.DockerNodesExist(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	color.Blue("======> Restart nodes one at a time")
+	for _, node := range nodes {
+		switch {
+		case binary[node.name]:
+			err = bitxhub.RestartBinaryNode(repoRoot, node.name, supervisor.DefaultStopTimeout)
+		case docker:
+			err = bitxhub.RestartDockerNode(repoRoot, node.name, supervisor.DefaultStopTimeout)
+		default:
+			fmt.Printf("node %s is not running, it takes the new cert when it starts\n", node.name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("restart %s: %w", node.name, err)
+		}
+		if err := waitNode(node, "127.0.0.1", wait); err != nil {
+			return err
+		}
+	}
+	color.Green("certs of %d nodes are rotated", len(nodes))
+
+	return nil
+}
+
+// rotateRemoteNodes uploads the certs of every deployed node and then restarts them one by one
+func rotateRemoteNodes(cluster *inventory.BitXHub, nodes []*rotatedNode, noRestart bool, wait time.Duration) error {
+	hosts := make(map[string]*inventory.Host)
+	for _, h := range cluster.Nodes {
+		hosts[h.Name] = h
+	}
+
+	color.Blue("======> Distribute certs")
+	var deployed []*rotatedNode
+	for _, node := range nodes {
+		h, ok := hosts[node.name]
+		if !ok {
+			fmt.Printf("node %s is not deployed, skip it\n", node.name)
+			continue
+		}
+		certDir := filepath.Join(node.root, types.TlsCerts)
+		for _, name := range []string{repo.GetCertPath(pki.RoleNode, certDir), repo.GetCertPath(repo.AgencyName, certDir), repo.GetCACertPath(certDir)} {
+			if err := sh.Command("scp", name, h.SCPTarget(types.TlsCerts+"/")).Run(); err != nil {
+				return fmt.Errorf("upload %s to %s: %w", filepath.Base(name), node.name, err)
+			}
+		}
+		fmt.Printf("certs of %s are uploaded to %s\n", node.name, h.Host)
+		deployed = append(deployed, node)
+	}
+	if noRestart {
+		return nil
+	}
+
+	color.Blue("======> Restart nodes one at a time")
+	for _, node := range deployed {
+		h := hosts[node.name]
+		// the node is started in the parent directory of its repo, the way deploy starts it
+		script := fmt.Sprintf(`PID=$(cat $HOME/%s 2>/dev/null); if [ -n "$PID" ] && kill $PID 2>/dev/null; then while kill -0 $PID 2>/dev/null; do sleep 1; done; fi; `+
+			`cd $HOME/%s && export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:$HOME/%s && (nohup ./bitxhub --repo=%s start >/dev/null 2>&1 & echo $! > $HOME/%s)`,
+			h.PidFile, filepath.Dir(h.Repo), filepath.Dir(h.Repo), filepath.Base(h.Repo), h.PidFile)
+		if err := sh.Command("ssh", h.Who(), script).Run(); err != nil {
+			return fmt.Errorf("restart %s at %s: %w", node.name, h.Host, err)
+		}
+		fmt.Printf("node %s at %s is restarted\n", node.name, h.Host)
+		if err := waitNode(node, h.Host, wait); err != nil {
+			return err
+		}
+	}
+	color.Green("certs of %d nodes are rotated", len(deployed))
+
+	return nil
+}
+
+// waitNode waits until the restarted node listens on its grpc port, so that the next node is restarted
+// only when the cluster has its quorum again
+func waitNode(node *rotatedNode, host string, wait time.Duration) error {
+	tree, err := toml.LoadFile(filepath.Join(node.root, repo.BitXHubConfigName))
+	if err != nil {
+		return fmt.Errorf("load bitxhub config of %s: %w", node.name, err)
+	}
+	grpc, ok := tree.Get("port.grpc").(int64)
+	if !ok {
+		return fmt.Errorf("no grpc port in bitxhub config of %s", node.name)
+	}
+
+	if err := ports.WaitListening(host, int(grpc), wait); err != nil {
+		return fmt.Errorf("node %s does not come back, rotation stops and the remaining nodes keep their old certs: %w", node.name, err)
+	}
+	fmt.Printf("node %s is listening on %d\n", node.name, grpc)
+
+	return nil
+}
@@ -1,19 +1,12 @@
 package main
 
 import (
-	"bufio"
-	"crypto/ecdsa"
 	"crypto/elliptic"
-	"crypto/rand"
-	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"math/big"
-	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -28,12 +21,19 @@ import (
 	"github.com/meshplus/bitxhub-kit/crypto"
 	"github.com/meshplus/bitxhub-kit/crypto/asym"
 	"github.com/meshplus/bitxhub-kit/fileutil"
-	"github.com/meshplus/bitxhub/pkg/cert"
 	libp2pcert "github.com/meshplus/go-libp2p-cert"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/hdkey"
+	"github.com/meshplus/goduck/internal/hosts"
+	"github.com/meshplus/goduck/internal/orchestrator"
+	"github.com/meshplus/goduck/internal/pki"
+	"github.com/meshplus/goduck/internal/ports"
 	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/tomledit"
 	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
 	"github.com/pelletier/go-toml"
-	"github.com/spf13/viper"
 )
 
 var (
@@ -93,6 +93,7 @@ type ConfigGenerator interface {
 type Genesis struct {
 	Admins   []*Admin          `json:"admins" toml:"admins"`
 	Strategy map[string]string `json:"strategy" toml:"strategy"`
+	Balance  string            `json:"balance,omitempty" toml:"balance,omitempty"`
 }
 
 type Admin struct {
@@ -101,13 +102,22 @@ type Admin struct {
 }
 
 type BitXHubConfigGenerator struct {
-	typ     string
-	mode    string
-	target  string
-	num     int
-	ips     []string
-	tls     bool
-	version string
+	typ  string
+	mode string
+	// consensus is the consensus plugin of nodes, the default of mode if it is empty
+	consensus string
+	target    string
+	num       int
+	ips       []string
+	subnet    string
+	tls       bool
+	version   string
+	// manifest is the path of the port manifest, assigned ports are not recorded if it is empty
+	manifest string
+	genesis  *GenesisSpec
+	// keys derives the keys of the CA, nodes and accounts, they are random if it is nil
+	keys    *hdkey.Deriver
+	release *versions.BitXHub
 }
 
 type PierConfigGenerator struct {
@@ -134,13 +144,16 @@ type PierConfigGenerator struct {
 	pierPath             string
 	cryptoPath           string
 	method               string
+	// keys derives the keys of the pier, they are generated by pier if it is nil
+	keys    *hdkey.Deriver
+	release *versions.Pier
 }
 
-func NewBitXHubConfigGenerator(typ string, mode string, target string, num int, ips []string, tls bool, version string) *BitXHubConfigGenerator {
-	return &BitXHubConfigGenerator{typ: typ, mode: mode, target: target, num: num, ips: ips, tls: tls, version: version}
+func NewBitXHubConfigGenerator(typ string, mode string, consensus string, target string, num int, ips []string, subnet string, tls bool, version string, manifest string, genesis *GenesisSpec, seed string) *BitXHubConfigGenerator {
+	return &BitXHubConfigGenerator{typ: typ, mode: mode, consensus: consensus, target: target, num: num, ips: ips, subnet: subnet, tls: tls, version: version, manifest: manifest, genesis: genesis, keys: hdkey.New(seed).Child(types.BitXHub)}
 }
 
-func NewPierConfigGenerator(mode, startType, bitxhub string, validators []string, port string, peers, connectors []string, providers, appchainType, appchainIP, appchainAddr string, appPorts []string, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method string) *PierConfigGenerator {
+func NewPierConfigGenerator(mode, startType, bitxhub string, validators []string, port string, peers, connectors []string, providers, appchainType, appchainIP, appchainAddr string, appPorts []string, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method, seed string) *PierConfigGenerator {
 	return &PierConfigGenerator{
 		mode:                 mode,
 		startType:            startType,
@@ -164,6 +177,7 @@ func NewPierConfigGenerator(mode, startType, bitxhub string, validators []string
 		pierPath:             pierPath,
 		cryptoPath:           cryptoPath,
 		method:               method,
+		keys:                 hdkey.New(seed).Child(types.Pier, appchainType),
 	}
 }
 
@@ -208,6 +222,17 @@ func (b *BitXHubConfigGenerator) CleanOldConfig() error {
 		return fmt.Errorf("remove agency certificate: %w", err)
 	}
 
+	// the index and CRLs of the old CA
+	crls, err := filepath.Glob(filepath.Join(b.target, "*.crl"))
+	if err != nil {
+		return err
+	}
+	for _, p := range append(crls, filepath.Join(b.target, pki.IndexName)) {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", filepath.Base(p), err)
+		}
+	}
+
 	for i := 1; ; i++ {
 		nodeDir := filepath.Join(b.target, "node"+strconv.Itoa(i))
 		exist, err := removeDir(nodeDir)
@@ -239,11 +264,11 @@ func (b *BitXHubConfigGenerator) InitConfig() error {
 		return fmt.Errorf("check if BitXHub is initialized: %w", err)
 	} else if ok {
 		fmt.Println("BitXHub configuration file already exists")
-		fmt.Println("reinitializing would overwrite your configuration, Y/N (default: N)?")
-		input := bufio.NewScanner(os.Stdin)
-		input.Scan()
-
-		if input.Text() != "Y" && input.Text() != "y" {
+		overwrite, err := utils.Confirm("reinitializing would overwrite your configuration")
+		if err != nil {
+			return err
+		}
+		if !overwrite {
 			return nil
 		}
 
@@ -258,29 +283,47 @@ func (b *BitXHubConfigGenerator) InitConfig() error {
 		}
 	}
 
-	// generate ca for bitxhub, return path of ca.priv and ca.cert
-	caPrivKey, caCertPath, err := generateCA(b.target)
+	// generate ca and agency for bitxhub, which issue the node certs
+	ca, err := pki.Init(b.target, "Hyperchain", 0, b.keys)
 	if err != nil {
 		return fmt.Errorf("generate CA: %w", err)
 	}
 
-	// generate cert for bitxhub base ca.priv and ca.cert, return path of agency.priv and agency.cert
-	agencyPrivKey, agencyCertPath, err := generateCert(repo.AgencyName, strings.ToUpper(repo.AgencyName), b.target,
-		caPrivKey, caCertPath, true)
-	if err != nil {
-		return fmt.Errorf("generate agency cert: %w", err)
+	manifest := &ports.Manifest{}
+	if b.manifest != "" {
+		if manifest, err = ports.Load(b.manifest); err != nil {
+			return err
+		}
 	}
+	manifest.Release(b.target)
 
-	addrs, nodes, err := b.generateNodesConfig(b.target, b.mode, agencyPrivKey, agencyCertPath, b.ips)
+	addrs, nodes, err := b.generateNodesConfig(b.target, b.mode, ca, b.ips, ports.NewAllocator(manifest))
 
 	if err != nil {
 		return fmt.Errorf("generate nodes config: %w", err)
 	}
 
-	if err := writeNetworkAndGenesis(b.target, b.mode, addrs, nodes, b.version); err != nil {
+	var genesis *Genesis
+	if b.release.GenesisFormat() == versions.GenesisAdmins {
+		repoRoot, err := repo.PathRoot()
+		if err != nil {
+			return err
+		}
+		if genesis, err = b.genesis.Build(repoRoot, addrs); err != nil {
+			return fmt.Errorf("build genesis: %w", err)
+		}
+	}
+
+	if err := writeNetworkAndGenesis(b.target, b.mode, addrs, nodes, b.release, genesis); err != nil {
 		return fmt.Errorf("write network and genesis config: %w", err)
 	}
 
+	if b.manifest != "" {
+		if err := manifest.Save(b.manifest); err != nil {
+			return fmt.Errorf("save port manifest: %w", err)
+		}
+	}
+
 	fmt.Printf("%d BitXHub nodes at %s are initialized successfully\n", b.num, b.target)
 
 	return nil
@@ -288,6 +331,12 @@ func (b *BitXHubConfigGenerator) InitConfig() error {
 
 // ProcessParams check parameter correctness
 func (b *BitXHubConfigGenerator) ProcessParams() error {
+	release, err := versions.BitXHubOf(b.version)
+	if err != nil {
+		return err
+	}
+	b.release = release
+
 	if b.mode == types.SoloMode {
 		b.num = 1
 	}
@@ -304,30 +353,35 @@ func (b *BitXHubConfigGenerator) ProcessParams() error {
 		return fmt.Errorf("invalid mode, choose one of solo or cluster")
 	}
 
-	if b.typ == types.TypeDocker && b.mode == types.ClusterMode && b.num != 4 {
-		return fmt.Errorf("docker type supports 4 nodes only")
+	if b.release.GenesisFormat() != versions.GenesisAdmins && !b.genesis.IsDefault() {
+		return fmt.Errorf("genesis admins and strategies are only supported since BitXHub v1.6.0")
 	}
 
 	if len(b.ips) != 0 && len(b.ips) != b.num {
 		return fmt.Errorf("IPs' number is not equal to nodes' number")
 	}
 
-	if len(b.ips) == 0 && b.num >= 10 {
-		return fmt.Errorf("can not create more than 10 nodes with one IP address")
-	}
+	dockerCluster := b.typ == types.TypeDocker && b.mode == types.ClusterMode
 
-	if err := checkIPs(b.ips); err != nil {
+	if err := hosts.CheckAll(b.ips); err != nil {
 		return err
 	}
 
 	if len(b.ips) == 0 {
-		if b.typ == types.TypeBinary || b.mode == types.SoloMode {
+		if !dockerCluster {
 			for i := 0; i < b.num; i++ {
 				b.ips = append(b.ips, "127.0.0.1")
 			}
 		} else {
+			if b.subnet == "" {
+				b.subnet = bitxhub.DefaultSubnet
+			}
+			// the first address of the subnet is the gateway
 			for i := 2; i < b.num+2; i++ {
-				ip := fmt.Sprintf("172.19.0.%d", i)
+				ip, err := orchestrator.SubnetIP(b.subnet, i)
+				if err != nil {
+					return err
+				}
 				b.ips = append(b.ips, ip)
 			}
 		}
@@ -337,6 +391,13 @@ func (b *BitXHubConfigGenerator) ProcessParams() error {
 		return fmt.Errorf("there are at least 3 nodes in cluster mode")
 	}
 
+	if b.consensus == "" {
+		b.consensus = versions.DefaultConsensus(b.mode)
+	}
+	if err := checkConsensus(b.release, b.mode, b.consensus, b.num); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -373,7 +434,11 @@ func (p *PierConfigGenerator) copyConfigFiles() error {
 		for _, v := range p.peers {
 			peers += "\"" + v + "\",\n"
 		}
-		peers += "\"" + fmt.Sprintf("/ip4/%s/tcp/%s/p2p/%s", localIP, p.port, p.id) + "\",\n"
+		port, err := strconv.Atoi(p.port)
+		if err != nil {
+			return fmt.Errorf("invalid pier port %s: %w", p.port, err)
+		}
+		peers += "\"" + hosts.P2PAddr(localIP, port, p.id) + "\",\n"
 	} else if p.mode == types.PierModeUnion {
 		for _, v := range p.connectors {
 			connectors += "\"" + v + "\",\n"
@@ -384,14 +449,7 @@ func (p *PierConfigGenerator) copyConfigFiles() error {
 
 	pluginConfig := p.appchainType
 
-	pluginFile := "appchain_plugin"
-	if p.version == "v1.0.0" || p.version == "v1.0.0-rc1" {
-		if p.appchainType == types.ChainTypeEther {
-			pluginFile = "eth-client.so"
-		} else if p.appchainType == types.ChainTypeFabric {
-			pluginFile = "fabric-client-1.4.so"
-		}
-	}
+	pluginFile := p.release.PluginFile(p.appchainType)
 
 	data := struct {
 		Mode         string
@@ -413,7 +471,7 @@ func (p *PierConfigGenerator) copyConfigFiles() error {
 		filepath.Join("pier", "api"),
 		filepath.Join("pier", "pier.toml"),
 	}
-	if p.version >= "v1.8.0" {
+	if p.release.HasAdminKey() {
 		files = append(files, filepath.Join("pier", "admin.json"))
 	}
 	if err := renderConfigFile(p.target, files, data); err != nil {
@@ -543,15 +601,12 @@ func (p *PierConfigGenerator) InitConfig() error {
 		return fmt.Errorf("check if Pier is initialized: %w", err)
 	} else if ok {
 		fmt.Println("Pier configuration file already exists")
-		fmt.Println("reinitializing would overwrite your configuration, Y/N?")
-
-		inputReader := bufio.NewReader(os.Stdin)
-		input, err := inputReader.ReadString('\n')
+		overwrite, err := utils.Confirm("reinitializing would overwrite your configuration")
 		if err != nil {
-			return fmt.Errorf("read response: %w", err)
+			return err
 		}
 
-		if input[0] != 'Y' && input[0] != 'y' {
+		if !overwrite {
 			color.Blue("[N] The selection is 'No', so it will work with the existing configuration files.\n")
 			return nil
 		}
@@ -573,9 +628,15 @@ func (p *PierConfigGenerator) InitConfig() error {
 	// Since the PIER binaries used for remote deployment may not be able to run
 	// locally, the step of generatePierKeyAndID is skipped here and it will be
 	// done remotely through the SSH command in deploy.go.
-	if p.pierPath != "" {
+	if p.keys != nil {
+		id, err := writeSeededPierKeys(p.target, p.keys, p.release.HasNodeKey())
+		if err != nil {
+			return fmt.Errorf("generate Pier's private key and id: %w", err)
+		}
+		p.id = id
+	} else if p.pierPath != "" {
 		keys := []string{repo.KeyName}
-		if p.version >= "v1.4.0" {
+		if p.release.HasNodeKey() {
 			keys = append(keys, repo.NodeKeyName)
 		}
 		id, err := generatePierKeyAndID(p.target, p.pierPath, keys)
@@ -597,15 +658,14 @@ func (p *PierConfigGenerator) InitConfig() error {
 }
 
 func (p *PierConfigGenerator) ProcessParams() error {
-	if p.mode != types.PierModeDirect && p.mode != types.PierModeRelay {
-		if p.version < "v1.4.0" {
-			return fmt.Errorf("invalid mode, choose one of direct or relay")
-		} else {
-			if p.mode != types.PierModeUnion {
-				return fmt.Errorf("invalid mode, choose one of direct, relay or union")
-			}
-		}
+	release, err := versions.PierOf(p.version)
+	if err != nil {
+		return err
+	}
+	p.release = release
 
+	if !p.release.SupportsMode(p.mode) {
+		return fmt.Errorf("invalid mode, choose one of %s", strings.Join(p.release.Modes(), ", "))
 	}
 
 	if p.mode == types.PierModeRelay && p.bitxhub == "" {
@@ -628,24 +688,24 @@ func (p *PierConfigGenerator) ProcessParams() error {
 		return fmt.Errorf("invalid appchain type(%s), choose one of ethereum or fabric", p.appchainType)
 	}
 
-	if err := checkIPs([]string{p.appchainIP}); err != nil {
+	if err := hosts.Check(p.appchainIP); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func InitBitXHubConfig(typ, mode, target string, num int, ips []string, tls bool, version string) error {
-	bcg := NewBitXHubConfigGenerator(typ, mode, target, num, ips, tls, version)
+func InitBitXHubConfig(typ, mode, consensus, target string, num int, ips []string, subnet string, tls bool, version, manifest string, genesis *GenesisSpec, seed string) error {
+	bcg := NewBitXHubConfigGenerator(typ, mode, consensus, target, num, ips, subnet, tls, version, manifest, genesis, seed)
 	return bcg.InitConfig()
 }
 
-func InitPierConfig(mode, startType, bitxhub string, validators []string, port string, peers, connectors []string, providers, appchainType, appchainIP, appchainAddr string, appPorts []string, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method string) error {
-	pcg := NewPierConfigGenerator(mode, startType, bitxhub, validators, port, peers, connectors, providers, appchainType, appchainIP, appchainAddr, appPorts, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method)
+func InitPierConfig(mode, startType, bitxhub string, validators []string, port string, peers, connectors []string, providers, appchainType, appchainIP, appchainAddr string, appPorts []string, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method, seed string) error {
+	pcg := NewPierConfigGenerator(mode, startType, bitxhub, validators, port, peers, connectors, providers, appchainType, appchainIP, appchainAddr, appPorts, appchainContractAddr, target, tls, httpPort, pprofPort, apiPort, version, pierPath, cryptoPath, method, seed)
 	return pcg.InitConfig()
 }
 
-func (b *BitXHubConfigGenerator) generateNodesConfig(repoRoot, mode, agencyPrivKey, agencyCertPath string, ips []string) ([]string, []*NetworkNodes, error) {
+func (b *BitXHubConfigGenerator) generateNodesConfig(repoRoot, mode string, ca *pki.Authority, ips []string, allocator *ports.Allocator) ([]string, []*NetworkNodes, error) {
 	count := len(ips)
 	ipToId := make(map[string]int)
 	addrs := make([]string, 0, count)
@@ -655,7 +715,7 @@ func (b *BitXHubConfigGenerator) generateNodesConfig(repoRoot, mode, agencyPrivK
 		ip := ips[i-1]
 		ipToId[ip]++
 
-		addr, node, err := b.generateNodeConfig(repoRoot, mode, agencyPrivKey, agencyCertPath, ip, i, ipToId)
+		addr, node, err := b.generateNodeConfig(repoRoot, mode, ca, ip, i, ipToId, allocator)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -667,7 +727,7 @@ func (b *BitXHubConfigGenerator) generateNodesConfig(repoRoot, mode, agencyPrivK
 	return addrs, nodes, nil
 }
 
-func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKey, agencyCertPath, ip string, id int, ipToId map[string]int) (string, *NetworkNodes, error) {
+func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode string, ca *pki.Authority, ip string, id int, ipToId map[string]int, allocator *ports.Allocator) (string, *NetworkNodes, error) {
 	name := "node"
 	addrKeyName := name
 	org := "Node" + strconv.Itoa(id)
@@ -683,28 +743,22 @@ func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKe
 		return "", nil, err
 	}
 
-	// generate node.priv and node.cert
-	if _, _, err := generateCert(name, org, certRoot, agencyPrivKey, agencyCertPath, false); err != nil {
+	// generate node.priv and node.cert, with ca.cert and agency.cert
+	if _, err := ca.Issue(&pki.Request{Name: filepath.Base(nodeRoot), Role: pki.RoleNode, Org: org, Dir: certRoot}); err != nil {
 		return "", nil, fmt.Errorf("generate node cert: %w", err)
 	}
 
-	// copy ca.cert
-	if err := copyFile(repo.GetCACertPath(certRoot), repo.GetCACertPath(repoRoot)); err != nil {
-		return "", nil, fmt.Errorf("copy ca cert: %w", err)
-	}
-
-	// copy agency.cert
-	if err := copyFile(repo.GetCertPath("agency", certRoot), agencyCertPath); err != nil {
-		return "", nil, fmt.Errorf("copy agency cert: %w", err)
-	}
-
 	// generate key.pri ===============================================
 	cryptoOpt := crypto.Secp256k1
-	if b.version < "v1.4.0" {
+	if !b.release.HasAccountKey() {
 		cryptoOpt = crypto.ECDSA_P521
 	}
-	if b.version >= "v1.4.0" {
-		if err := generatePrivKey(repo.KeyPriv, certRoot, crypto.Secp256k1); err != nil {
+	if b.release.HasAccountKey() {
+		account, err := b.keys.Key(crypto.Secp256k1, "account", filepath.Base(nodeRoot))
+		if err != nil {
+			return "", nil, fmt.Errorf("generate priv key: %w", err)
+		}
+		if err := writePrivKey(repo.KeyPriv, certRoot, account); err != nil {
 			return "", nil, fmt.Errorf("generate priv key: %w", err)
 		}
 		addrKeyName = "key"
@@ -728,12 +782,26 @@ func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKe
 		return "", nil, err
 	}
 
-	if err := asym.StorePrivateKey(privKey, keyPath, "bitxhub"); err != nil {
+	// BitXHub nodes decrypt key.json with the default password when they start
+	if err := asym.StorePrivateKey(privKey, keyPath, repo.KeyPassword); err != nil {
 		return "", nil, err
 	}
 
+	// allocate ports ================================================
+	// ports are allocated on the first host of the node,
+	// docker nodes publish their ports on this host, so they are allocated as local ones
+	nodeHosts := hosts.Split(ip)
+	host, check := nodeHosts[0], ports.IsLocal(nodeHosts[0])
+	if b.typ == types.TypeDocker {
+		host, check = "", true
+	}
+	nodePorts, err := allocator.Allocate(nodeRoot, host, check)
+	if err != nil {
+		return "", nil, fmt.Errorf("allocate ports for node %d: %w", id, err)
+	}
+
 	// generate bitxhub.toml, order.toml, api... ======================
-	if err := b.copyConfigFiles(nodeRoot, ipToId[ip]); err != nil {
+	if err := b.copyConfigFiles(nodeRoot, ipToId[ip], nodePorts); err != nil {
 		return "", nil, fmt.Errorf("initialize configuration for node %d: %w", id, err)
 	}
 
@@ -754,43 +822,105 @@ func (b *BitXHubConfigGenerator) generateNodeConfig(repoRoot, mode, agencyPrivKe
 	node := &NetworkNodes{
 		ID:      uint64(id),
 		Pid:     pid,
-		Hosts:   []string{fmt.Sprintf("/ip4/%s/tcp/%d/p2p/", ip, 4000+ipToId[ip])},
 		Account: addr,
 	}
+	for _, h := range nodeHosts {
+		node.Hosts = append(node.Hosts, hosts.P2PAddr(h, nodePorts.P2P, ""))
+	}
 
 	return addr, node, nil
 }
 
-func (b *BitXHubConfigGenerator) copyConfigFiles(nodeRoot string, id int) error {
-	consensus := types.SoloMode
-	if b.mode == "cluster" {
-		consensus = "raft"
+func (b *BitXHubConfigGenerator) copyConfigFiles(nodeRoot string, id int, p *ports.NodePorts) error {
+	data := struct {
+		Id          int
+		Solo        bool
+		Consensus   string
+		Tls         bool
+		JsonRpcPort int
+		GrpcPort    int
+		GatewayPort int
+		P2PPort     int
+		PprofPort   int
+		MonitorPort int
+	}{id, b.mode == "solo", b.consensus, b.tls, p.JsonRpc, p.Grpc, p.Gateway, p.P2P, p.Pprof, p.Monitor}
+
+	files := []string{"bitxhub.toml", "order.toml", "api"}
+
+	if err := renderConfigFiles(nodeRoot, b.release.ConfigTemplates(), files, data); err != nil {
+		return err
 	}
 
-	data := struct {
-		Id        int
-		Solo      bool
-		Consensus string
-		Tls       bool
-	}{id, b.mode == "solo", consensus, b.tls}
+	if err := writeNodeConsensus(nodeRoot, b.consensus); err != nil {
+		return err
+	}
 
-	files := []string{"bitxhub.toml", "api"}
+	return writeNodePorts(nodeRoot, p)
+}
 
-	srcPath := "bitxhub"
+// writeNodeConsensus points the order plugin of bitxhub.toml to the consensus
+func writeNodeConsensus(nodeRoot, consensus string) error {
+	path := filepath.Join(nodeRoot, repo.BitXHubConfigName)
+	doc, err := tomledit.Load(path)
+	if err != nil {
+		return err
+	}
 
-	if b.version < "v1.4.0" {
-		srcPath = fmt.Sprintf("%s/v1.1.0", srcPath)
-	} else if b.version < "v1.6.0" {
-		srcPath = fmt.Sprintf("%s/v1.4.0", srcPath)
-	} else {
-		srcPath = fmt.Sprintf("%s/v1.6.0", srcPath)
+	if _, ok := doc.Get("order.plugin"); ok {
+		if err := doc.Set("order.plugin", filepath.Join("plugins", consensus+".so")); err != nil {
+			return err
+		}
+	}
+	if _, ok := doc.Get("order.type"); ok {
+		if err := doc.Set("order.type", consensus); err != nil {
+			return err
+		}
+	}
+
+	return doc.Save(path)
+}
+
+// checkConsensus checks the release ships the consensus and the consensus runs with nodes in mode
+func checkConsensus(release *versions.BitXHub, mode, consensus string, num int) error {
+	c, err := release.LookupConsensus(consensus)
+	if err != nil {
+		return err
+	}
+	if mode == types.SoloMode && c.Name != "solo" || mode == types.ClusterMode && c.Name == "solo" {
+		return fmt.Errorf("consensus %s can not be used in %s mode", c.Name, mode)
+	}
+
+	return c.CheckNodes(num)
+}
+
+// writeNodePorts sets the ports in bitxhub.toml of the node to the allocated ones,
+// ports which are not in the configuration of the version are left out
+func writeNodePorts(nodeRoot string, p *ports.NodePorts) error {
+	path := filepath.Join(nodeRoot, repo.BitXHubConfigName)
+	doc, err := tomledit.Load(path)
+	if err != nil {
+		return err
+	}
+
+	for key, port := range map[string]int{
+		"port.jsonrpc": p.JsonRpc,
+		"port.grpc":    p.Grpc,
+		"port.gateway": p.Gateway,
+		"port.pprof":   p.Pprof,
+		"port.monitor": p.Monitor,
+	} {
+		if _, ok := doc.Get(key); ok {
+			if err := doc.Set(key, int64(port)); err != nil {
+				return err
+			}
+		}
 	}
 
-	return renderConfigFiles(nodeRoot, srcPath, files, data)
+	return doc.Save(path)
 }
 
 // write network and genesis info for BitXHub
-func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*NetworkNodes, version string) error {
+func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*NetworkNodes, release *versions.BitXHub, adminGenesis *Genesis) error {
 	genesis := Genesis1_1_0{Addresses: addrs}
 	content, err := json.MarshalIndent(genesis, "", " ")
 	if err != nil {
@@ -805,7 +935,7 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 			nodeRoot = filepath.Join(repoRoot, "nodeSolo")
 		}
 
-		if version == "v1.1.0-rc1" {
+		if release.NetworkFormat() == versions.NetworkReadin {
 			var addrs [][]string
 			for _, node := range nodes {
 				addrs = append(addrs, []string{fmt.Sprintf("%s%s", node.Hosts[0], node.Pid)})
@@ -825,7 +955,7 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 			}
 
 			continue
-		} else if version < "v1.1.0-rc1" {
+		} else if release.NetworkFormat() == versions.NetworkAddrs {
 			nodes1_0_0 := make([]*NetworkNodes1_0_0, 0, len(nodes))
 
 			for _, n := range nodes {
@@ -876,31 +1006,12 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 		}
 
 		// bitxhub.toml
-		if version < "v1.6.0" { // v1.4.0 v1.5.0
-			genesis1 := &Genesis1_1_0{}
-			for _, addr := range addrs {
-				genesis1.Addresses = append(genesis1.Addresses, addr)
-			}
-
-			if err = ModifyConfig(nodeRoot, "genesis", genesis1); err != nil {
+		if release.GenesisFormat() == versions.GenesisAddresses {
+			if err = ModifyConfig(nodeRoot, "genesis.addresses", addrs); err != nil {
 				return fmt.Errorf("modify bitxhub.toml error: %w", err)
 			}
-		} else { // >= v1.6.0
-			genesis2 := &Genesis{
-				Strategy: map[string]string{
-					"AppchainMgr": "SimpleMajority",
-					"RuleMgr":     "SimpleMajority",
-					"NodeMgr":     "SimpleMajority",
-					"ServiceMgr":  "SimpleMajority"},
-			}
-			for _, addr := range addrs {
-				genesis2.Admins = append(genesis2.Admins, &Admin{
-					Address: addr,
-					Weight:  uint64(1),
-				})
-			}
-
-			if err = ModifyConfig(nodeRoot, "genesis", genesis2); err != nil {
+		} else {
+			if err = writeGenesis(nodeRoot, adminGenesis); err != nil {
 				return fmt.Errorf("modify bitxhub.toml error: %w", err)
 			}
 		}
@@ -909,26 +1020,6 @@ func writeNetworkAndGenesis(repoRoot, mode string, addrs []string, nodes []*Netw
 	return nil
 }
 
-func generateCert(name string, org string, target string, privKey string, caCertPath string, isCA bool) (string, string, error) {
-	if err := generatePrivKey(name, target, crypto.ECDSA_P256); err != nil {
-		return "", "", fmt.Errorf("generate private key: %w", err)
-	}
-
-	if err := generateCSR(org, repo.GetPrivKeyPath(name, target), target); err != nil {
-		return "", "", fmt.Errorf("generate csr: %w", err)
-	}
-
-	if err := issueCert(repo.GetCSRPath(name, target), privKey, caCertPath, target, isCA); err != nil {
-		return "", "", fmt.Errorf("issue cert: %w", err)
-	}
-
-	if err := os.Remove(repo.GetCSRPath(name, target)); err != nil {
-		return "", "", fmt.Errorf("remove csr: %w", err)
-	}
-
-	return repo.GetPrivKeyPath(name, target), repo.GetCertPath(name, target), nil
-}
-
 func copyFile(dstFile, srcFile string) error {
 	src, err := os.Open(srcFile)
 	if err != nil {
@@ -946,16 +1037,6 @@ func copyFile(dstFile, srcFile string) error {
 	return err
 }
 
-func checkIPs(ips []string) error {
-	for _, ip := range ips {
-		parsedIP := net.ParseIP(ip)
-		if parsedIP.To4() == nil {
-			return fmt.Errorf("%v is not an IPv4 address", parsedIP)
-		}
-	}
-	return nil
-}
-
 func removeDir(dir string) (bool, error) {
 	ok, err := existDir(dir)
 	if err != nil {
@@ -1020,181 +1101,40 @@ func generatePierKeyAndID(target, pierPath string, keys []string) (string, error
 	return pid, nil
 }
 
-// generate ca for bitxhub, return path of ca.priv and ca.cert
-func generateCA(dir string) (string, string, error) {
-	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return "", "", err
-	}
-
-	priKeyEncode, err := x509.MarshalECPrivateKey(privKey)
-	if err != nil {
-		return "", "", err
-	}
-
-	// create ca.priv
-	f, err := os.Create(repo.GetCAPrivKeyPath(dir))
-	if err != nil {
-		return "", "", err
-	}
-	defer f.Close()
-
-	err = pem.Encode(f, &pem.Block{Type: "EC PRIVATE KEY", Bytes: priKeyEncode})
-	if err != nil {
-		return "", "", err
-	}
-
-	c, err := cert.GenerateCert(privKey, true, "Hyperchain")
-	if err != nil {
-		return "", "", err
-	}
-
-	x509certEncode, err := x509.CreateCertificate(rand.Reader, c, c, privKey.Public(), privKey)
-	if err != nil {
-		return "", "", err
-	}
-
-	// create ca.cert
-	f, err = os.Create(repo.GetCACertPath(dir))
-	if err != nil {
-		return "", "", err
-	}
-	defer f.Close()
-
-	if err := pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: x509certEncode}); err != nil {
-		return "", "", err
-	}
-
-	return repo.GetCAPrivKeyPath(dir), repo.GetCACertPath(dir), nil
-}
-
-func generateCSR(org string, privPath string, target string) error {
-	privData, err := ioutil.ReadFile(privPath)
+// writeSeededPierKeys writes key.json and node.priv of the pier derived by keys into target and
+// returns the pid of node.priv, or of key.json if there is no node key
+func writeSeededPierKeys(target string, keys *hdkey.Deriver, nodeKey bool) (string, error) {
+	account, err := keys.Key(crypto.Secp256k1, "account")
 	if err != nil {
-		return err
-	}
-	block, _ := pem.Decode(privData)
-	privKey, err := x509.ParseECPrivateKey(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("parse private key: %w", err)
-	}
-
-	template := &x509.CertificateRequest{
-		Subject: pkix.Name{
-			Country:            []string{"CN"},
-			Locality:           []string{"HangZhou"},
-			Province:           []string{"ZheJiang"},
-			OrganizationalUnit: []string{"BitXHub"},
-			Organization:       []string{org},
-			StreetAddress:      []string{"street", "address"},
-			PostalCode:         []string{"324000"},
-			CommonName:         "BitXHub",
-		},
-	}
-	data, err := x509.CreateCertificateRequest(rand.Reader, template, privKey)
-	if err != nil {
-		return err
-	}
-
-	name := getFileName(privPath)
-
-	f, err := os.Create(repo.GetCSRPath(name, target))
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	return pem.Encode(f, &pem.Block{Type: "CSR", Bytes: data})
-}
-
-func issueCert(csrPath, privPath, certPath, target string, isCA bool) error {
-	privData, err := ioutil.ReadFile(privPath)
-	if err != nil {
-		return fmt.Errorf("read ca private key: %w", err)
+		return "", err
 	}
-	block, _ := pem.Decode(privData)
-	privKey, err := x509.ParseECPrivateKey(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("parse ca private key: %w", err)
-	}
-
-	caCertData, err := ioutil.ReadFile(certPath)
-	if err != nil {
-		return err
+	// piers decrypt key.json with the default password when they start
+	if err := asym.StorePrivateKey(account, filepath.Join(target, repo.KeyName), repo.KeyPassword); err != nil {
+		return "", err
 	}
-	block, _ = pem.Decode(caCertData)
-	caCert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("parse ca cert: %w", err)
+	if !nodeKey {
+		return keyPid(account)
 	}
 
-	crsData, err := ioutil.ReadFile(csrPath)
+	node, err := keys.ECDSA(elliptic.P256(), "node")
 	if err != nil {
-		return fmt.Errorf("read crs: %w", err)
+		return "", err
 	}
-
-	block, _ = pem.Decode(crsData)
-
-	crs, err := x509.ParseCertificateRequest(block.Bytes)
-	if err != nil {
-		return fmt.Errorf("parse csr: %w", err)
+	nodeKeyPath := filepath.Join(target, repo.NodeKeyName)
+	if err := pki.WriteKey(nodeKeyPath, node); err != nil {
+		return "", err
 	}
 
-	if err := crs.CheckSignature(); err != nil {
-		return fmt.Errorf("wrong csr sign: %w", err)
-	}
-
-	sn, err := rand.Int(rand.Reader, big.NewInt(1000000))
-	if err != nil {
-		return err
-	}
-
-	notBefore := time.Now().Add(-5 * time.Minute).UTC()
-	template := &x509.Certificate{
-		Signature:             crs.Signature,
-		SignatureAlgorithm:    crs.SignatureAlgorithm,
-		PublicKey:             crs.PublicKey,
-		PublicKeyAlgorithm:    crs.PublicKeyAlgorithm,
-		SerialNumber:          sn,
-		NotBefore:             notBefore,
-		NotAfter:              notBefore.Add(50 * 365 * 24 * time.Hour).UTC(),
-		BasicConstraintsValid: true,
-		IsCA:                  isCA,
-		Issuer:                caCert.Subject,
-		KeyUsage: x509.KeyUsageDigitalSignature |
-			x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign |
-			x509.KeyUsageCRLSign,
-		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
-		Subject:     crs.Subject,
-	}
-
-	x509certEncode, err := x509.CreateCertificate(rand.Reader, template, caCert, crs.PublicKey, privKey)
-	if err != nil {
-		return fmt.Errorf("create cert: %w", err)
-	}
-
-	name := getFileName(csrPath)
-
-	f, err := os.Create(repo.GetCertPath(name, target))
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	return pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: x509certEncode})
+	return getPidFromPrivateKey(nodeKeyPath)
 }
 
-func generatePrivKey(name, target string, opt crypto.KeyType) error {
+// writePrivKey writes the key as <name>.priv into target in pem
+func writePrivKey(name, target string, privKey crypto.PrivateKey) error {
 	target, err := filepath.Abs(target)
 	if err != nil {
 		return fmt.Errorf("get absolute key path: %w", err)
 	}
 
-	privKey, err := asym.GenerateKeyPair(opt)
-	if err != nil {
-		return fmt.Errorf("generate key: %w", err)
-	}
-
 	priKeyEncode, err := privKey.Bytes()
 	if err != nil {
 		return fmt.Errorf("marshal key: %w", err)
@@ -1220,17 +1160,6 @@ func generatePrivKey(name, target string, opt crypto.KeyType) error {
 	return nil
 }
 
-func getFileName(path string) string {
-	def := "default"
-	name := filepath.Base(path)
-	bs := strings.Split(name, ".")
-	if len(bs) != 2 {
-		return def
-	}
-
-	return bs[0]
-}
-
 func renderConfigFiles(dstDir, srcDir string, filesToRender []string, data interface{}) error {
 	filesM := make(map[string]struct{})
 	for _, file := range filesToRender {
@@ -1300,27 +1229,17 @@ func renderConfigFile(dstDir string, srcFiles []string, data interface{}) error
 	return nil
 }
 
+// ModifyConfig sets the key of bitxhub.toml in repoRoot, comments and order of the file are kept
 func ModifyConfig(repoRoot string, modifyKey string, modifyValue interface{}) error {
-	data, _ := json.Marshal(&modifyValue)
-	m := make(map[string]interface{})
-	if err := json.Unmarshal(data, &m); err != nil {
-		return fmt.Errorf("convert struct to map error: %w", err)
-	}
-
-	viper.SetConfigFile(filepath.Join(repoRoot, repo.BitXHubConfigName))
-	viper.SetConfigType("toml")
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("BITXHUB")
-	replacer := strings.NewReplacer(".", "_")
-	viper.SetEnvKeyReplacer(replacer)
-	if err := viper.ReadInConfig(); err != nil {
+	path := filepath.Join(repoRoot, repo.BitXHubConfigName)
+	doc, err := tomledit.Load(path)
+	if err != nil {
 		return err
 	}
 
-	viper.Set(modifyKey, m)
-	if err := viper.WriteConfigAs(filepath.Join(repoRoot, repo.BitXHubConfigName)); err != nil {
+	if err := doc.Set(modifyKey, modifyValue); err != nil {
 		return err
 	}
 
-	return nil
+	return doc.Save(path)
 }
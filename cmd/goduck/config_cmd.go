@@ -0,0 +1,283 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/tomledit"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+// pluginFile selects the appchain plugin config of pier by --file
+const pluginFile = "plugin"
+
+var configFileFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "file",
+		Usage: "Specify the config file relative to the node repo, `plugin` means the appchain plugin config of pier (default: bitxhub.toml or pier.toml)",
+	},
+	&cli.StringFlag{
+		Name:  "target",
+		Usage: "Specify the directory of node repos (default: $repo/bitxhub/.bitxhub or $repo/pier)",
+	},
+	&cli.StringFlag{
+		Name:  "version",
+		Usage: "Specify the component version to validate keys against (default: the running BitXHub version if any)",
+	},
+}
+
+func configCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "Get or modify config files of BitXHub nodes and piers in place",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "get",
+				Usage:     "Show the value of a config key",
+				ArgsUsage: "<bitxhub|pier> <node|appchain> <key.path>",
+				Flags:     configFileFlags,
+				Action:    configGet,
+			},
+			{
+				Name:      "set",
+				Usage:     "Set config keys, comments and order of the file are kept",
+				ArgsUsage: "<bitxhub|pier> <node|appchain> <key.path=value>...",
+				Flags: append([]cli.Flag{
+					&cli.BoolFlag{
+						Name:  "new",
+						Usage: "Allow to add keys which are unknown for the component version",
+					},
+				}, configFileFlags...),
+				Action: configSet,
+			},
+			{
+				Name:      "unset",
+				Usage:     "Remove config keys or tables",
+				ArgsUsage: "<bitxhub|pier> <node|appchain> <key.path>...",
+				Flags:     configFileFlags,
+				Action:    configUnset,
+			},
+			configValidateCMD(),
+		},
+	}
+}
+
+// configFile is a config file of a BitXHub node or a pier
+type configFile struct {
+	component string
+	path      string
+	// keys are the known keys of the file, nil for files without known keys
+	keys    []configKey
+	version *versions.Version
+}
+
+func configGet(ctx *cli.Context) error {
+	if ctx.NArg() != 3 {
+		return fmt.Errorf("expect <component> <node> <key.path>")
+	}
+	file, err := resolveConfigFile(ctx)
+	if err != nil {
+		return err
+	}
+	doc, err := tomledit.Load(file.path)
+	if err != nil {
+		return err
+	}
+
+	key := ctx.Args().Get(2)
+	value, ok := doc.Get(key)
+	if !ok {
+		return fmt.Errorf("key %s is not found in %s", key, file.path)
+	}
+
+	if tree, ok := value.(*toml.Tree); ok {
+		fmt.Print(tree.String())
+		return nil
+	}
+	text, err := tomledit.Format(value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(text)
+
+	return nil
+}
+
+func configSet(ctx *cli.Context) error {
+	if ctx.NArg() < 3 {
+		return fmt.Errorf("expect <component> <node> <key.path=value>...")
+	}
+	file, err := resolveConfigFile(ctx)
+	if err != nil {
+		return err
+	}
+	doc, err := tomledit.Load(file.path)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range ctx.Args().Slice()[2:] {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return fmt.Errorf("invalid assignment %s, expect key.path=value", arg)
+		}
+		key, raw := strings.TrimSpace(kv[0]), kv[1]
+
+		like, err := file.typeOf(doc, key, ctx.Bool("new"))
+		if err != nil {
+			return err
+		}
+		value, err := tomledit.ParseAs(raw, like)
+		if err != nil {
+			return fmt.Errorf("invalid value of %s: %w", key, err)
+		}
+		if err := doc.Set(key, value); err != nil {
+			return err
+		}
+	}
+
+	if err := doc.Save(file.path); err != nil {
+		return fmt.Errorf("save %s: %w", file.path, err)
+	}
+	color.Green("%s is updated", file.path)
+
+	return nil
+}
+
+func configUnset(ctx *cli.Context) error {
+	if ctx.NArg() < 3 {
+		return fmt.Errorf("expect <component> <node> <key.path>...")
+	}
+	file, err := resolveConfigFile(ctx)
+	if err != nil {
+		return err
+	}
+	doc, err := tomledit.Load(file.path)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range ctx.Args().Slice()[2:] {
+		if err := doc.Unset(key); err != nil {
+			return err
+		}
+	}
+
+	if err := doc.Save(file.path); err != nil {
+		return fmt.Errorf("save %s: %w", file.path, err)
+	}
+	color.Green("%s is updated", file.path)
+
+	return nil
+}
+
+// typeOf returns a value of the type the key takes, which is the type of the current value
+// or of the known key, nil means any type
+func (f *configFile) typeOf(doc *tomledit.Document, key string, allowNew bool) (interface{}, error) {
+	if value, ok := doc.Get(key); ok {
+		return value, nil
+	}
+
+	if f.keys != nil {
+		known, ok := lookupConfigKey(f.keys, key, f.version)
+		if ok {
+			return known.like, nil
+		}
+		if known != nil && !allowNew {
+			return nil, fmt.Errorf("key %s needs %s %s+, the target version is %s", key, f.component, known.since, f.version)
+		}
+	}
+
+	if !allowNew {
+		return nil, fmt.Errorf("unknown key %s of %s, use --new to add it anyway", key, filepath.Base(f.path))
+	}
+
+	return nil, nil
+}
+
+func resolveConfigFile(ctx *cli.Context) (*configFile, error) {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return nil, err
+	}
+
+	component, node := ctx.Args().Get(0), ctx.Args().Get(1)
+	file := &configFile{component: component}
+	target := ctx.String("target")
+	name := ctx.String("file")
+
+	var nodeRepo string
+	switch component {
+	case types.BitXHub:
+		if target == "" {
+			target = filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+		}
+		nodeRepo = filepath.Join(target, node)
+		if name == "" || name == repo.BitXHubConfigName {
+			name, file.keys = repo.BitXHubConfigName, bitxhubConfigKeys
+		}
+		version := ctx.String("version")
+		if version == "" {
+			version = runningBitXHubVersion(repoRoot)
+		}
+		if version != "" {
+			release, err := versions.BitXHubOf(version)
+			if err != nil {
+				return nil, err
+			}
+			file.version = &release.Version
+		}
+	case types.Pier:
+		if target == "" {
+			target = filepath.Join(repoRoot, types.Pier)
+		}
+		nodeRepo = filepath.Join(target, node)
+		if !fileutil.Exist(nodeRepo) {
+			nodeRepo = filepath.Join(target, ".pier_"+node)
+		}
+		switch name {
+		case "", repo.PierConfigName:
+			name, file.keys = repo.PierConfigName, pierConfigKeys
+		case pluginFile:
+			name = filepath.Join(node, node+".toml")
+		}
+		if version := ctx.String("version"); version != "" {
+			release, err := versions.PierOf(version)
+			if err != nil {
+				return nil, err
+			}
+			file.version = &release.Version
+		}
+	default:
+		return nil, fmt.Errorf("unsupported component %q, expect %s or %s", component, types.BitXHub, types.Pier)
+	}
+
+	if node == "" {
+		return nil, fmt.Errorf("node is required")
+	}
+	file.path = filepath.Join(nodeRepo, name)
+	if !fileutil.Exist(file.path) {
+		return nil, fmt.Errorf("config file %s is not found", file.path)
+	}
+
+	return file, nil
+}
+
+// runningBitXHubVersion returns the version of BitXHub nodes started by goduck, empty if none
+func runningBitXHubVersion(repoRoot string) string {
+	data, err := ioutil.ReadFile(bitxhub.VersionPath(repoRoot))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+}
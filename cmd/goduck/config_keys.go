@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/meshplus/goduck/internal/versions"
+)
+
+// configKey is a known key of a component config file, which can be added even if the file does not have it
+type configKey struct {
+	key string
+	// like is a value of the type of the key as decoded by go-toml
+	like interface{}
+	// since is the first version having the key
+	since string
+}
+
+var (
+	tomlString  = ""
+	tomlInt     = int64(0)
+	tomlBool    = false
+	tomlStrings = []interface{}{""}
+)
+
+// bitxhubConfigKeys are the known keys of bitxhub.toml
+var bitxhubConfigKeys = []configKey{
+	{"title", tomlString, ""},
+	{"solo", tomlBool, ""},
+	{"port.jsonrpc", tomlInt, "v1.7.0"},
+	{"port.grpc", tomlInt, ""},
+	{"port.gateway", tomlInt, ""},
+	{"port.pprof", tomlInt, ""},
+	{"port.monitor", tomlInt, ""},
+	{"pprof.enable", tomlBool, ""},
+	{"pprof.ptype", tomlString, ""},
+	{"pprof.mode", tomlString, ""},
+	{"pprof.duration", tomlString, ""},
+	{"monitor.enable", tomlBool, ""},
+	{"gateway.allowed_origins", tomlStrings, ""},
+	{"ping.enable", tomlBool, ""},
+	{"ping.duration", tomlString, ""},
+	{"security.enable_tls", tomlBool, ""},
+	{"security.pem_file_path", tomlString, ""},
+	{"security.server_name", tomlString, ""},
+	{"limiter.interval", tomlString, ""},
+	{"limiter.quantum", tomlInt, ""},
+	{"limiter.capacity", tomlInt, ""},
+	{"log.level", tomlString, ""},
+	{"log.dir", tomlString, ""},
+	{"log.filename", tomlString, ""},
+	{"log.report_caller", tomlBool, ""},
+	{"log.module.p2p", tomlString, ""},
+	{"log.module.consensus", tomlString, ""},
+	{"log.module.executor", tomlString, ""},
+	{"log.module.router", tomlString, ""},
+	{"log.module.api", tomlString, ""},
+	{"log.module.coreapi", tomlString, ""},
+	{"log.module.storage", tomlString, ""},
+	{"log.module.profile", tomlString, ""},
+	{"cert.verify", tomlBool, ""},
+	{"order.type", tomlString, ""},
+	{"order.plugin", tomlString, ""},
+	{"executor.type", tomlString, ""},
+	{"genesis.dider", tomlString, "v1.6.0"},
+	{"genesis.balance", tomlString, "v1.6.0"},
+}
+
+// pierConfigKeys are the known keys of pier.toml
+var pierConfigKeys = []configKey{
+	{"title", tomlString, ""},
+	{"port.http", tomlInt, ""},
+	{"port.pprof", tomlInt, ""},
+	{"log.level", tomlString, ""},
+	{"log.dir", tomlString, ""},
+	{"log.filename", tomlString, ""},
+	{"log.report_caller", tomlBool, ""},
+	{"mode.type", tomlString, ""},
+	{"mode.relay.addrs", tomlStrings, ""},
+	{"mode.relay.timeout_limit", tomlString, ""},
+	{"mode.relay.quorum", tomlInt, ""},
+	{"mode.relay.validators", tomlStrings, ""},
+	{"mode.union.addrs", tomlStrings, "v1.4.0"},
+	{"mode.union.providers", tomlInt, "v1.4.0"},
+	{"security.enable_tls", tomlBool, ""},
+	{"security.tlsca", tomlString, ""},
+	{"security.common_name", tomlString, ""},
+	{"HA.mode", tomlString, ""},
+	{"appchain.plugin", tomlString, ""},
+	{"appchain.config", tomlString, ""},
+}
+
+// lookupConfigKey returns the known key of the version, version may be nil if it is unknown
+func lookupConfigKey(keys []configKey, key string, version *versions.Version) (*configKey, bool) {
+	for i := range keys {
+		if keys[i].key != key {
+			continue
+		}
+		if version != nil && keys[i].since != "" && version.Before(keys[i].since) {
+			return &keys[i], false
+		}
+		return &keys[i], true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+)
+
+// nodeCerts are the certs every BitXHub node has under certs/
+var nodeCerts = []string{"ca.cert", "agency.cert", "node.cert", "node.priv"}
+
+// ConfigIssue is a problem found in generated configs
+type ConfigIssue struct {
+	Severity  string `json:"severity"`
+	Component string `json:"component"`
+	Node      string `json:"node"`
+	File      string `json:"file,omitempty"`
+	Message   string `json:"message"`
+}
+
+// configValidator collects issues of the BitXHub nodes and piers of a repo
+type configValidator struct {
+	release *versions.BitXHub
+	issues  []*ConfigIssue
+	nodes   []*validatedNode
+	// ports maps host:port to the node using it
+	ports map[string]string
+}
+
+// validatedNode is what a BitXHub node really is, as read from its keys and configs
+type validatedNode struct {
+	name    string
+	id      uint64
+	pid     string
+	account string
+	host    string
+	grpc    int64
+	network *NetworkConfig
+	admins  []string
+}
+
+func configValidateCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "validate",
+		Usage: "Check that configs of BitXHub nodes and piers are consistent before starting them",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "bitxhub-target",
+				Usage: "Specify the directory of BitXHub node repos (default: $repo/bitxhub/.bitxhub)",
+			},
+			&cli.StringFlag{
+				Name:  "pier-target",
+				Usage: "Specify the directory of pier repos (default: $repo/pier)",
+			},
+			&cli.StringFlag{
+				Name:  "version",
+				Usage: "Specify the BitXHub version (default: the running BitXHub version if any)",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Report issues in json",
+			},
+		},
+		Action: configValidate,
+	}
+}
+
+func configValidate(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+
+	bxhTarget := ctx.String("bitxhub-target")
+	if bxhTarget == "" {
+		bxhTarget = filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+	}
+	pierTarget := ctx.String("pier-target")
+	if pierTarget == "" {
+		pierTarget = filepath.Join(repoRoot, types.Pier)
+	}
+
+	v := &configValidator{ports: make(map[string]string)}
+	version := ctx.String("version")
+	if version == "" {
+		version = runningBitXHubVersion(repoRoot)
+	}
+	if version != "" {
+		if v.release, err = versions.BitXHubOf(version); err != nil {
+			return err
+		}
+	}
+
+	if err := v.validateBitXHub(bxhTarget); err != nil {
+		return err
+	}
+	if err := v.validatePiers(pierTarget); err != nil {
+		return err
+	}
+
+	if ctx.Bool("json") {
+		issues := v.issues
+		if issues == nil {
+			issues = []*ConfigIssue{}
+		}
+		data, err := json.MarshalIndent(issues, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return v.err()
+	}
+
+	for _, issue := range v.issues {
+		where := issue.Component + " " + issue.Node
+		if issue.File != "" {
+			where += " " + issue.File
+		}
+		if issue.Severity == severityError {
+			color.Red("[error] %s: %s", where, issue.Message)
+		} else {
+			color.Yellow("[warning] %s: %s", where, issue.Message)
+		}
+	}
+	if len(v.issues) == 0 {
+		color.Green("%d BitXHub nodes are validated, no issue found", len(v.nodes))
+	}
+
+	return v.err()
+}
+
+// err returns an error with the number of config errors found, nil if there is none
+func (v *configValidator) err() error {
+	errs := 0
+	for _, issue := range v.issues {
+		if issue.Severity == severityError {
+			errs++
+		}
+	}
+
+	if errs > 0 {
+		return fmt.Errorf("%d config errors found", errs)
+	}
+
+	return nil
+}
+
+func (v *configValidator) report(severity, component, node, file, format string, args ...interface{}) {
+	v.issues = append(v.issues, &ConfigIssue{
+		Severity:  severity,
+		Component: component,
+		Node:      node,
+		File:      file,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *configValidator) validateBitXHub(target string) error {
+	dirs, err := ioutil.ReadDir(target)
+	if err != nil {
+		if !fileutil.Exist(target) {
+			return nil
+		}
+		return fmt.Errorf("read BitXHub target: %w", err)
+	}
+
+	for _, dir := range dirs {
+		nodeRoot := filepath.Join(target, dir.Name())
+		if !dir.IsDir() || !fileutil.Exist(filepath.Join(nodeRoot, repo.BitXHubConfigName)) {
+			continue
+		}
+		v.nodes = append(v.nodes, v.validateNode(dir.Name(), nodeRoot))
+	}
+
+	byID := make(map[uint64]*validatedNode)
+	for _, node := range v.nodes {
+		if node.network == nil {
+			continue
+		}
+		if other, ok := byID[node.id]; ok {
+			v.report(severityError, types.BitXHub, node.name, repo.NetworkConfigName, "id %d is also used by %s", node.id, other.name)
+		}
+		byID[node.id] = node
+	}
+
+	var genesis *validatedNode
+	for _, node := range v.nodes {
+		if node.network != nil {
+			v.checkNetwork(node, byID)
+		}
+		if node.admins == nil {
+			continue
+		}
+		if genesis == nil {
+			genesis = node
+		} else if strings.Join(genesis.admins, ",") != strings.Join(node.admins, ",") {
+			v.report(severityError, types.BitXHub, node.name, repo.BitXHubConfigName, "genesis admins differ from those of %s", genesis.name)
+		}
+	}
+
+	if genesis != nil {
+		for _, node := range v.nodes {
+			if node.account != "" && !containsFold(genesis.admins, node.account) {
+				v.report(severityWarning, types.BitXHub, node.name, repo.KeyName, "account %s is not a genesis admin", node.account)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *configValidator) validateNode(name, nodeRoot string) *validatedNode {
+	node := &validatedNode{name: name}
+
+	certRoot := filepath.Join(nodeRoot, "certs")
+	certs := append([]string{}, nodeCerts...)
+	if v.release != nil && v.release.HasAccountKey() {
+		certs = append(certs, repo.KeyPriv+".priv")
+	}
+	for _, c := range certs {
+		if !fileutil.Exist(filepath.Join(certRoot, c)) {
+			v.report(severityError, types.BitXHub, name, filepath.Join("certs", c), "missing")
+		}
+	}
+
+	if pid, err := getPidFromPrivateKey(filepath.Join(certRoot, "node.priv")); err == nil {
+		node.pid = pid
+	}
+	if fileutil.Exist(filepath.Join(nodeRoot, repo.KeyName)) {
+		if account, err := keyJsonAddress(filepath.Join(nodeRoot, repo.KeyName)); err != nil {
+			v.report(severityError, types.BitXHub, name, repo.KeyName, "read account: %v", err)
+		} else {
+			node.account = account
+		}
+	} else {
+		v.report(severityError, types.BitXHub, name, repo.KeyName, "missing")
+	}
+
+	if data, err := ioutil.ReadFile(filepath.Join(nodeRoot, repo.NetworkConfigName)); err != nil {
+		v.report(severityError, types.BitXHub, name, repo.NetworkConfigName, "read: %v", err)
+	} else {
+		network := &NetworkConfig{}
+		if err := toml.Unmarshal(data, network); err != nil {
+			v.report(severityError, types.BitXHub, name, repo.NetworkConfigName, "parse: %v", err)
+		} else if len(network.Nodes) > 0 && network.Nodes[0].Pid != "" {
+			node.id, node.network = network.ID, network
+		}
+	}
+
+	tree, err := toml.LoadFile(filepath.Join(nodeRoot, repo.BitXHubConfigName))
+	if err != nil {
+		v.report(severityError, types.BitXHub, name, repo.BitXHubConfigName, "parse: %v", err)
+		return node
+	}
+
+	if admins, ok := tree.Get("genesis.admins").([]*toml.Tree); ok {
+		for _, admin := range admins {
+			if addr, ok := admin.Get("address").(string); ok {
+				node.admins = append(node.admins, addr)
+			}
+		}
+		sort.Strings(node.admins)
+	} else if addrs, ok := tree.Get("genesis.addresses").([]interface{}); ok {
+		for _, addr := range addrs {
+			node.admins = append(node.admins, fmt.Sprint(addr))
+		}
+		sort.Strings(node.admins)
+	}
+
+	if node.network != nil {
+		for _, n := range node.network.Nodes {
+			if n.ID == node.id && len(n.Hosts) > 0 {
+				var p2p int64
+				node.host, p2p = parseHostAddr(n.Hosts[0])
+				v.usePort(node.host, p2p, fmt.Sprintf("%s %s p2p", types.BitXHub, name))
+			}
+		}
+	}
+	if portTree, ok := tree.Get("port").(*toml.Tree); ok {
+		for _, key := range portTree.Keys() {
+			if port, ok := portTree.Get(key).(int64); ok {
+				v.usePort(node.host, port, fmt.Sprintf("%s %s %s", types.BitXHub, name, key))
+			}
+		}
+		node.grpc, _ = portTree.Get("grpc").(int64)
+	}
+
+	return node
+}
+
+// checkNetwork compares network.toml of the node with what the nodes really are
+func (v *configValidator) checkNetwork(node *validatedNode, byID map[uint64]*validatedNode) {
+	if int(node.network.N) != len(node.network.Nodes) {
+		v.report(severityError, types.BitXHub, node.name, repo.NetworkConfigName, "n is %d but there are %d nodes", node.network.N, len(node.network.Nodes))
+	}
+	if len(node.network.Nodes) != len(byID) {
+		v.report(severityWarning, types.BitXHub, node.name, repo.NetworkConfigName, "lists %d nodes but there are %d node repos", len(node.network.Nodes), len(byID))
+	}
+
+	for _, n := range node.network.Nodes {
+		actual, ok := byID[n.ID]
+		if !ok {
+			continue
+		}
+		if actual.pid != "" && n.Pid != actual.pid {
+			v.report(severityError, types.BitXHub, node.name, repo.NetworkConfigName, "pid of node %d is %s, but node.priv of %s gives %s", n.ID, n.Pid, actual.name, actual.pid)
+		}
+		if n.Account != "" && actual.account != "" && !strings.EqualFold(n.Account, actual.account) {
+			v.report(severityError, types.BitXHub, node.name, repo.NetworkConfigName, "account of node %d is %s, but key.json of %s gives %s", n.ID, n.Account, actual.name, actual.account)
+		}
+	}
+}
+
+func (v *configValidator) validatePiers(target string) error {
+	dirs, err := ioutil.ReadDir(target)
+	if err != nil {
+		if !fileutil.Exist(target) {
+			return nil
+		}
+		return fmt.Errorf("read pier target: %w", err)
+	}
+
+	for _, dir := range dirs {
+		pierRoot := filepath.Join(target, dir.Name())
+		if dir.IsDir() && fileutil.Exist(filepath.Join(pierRoot, repo.PierConfigName)) {
+			v.validatePier(dir.Name(), pierRoot)
+		}
+	}
+
+	return nil
+}
+
+func (v *configValidator) validatePier(name, pierRoot string) {
+	tree, err := toml.LoadFile(filepath.Join(pierRoot, repo.PierConfigName))
+	if err != nil {
+		v.report(severityError, types.Pier, name, repo.PierConfigName, "parse: %v", err)
+		return
+	}
+
+	if !fileutil.Exist(filepath.Join(pierRoot, repo.KeyName)) {
+		v.report(severityError, types.Pier, name, repo.KeyName, "missing")
+	}
+
+	if config, ok := tree.Get("appchain.config").(string); ok {
+		pluginConfig := filepath.Join(config, config+".toml")
+		if _, err := toml.LoadFile(filepath.Join(pierRoot, pluginConfig)); err != nil {
+			v.report(severityError, types.Pier, name, pluginConfig, "parse: %v", err)
+		}
+	}
+
+	if portTree, ok := tree.Get("port").(*toml.Tree); ok {
+		for _, key := range portTree.Keys() {
+			if port, ok := portTree.Get(key).(int64); ok {
+				v.usePort("", port, fmt.Sprintf("%s %s %s", types.Pier, name, key))
+			}
+		}
+	}
+
+	if tree.Get("mode.type") != types.PierModeRelay || len(v.nodes) == 0 {
+		return
+	}
+
+	accounts := make([]string, 0, len(v.nodes))
+	grpcPorts := make(map[int64]bool)
+	for _, node := range v.nodes {
+		if node.account != "" {
+			accounts = append(accounts, node.account)
+		}
+		grpcPorts[node.grpc] = true
+	}
+
+	validators, _ := tree.Get("mode.relay.validators").([]interface{})
+	for _, validator := range validators {
+		if !containsFold(accounts, fmt.Sprint(validator)) {
+			v.report(severityError, types.Pier, name, repo.PierConfigName, "validator %v is not a BitXHub node account", validator)
+		}
+	}
+	if len(validators) != 0 && len(validators) != len(accounts) {
+		v.report(severityWarning, types.Pier, name, repo.PierConfigName, "%d validators are set but there are %d BitXHub nodes", len(validators), len(accounts))
+	}
+
+	addrs, _ := tree.Get("mode.relay.addrs").([]interface{})
+	for _, addr := range addrs {
+		s := fmt.Sprint(addr)
+		port, err := strconv.ParseInt(s[strings.LastIndex(s, ":")+1:], 10, 64)
+		if err != nil || !grpcPorts[port] {
+			v.report(severityError, types.Pier, name, repo.PierConfigName, "relay address %s is not the grpc address of a BitXHub node", s)
+		}
+	}
+}
+
+// usePort records the port of the host, local hosts share their ports
+func (v *configValidator) usePort(host string, port int64, user string) {
+	if port == 0 {
+		return
+	}
+	if ports.IsLocal(host) {
+		host = "localhost"
+	}
+
+	key := fmt.Sprintf("%s:%d", host, port)
+	if other, ok := v.ports[key]; ok {
+		parts := strings.SplitN(user, " ", 3)
+		v.report(severityError, parts[0], parts[1], "", "%s port %s is also used by %s", parts[2], key, other)
+		return
+	}
+	v.ports[key] = user
+}
+
+// parseHostAddr returns the host and the port of an address like /ip4/127.0.0.1/tcp/4001/p2p/
+func parseHostAddr(addr string) (string, int64) {
+	parts := strings.Split(strings.Trim(addr, "/"), "/")
+	if len(parts) < 4 {
+		return "", 0
+	}
+	port, _ := strconv.ParseInt(parts[3], 10, 64)
+
+	return parts[1], port
+}
+
+// keyJsonAddress returns the address of the key.json encrypted by the default password
+func keyJsonAddress(path string) (string, error) {
+	privKey, err := asym.RestorePrivateKey(path, repo.KeyPassword)
+	if err != nil {
+		return "", err
+	}
+	addr, err := privKey.PublicKey().Address()
+	if err != nil {
+		return "", err
+	}
+
+	return addr.String(), nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+
+	return false
+}
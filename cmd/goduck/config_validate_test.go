@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/goduck/internal/pki"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/pelletier/go-toml"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+)
+
+// writeValidNodes writes node repos of n nodes into target which configValidator finds no issue in
+func writeValidNodes(t *testing.T, target string, n int) {
+	ca, err := pki.Init(filepath.Join(target, "ca"), "Hyperchain", 0, nil)
+	require.Nil(t, err)
+
+	network := &NetworkConfig{N: uint64(n)}
+	var admins []*Admin
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("node%d", i)
+		nodeRoot := filepath.Join(target, name)
+		certRoot := filepath.Join(nodeRoot, types.TlsCerts)
+		_, err := ca.Issue(&pki.Request{Name: name, Role: pki.RoleNode, Dir: certRoot})
+		require.Nil(t, err)
+		pid, err := getPidFromPrivateKey(repo.GetPrivKeyPath(pki.RoleNode, certRoot))
+		require.Nil(t, err)
+
+		account, err := asym.GenerateKeyPair(crypto.Secp256k1)
+		require.Nil(t, err)
+		require.Nil(t, asym.StorePrivateKey(account, filepath.Join(nodeRoot, repo.KeyName), repo.KeyPassword))
+		addr, err := account.PublicKey().Address()
+		require.Nil(t, err)
+
+		network.Nodes = append(network.Nodes, &NetworkNodes{
+			ID:      uint64(i),
+			Pid:     pid,
+			Hosts:   []string{fmt.Sprintf("/ip4/127.0.0.1/tcp/%d/p2p/", 4000+i)},
+			Account: addr.String(),
+		})
+		admins = append(admins, &Admin{Address: addr.String(), Weight: 1})
+	}
+
+	for i, node := range network.Nodes {
+		nodeRoot := filepath.Join(target, fmt.Sprintf("node%d", i+1))
+		network.ID = node.ID
+		data, err := toml.Marshal(*network)
+		require.Nil(t, err)
+		require.Nil(t, ioutil.WriteFile(filepath.Join(nodeRoot, repo.NetworkConfigName), data, 0644))
+
+		data, err = toml.Marshal(map[string]interface{}{
+			"port":    map[string]interface{}{"grpc": 60010 + i + 1, "gateway": 9090 + i + 1},
+			"genesis": map[string]interface{}{"admins": admins},
+		})
+		require.Nil(t, err)
+		require.Nil(t, ioutil.WriteFile(filepath.Join(nodeRoot, repo.BitXHubConfigName), data, 0644))
+	}
+}
+
+// editNodeFile rewrites a file of the node in target by replacing old with new
+func editNodeFile(t *testing.T, target, node, file, old, new string) {
+	path := filepath.Join(target, node, file)
+	data, err := ioutil.ReadFile(path)
+	require.Nil(t, err)
+	require.Contains(t, string(data), old)
+	require.Nil(t, ioutil.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0644))
+}
+
+func TestValidateBitXHub(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(t *testing.T, target string)
+		want []string
+	}{
+		{
+			name: "valid",
+			edit: func(t *testing.T, target string) {},
+		},
+		{
+			name: "n differs from the nodes",
+			edit: func(t *testing.T, target string) {
+				editNodeFile(t, target, "node1", repo.NetworkConfigName, "n = 2", "n = 3")
+			},
+			want: []string{"n is 3 but there are 2 nodes"},
+		},
+		{
+			name: "duplicate id",
+			edit: func(t *testing.T, target string) {
+				editNodeFile(t, target, "node2", repo.NetworkConfigName, "id = 2\n", "id = 1\n")
+			},
+			// node2 takes the p2p address and the entry of node1 in both network.toml
+			want: []string{"p2p port localhost:4001 is also used by bitxhub node1 p2p", "id 1 is also used by node1",
+				"pid of node 1", "account of node 1", "pid of node 1", "account of node 1"},
+		},
+		{
+			name: "wrong pid",
+			edit: func(t *testing.T, target string) {
+				require.Nil(t, os.RemoveAll(filepath.Join(target, "node2", types.TlsCerts)))
+				ca, err := pki.Open(filepath.Join(target, "ca"))
+				require.Nil(t, err)
+				_, err = ca.Revoke("node2", "")
+				require.Nil(t, err)
+				_, err = ca.Issue(&pki.Request{Name: "node2", Role: pki.RoleNode, Dir: filepath.Join(target, "node2", types.TlsCerts)})
+				require.Nil(t, err)
+			},
+			want: []string{"pid of node 2 is", "pid of node 2 is"},
+		},
+		{
+			name: "missing cert",
+			edit: func(t *testing.T, target string) {
+				require.Nil(t, os.Remove(filepath.Join(target, "node1", types.TlsCerts, "agency.cert")))
+			},
+			want: []string{"missing"},
+		},
+		{
+			name: "port taken twice",
+			edit: func(t *testing.T, target string) {
+				editNodeFile(t, target, "node2", repo.BitXHubConfigName, "9092", "9091")
+			},
+			want: []string{"gateway port localhost:9091 is also used by bitxhub node1 gateway"},
+		},
+		{
+			name: "broken bitxhub.toml",
+			edit: func(t *testing.T, target string) {
+				editNodeFile(t, target, "node1", repo.BitXHubConfigName, "[port]", "[port")
+			},
+			want: []string{"parse:"},
+		},
+		{
+			name: "genesis admins differ",
+			edit: func(t *testing.T, target string) {
+				editNodeFile(t, target, "node2", repo.BitXHubConfigName, "weight = 1", "weight = 1\n\n  [[genesis.admins]]\n    address = \"0x0000000000000000000000000000000000000001\"\n    weight = 1")
+			},
+			want: []string{"genesis admins differ from those of node1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := ioutil.TempDir("", "validate")
+			require.Nil(t, err)
+			defer os.RemoveAll(target)
+			writeValidNodes(t, target, 2)
+			tt.edit(t, target)
+
+			v := &configValidator{ports: make(map[string]string)}
+			require.Nil(t, v.validateBitXHub(target))
+			var errs []string
+			for _, issue := range v.issues {
+				if issue.Severity == severityError {
+					errs = append(errs, issue.Message)
+				}
+			}
+			require.Len(t, errs, len(tt.want), "%v", errs)
+			for i, want := range tt.want {
+				require.Contains(t, errs[i], want)
+			}
+			if len(tt.want) == 0 {
+				require.Nil(t, v.err())
+			} else {
+				require.EqualError(t, v.err(), fmt.Sprintf("%d config errors found", len(tt.want)))
+			}
+		})
+	}
+}
+
+func TestConfigValidateJSON(t *testing.T) {
+	repoRoot, err := ioutil.TempDir("", "validate")
+	require.Nil(t, err)
+	defer os.RemoveAll(repoRoot)
+	target := filepath.Join(repoRoot, "bitxhub", ".bitxhub")
+	writeValidNodes(t, target, 2)
+	editNodeFile(t, target, "node1", repo.NetworkConfigName, "n = 2", "n = 3")
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	require.Nil(t, err)
+	os.Stdout = w
+	app := &cli.App{
+		Flags:    []cli.Flag{&cli.StringFlag{Name: "repo"}},
+		Commands: []*cli.Command{configValidateCMD()},
+	}
+	err = app.Run([]string{"goduck", "--repo", repoRoot, "validate", "--json"})
+	w.Close()
+	os.Stdout = stdout
+	require.EqualError(t, err, "1 config errors found")
+
+	data, err := ioutil.ReadAll(r)
+	require.Nil(t, err)
+	var issues []*ConfigIssue
+	require.Nil(t, json.Unmarshal(data, &issues))
+	require.Len(t, issues, 1)
+	require.Equal(t, &ConfigIssue{
+		Severity:  severityError,
+		Component: types.BitXHub,
+		Node:      "node1",
+		File:      repo.NetworkConfigName,
+		Message:   "n is 3 but there are 2 nodes",
+	}, issues[0])
+}
@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,8 +10,11 @@ import (
 	"github.com/fatih/color"
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/internal/download"
+	"github.com/meshplus/goduck/internal/hosts"
+	"github.com/meshplus/goduck/internal/inventory"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
 	"github.com/urfave/cli/v2"
 )
 
@@ -33,7 +34,7 @@ func deployCMD() *cli.Command {
 					},
 					&cli.StringFlag{
 						Name:     "ips",
-						Usage:    "servers ip, e.g. 188.0.0.1,188.0.0.2,188.0.0.3,.188.0.0.4",
+						Usage:    "servers host as IPv4, IPv6 or DNS name, hosts of a node are joined by |, e.g. 188.0.0.1,188.0.0.2|node2.example.com,fd00::3,node4.example.com",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -46,6 +47,11 @@ func deployCMD() *cli.Command {
 						Usage:    "BitXHub version",
 						Required: true,
 					},
+					&cli.StringFlag{
+						Name:  "consensus",
+						Value: "raft",
+						Usage: "consensus plugin of nodes, e.g. raft or rbft",
+					},
 				},
 				Action: deployBitXHub,
 			},
@@ -99,7 +105,7 @@ func deployCMD() *cli.Command {
 					},
 					&cli.StringFlag{
 						Name:     "ip",
-						Usage:    "servers ip, e.g. 188.0.0.1",
+						Usage:    "server host as IPv4, IPv6 or DNS name, e.g. 188.0.0.1",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -177,29 +183,21 @@ func deployBitXHub(ctx *cli.Context) error {
 	tls := ctx.Bool("tls")
 	username := ctx.String("username")
 	version := ctx.String("version")
+	consensus := ctx.String("consensus")
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
-	if err != nil {
+	if _, err := versions.LookupBitXHub(version); err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
-	}
+	// configs are kept with the CA of the cluster, which later issues and rotates its certs
+	dir := filepath.Join(inventory.Dir(repoRoot), types.BitXHub)
 
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport BitXHub verison")
-	}
-
-	dir, err := ioutil.TempDir("", "bitxhub")
-	if err != nil {
+	ips := strings.Split(ctx.String("ips"), ",")
+	if err := hosts.CheckAll(ips); err != nil {
 		return err
 	}
 
-	ips := strings.Split(ctx.String("ips"), ",")
-
-	generator := NewBitXHubConfigGenerator("binary", "cluster", dir, len(ips), ips, tls, version)
+	generator := NewBitXHubConfigGenerator("binary", "cluster", consensus, dir, len(ips), ips, "", tls, version, "", nil, "")
 
 	if err := generator.InitConfig(); err != nil {
 		return err
@@ -225,8 +223,10 @@ func deployBitXHub(ctx *cli.Context) error {
 
 	for idx, ip := range ips {
 		color.Blue("====> Operating at node%d\n", idx+1)
-		who := fmt.Sprintf("%s@%s", username, ip)
-		target := fmt.Sprintf("%s:~/", who)
+		// nodes are reached at their first host
+		host := hosts.Split(ip)[0]
+		who := fmt.Sprintf("%s@%s", username, host)
+		target := hosts.SCPTarget(username, host, "~/")
 
 		err = sh.Command("ssh", who, fmt.Sprintf("mkdir -p ~/.bitxhub/node%d", idx+1)).
 			Command("scp", filePath, target).Run()
@@ -243,15 +243,35 @@ func deployBitXHub(ctx *cli.Context) error {
 		}
 
 		err = sh.
-			Command("ssh", who, fmt.Sprintf("tar xzf %s -C ~/.bitxhub && mkdir -p .bitxhub/node%d/plugins && cp .bitxhub/raft.so .bitxhub/node%d/plugins", filename, idx+1, idx+1)).Run()
+			Command("ssh", who, fmt.Sprintf("tar xzf %s -C ~/.bitxhub && mkdir -p .bitxhub/node%d/plugins && cp .bitxhub/%s.so .bitxhub/node%d/plugins", filename, idx+1, consensus, idx+1)).Run()
 		if err != nil {
 			return err
 		}
 	}
 
+	cluster := &inventory.BitXHub{Version: version, Consensus: consensus, Config: dir}
+	for idx, ip := range ips {
+		cluster.Nodes = append(cluster.Nodes, &inventory.Host{
+			Name:    fmt.Sprintf("node%d", idx+1),
+			Host:    hosts.Split(ip)[0],
+			User:    username,
+			Repo:    fmt.Sprintf(".bitxhub/node%d", idx+1),
+			PidFile: fmt.Sprintf(".bitxhub/bitxhub%d.PID", idx+1),
+			Version: version,
+		})
+	}
+	inv, err := inventory.Load(repoRoot)
+	if err != nil {
+		return err
+	}
+	inv.BitXHub = cluster
+	if err := inv.Save(repoRoot); err != nil {
+		return err
+	}
+
 	color.Blue("====> Run\n")
 	for idx, ip := range ips {
-		who := fmt.Sprintf("%s@%s", username, ip)
+		who := fmt.Sprintf("%s@%s", username, hosts.Split(ip)[0])
 
 		err = sh.Command("ssh", who,
 			fmt.Sprintf("export LD_LIBRARY_PATH=$LD_LIBRARY_PATH:$HOME/.bitxhub && cd ~/.bitxhub && nohup ./bitxhub --repo=node%d start >/dev/null 2>&1 &", idx+1)).Start()
@@ -272,7 +292,7 @@ func deployBitXHub(ctx *cli.Context) error {
 	color.Blue("====> Check\n")
 	fmt.Println("You need to wait more than 5 seconds for each node")
 	for idx, ip := range ips {
-		who := fmt.Sprintf("%s@%s", username, ip)
+		who := fmt.Sprintf("%s@%s", username, hosts.Split(ip)[0])
 
 		out, err := sh.Command("ssh", who, fmt.Sprintf("sleep 5 && cat ~/.bitxhub/bitxhub%d.PID", idx+1)).Output()
 		if err != nil {
@@ -333,34 +353,44 @@ func deployPier(ctx *cli.Context) error {
 		return err
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	release, err := versions.LookupPier(version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	if err := hosts.Check(ip); err != nil {
 		return err
 	}
-
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport pier verison")
-	}
-
 	who := fmt.Sprintf("%s@%s", username, ip)
-	target := fmt.Sprintf("%s:~/", who)
+	target := hosts.SCPTarget(username, ip, "~/")
 
 	err = pierPrepare(repoRoot, version, target, who, mode, bitxhub, chain, ip, validators, port, peers, connectors, providers, tls, http, pprof, apiPort, cryptoPath, appchainIP, appchainAddr, appPorts, appchainContractAddr, appchainDid)
 	if err != nil {
 		return err
 	}
 
+	inv, err := inventory.Load(repoRoot)
+	if err != nil {
+		return err
+	}
+	inv.SetPier(&inventory.Host{
+		Name:    chain,
+		Host:    ip,
+		User:    username,
+		Repo:    fmt.Sprintf(".pier_%s", chain),
+		PidFile: fmt.Sprintf(".pier_%s/pier.PID", chain),
+		Version: version,
+	})
+	if err := inv.Save(repoRoot); err != nil {
+		return err
+	}
+
 	err = appchainRegister(who, chain, version)
 	if err != nil {
 		return err
 	}
 
-	if version < "v1.6.0" {
+	if !release.RegisterByProposal() {
 		err = ruleDeploy(who, chain)
 		if err != nil {
 			return err
@@ -376,7 +406,7 @@ func deployPier(ctx *cli.Context) error {
 			return err
 		}
 	} else {
-		color.Blue("The PIER has been successfully deployed and the application chain registration proposal has been initiated to the relay chain. Please wait for the proposal to pass before registering the validation rules and starting the PIER\n")
+		color.Blue("The PIER has been successfully deployed and the application chain registration proposal has been initiated to the relay chain. Please wait for the proposal to pass, or approve it by `goduck governance vote-all`, before registering the validation rules and starting the PIER\n")
 	}
 
 	return nil
@@ -446,6 +476,11 @@ func ruleDeploy(who string, chain string) error {
 func appchainRegister(who, chain, version string) error {
 	color.Blue("====> Register pier(%s) to BitXHub \n", chain)
 
+	release, err := versions.PierOf(version)
+	if err != nil {
+		return err
+	}
+
 	chainVersion := ""
 	if chain == "fabric" {
 		chainVersion = "1.4.3"
@@ -455,7 +490,7 @@ func appchainRegister(who, chain, version string) error {
 		return fmt.Errorf("not support chain type")
 	}
 
-	if version < "v1.7.0" {
+	if !release.RegisterWithConsensus() {
 		err := sh.Command("ssh", who,
 			fmt.Sprintf("export LD_LIBRARY_PATH=$HOME/pier && $HOME/pier/pier --repo $HOME/.pier_%s appchain register "+
 				"--name chain-%s "+
@@ -486,8 +521,13 @@ func appchainRegister(who, chain, version string) error {
 }
 
 func pierPrepare(repoRoot, version, target, who, mode, bitxhub, chain, ip string, validators []string, port string, peers, connectors []string, providers, tls, http, pprof, apiPort, cryptoPath, appchainIP, appchainAddr string, appPorts []string, appchainContractAddr, appchainDid string) error {
+	release, err := versions.PierOf(version)
+	if err != nil {
+		return err
+	}
+
 	configPath := filepath.Join(repoRoot, "pier_deploy")
-	err := os.MkdirAll(configPath, os.ModePerm)
+	err = os.MkdirAll(configPath, os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -565,7 +605,7 @@ func pierPrepare(repoRoot, version, target, who, mode, bitxhub, chain, ip string
 
 	color.Blue("====> Generate pier configure locally\n")
 	pierPath := ""
-	err = InitPierConfig(mode, "binary", bitxhub, validators, port, peers, connectors, providers, chain, appchainIP, appchainAddr, appPorts, appchainContractAddr, configPath, tls, http, pprof, apiPort, version, pierPath, cryptoPath, appchainDid)
+	err = InitPierConfig(mode, "binary", bitxhub, validators, port, peers, connectors, providers, chain, appchainIP, appchainAddr, appPorts, appchainContractAddr, configPath, tls, http, pprof, apiPort, version, pierPath, cryptoPath, appchainDid, "")
 	if err != nil {
 		return err
 	}
@@ -580,7 +620,7 @@ func pierPrepare(repoRoot, version, target, who, mode, bitxhub, chain, ip string
 		return err
 	}
 	// The files needed for deployment are placed in the ~/pier folder, and the configuration folder for actual deployment is ~/.pier_${chaintype}.
-	if version < "v1.7.0" {
+	if !release.SeparateClient() {
 		err = sh.
 			Command("scp", libPath, fmt.Sprintf("%spier/", target)).
 			Command("scp", rulePath, fmt.Sprintf("%spier/", target)).
@@ -591,12 +631,7 @@ func pierPrepare(repoRoot, version, target, who, mode, bitxhub, chain, ip string
 			return err
 		}
 	} else {
-		clientPath := ""
-		if chain == types.ChainTypeEther {
-			clientPath = filepath.Join(binPath, types.EthClient)
-		} else if chain == types.ChainTypeFabric {
-			clientPath = filepath.Join(binPath, types.FabricClient)
-		}
+		clientPath := filepath.Join(binPath, release.ClientFile(chain))
 		err = sh.
 			Command("scp", libPath, fmt.Sprintf("%spier/", target)).
 			Command("scp", rulePath, fmt.Sprintf("%spier/", target)).
@@ -639,20 +674,7 @@ func pierPrepare(repoRoot, version, target, who, mode, bitxhub, chain, ip string
 	}
 
 	color.Blue("====> Copy appchain plugin\n")
-	chainPlugin := ""
-	if chain == "fabric" {
-		if version == "v1.0.0" || version == "v1.0.0-rc1" {
-			chainPlugin = "fabric-client-1.4.so"
-		} else {
-			chainPlugin = "fabric-client-1.4"
-		}
-	} else {
-		if version == "v1.0.0" || version == "v1.0.0-rc1" {
-			chainPlugin = "eth-client.so"
-		} else {
-			chainPlugin = "eth-client"
-		}
-	}
+	chainPlugin := release.ClientFile(chain)
 
 	err = sh.
 		Command("ssh", who, fmt.Sprintf("mkdir -p $HOME/.pier_%s/plugins && cp $HOME/pier/%s $HOME/.pier_%s/plugins/", chain, chainPlugin, chain)).
@@ -661,8 +683,8 @@ func pierPrepare(repoRoot, version, target, who, mode, bitxhub, chain, ip string
 		return err
 	}
 
-	if version != "v1.0.0" && version != "v1.0.0-rc1" {
-		err = sh.Command("ssh", who, fmt.Sprintf("mv $HOME/.pier_%s/plugins/%s $HOME/.pier_%s/plugins/appchain_plugin", chain, chainPlugin, chain)).Run()
+	if !release.SharedObjectPlugin() {
+		err = sh.Command("ssh", who, fmt.Sprintf("mv $HOME/.pier_%s/plugins/%s $HOME/.pier_%s/plugins/%s", chain, chainPlugin, chain, release.PluginFile(chain))).Run()
 		if err != nil {
 			return err
 		}
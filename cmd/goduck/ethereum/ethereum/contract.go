@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/ecdsa"
+	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"reflect"
@@ -19,9 +20,23 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/utils"
 	"github.com/urfave/cli/v2"
 )
 
+var (
+	passwordFlag = &cli.StringFlag{
+		Name:    "password",
+		Usage:   "the password of the ethereum keystore, an empty one is tried and then asked on the terminal if it is not given",
+		EnvVars: []string{"GODUCK_PASSWORD"},
+	}
+	passwordFileFlag = &cli.StringFlag{
+		Name:  "password_file",
+		Usage: "the file whose first line is the password of the ethereum keystore",
+	}
+)
+
 var contractCMD = &cli.Command{
 	Name:  "contract",
 	Usage: "operation about solidity contract",
@@ -38,9 +53,11 @@ var contractCMD = &cli.Command{
 				},
 				&cli.StringFlag{
 					Name:     "key_path",
-					Usage:    "the ethereum account private key path",
+					Usage:    "the ethereum account private key path, or the label of a key in the key store of the repo",
 					Required: true,
 				},
+				passwordFlag,
+				passwordFileFlag,
 				&cli.StringFlag{
 					Name:     "code_path",
 					Usage:    "the path of solidity contract",
@@ -61,9 +78,11 @@ var contractCMD = &cli.Command{
 				},
 				&cli.StringFlag{
 					Name:     "key_path",
-					Usage:    "the ethereum account private key path",
+					Usage:    "the ethereum account private key path, or the label of a key in the key store of the repo",
 					Required: true,
 				},
+				passwordFlag,
+				passwordFileFlag,
 				&cli.StringFlag{
 					Name:     "abi_path",
 					Usage:    "the path of solidity contract abi file",
@@ -80,7 +99,7 @@ func deploy(ctx *cli.Context) error {
 	keyPath := ctx.String("key_path")
 	codePath := ctx.String("code_path")
 
-	etherCli, privateKey, err := helper(etherAddr, keyPath)
+	etherCli, privateKey, err := helper(ctx, etherAddr, keyPath)
 	if err != nil {
 		return err
 	}
@@ -156,7 +175,7 @@ func invoke(ctx *cli.Context) error {
 		return err
 	}
 
-	etherCli, privateKey, err := helper(etherAddr, keyPath)
+	etherCli, privateKey, err := helper(ctx, etherAddr, keyPath)
 	if err != nil {
 		return err
 	}
@@ -239,7 +258,20 @@ func invoke(ctx *cli.Context) error {
 	return nil
 }
 
-func helper(etherAddr, keyPath string) (*ethclient.Client, *ecdsa.PrivateKey, error) {
+func passwordSource(ctx *cli.Context) *utils.PasswordSource {
+	return &utils.PasswordSource{Password: ctx.String("password"), File: ctx.String("password_file")}
+}
+
+func helper(ctx *cli.Context, etherAddr, keyPath string) (*ethclient.Client, *ecdsa.PrivateKey, error) {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPath, err = repo.ResolveKey(repoRoot, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	etherCli, err := ethclient.Dial(etherAddr)
 	if err != nil {
 		return nil, nil, err
@@ -249,9 +281,23 @@ func helper(etherAddr, keyPath string) (*ethclient.Client, *ecdsa.PrivateKey, er
 	if err != nil {
 		return nil, nil, err
 	}
-	unlockedKey, err := keystore.DecryptKey(keyByte, "")
+	// pem keys of the key store hold raw Secp256k1 keys
+	if block, _ := pem.Decode(keyByte); block != nil {
+		privateKey, err := crypto.ToECDSA(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parse %s: %w", keyPath, err)
+		}
+		return etherCli, privateKey, nil
+	}
+
+	// keystores of the ethereum started by goduck have empty passwords
+	var unlockedKey *keystore.Key
+	err = passwordSource(ctx).Unlock(fmt.Sprintf("Password of %s", keyPath), []string{""}, func(password string) error {
+		unlockedKey, err = keystore.DecryptKey(keyByte, password)
+		return err
+	})
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("decrypt keystore: %w", err)
 	}
 
 	return etherCli, unlockedKey.PrivateKey, nil
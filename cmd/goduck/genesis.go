@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	crypto2 "github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/tomledit"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+// proposal managers of BitXHub governance
+var governanceManagers = []string{"AppchainMgr", "RuleMgr", "NodeMgr", "ServiceMgr"}
+
+// proposalStrategies are the strategies BitXHub decides proposals of a manager by
+var proposalStrategies = []string{repo.SimpleMajority, repo.ZeroPermission}
+
+// GenesisSpec customizes the genesis of BitXHub v1.6.0+, fields which are not set keep the default:
+// node accounts are admins with weight 1 and every manager uses SimpleMajority
+type GenesisSpec struct {
+	// NodeAdmins makes the node accounts admins, true if it is not set
+	NodeAdmins *bool             `toml:"node_admins" json:"node_admins"`
+	Admins     []*AdminSpec      `toml:"admins" json:"admins"`
+	Strategy   map[string]string `toml:"strategy" json:"strategy"`
+	// Balance is the initial balance of admin accounts
+	Balance string `toml:"balance" json:"balance"`
+}
+
+// AdminSpec is an admin of the genesis given by Address, or by Key which is the label of
+// a key in the key store of the repo or the path of a Secp256k1 private key
+type AdminSpec struct {
+	Address string `toml:"address" json:"address"`
+	Key     string `toml:"key" json:"key"`
+	Weight  uint64 `toml:"weight" json:"weight"`
+}
+
+var genesisFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "genesis",
+		Usage: "Specify the genesis spec file in toml or json, which sets node_admins, admins, strategy and balance",
+	},
+	&cli.StringSliceFlag{
+		Name:  "admin",
+		Usage: "Add an admin to the genesis as address[:weight] or key[:weight], key is a key label of the key store or a private key path",
+	},
+	&cli.BoolFlag{
+		Name:  "no-node-admins",
+		Usage: "Do not make the node accounts admins of the genesis",
+	},
+	&cli.StringSliceFlag{
+		Name:  "strategy",
+		Usage: "Set the proposal strategy of a manager as manager=strategy, e.g. AppchainMgr=ZeroPermission, the strategy is SimpleMajority or ZeroPermission",
+	},
+	&cli.StringFlag{
+		Name:  "balance",
+		Usage: "Specify the initial balance of admin accounts",
+	},
+}
+
+// genesisSpecFromFlags loads the genesis spec file and applies the genesis flags over it
+func genesisSpecFromFlags(ctx *cli.Context) (*GenesisSpec, error) {
+	spec, err := loadGenesisSpec(ctx.String("genesis"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range ctx.StringSlice("admin") {
+		admin := &AdminSpec{Weight: 1}
+		value := s
+		if i := strings.LastIndex(s, ":"); i >= 0 {
+			weight, err := strconv.ParseUint(s[i+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid admin %s: %w", s, err)
+			}
+			value, admin.Weight = s[:i], weight
+		}
+		if strings.HasPrefix(value, "0x") {
+			admin.Address = value
+		} else {
+			admin.Key = value
+		}
+		spec.Admins = append(spec.Admins, admin)
+	}
+
+	if ctx.Bool("no-node-admins") {
+		nodeAdmins := false
+		spec.NodeAdmins = &nodeAdmins
+	}
+
+	for _, s := range ctx.StringSlice("strategy") {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("invalid strategy %s, expect manager=strategy", s)
+		}
+		if spec.Strategy == nil {
+			spec.Strategy = make(map[string]string)
+		}
+		spec.Strategy[kv[0]] = kv[1]
+	}
+
+	if ctx.IsSet("balance") {
+		spec.Balance = ctx.String("balance")
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// loadGenesisSpec reads the genesis spec in toml or json, an empty spec is returned if path is empty
+func loadGenesisSpec(path string) (*GenesisSpec, error) {
+	spec := &GenesisSpec{}
+	if path == "" {
+		return spec, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read genesis spec: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, spec)
+	case ".json":
+		err = json.Unmarshal(data, spec)
+	default:
+		return nil, fmt.Errorf("unsupported genesis spec format %s, use toml or json", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse genesis spec: %w", err)
+	}
+
+	return spec, nil
+}
+
+// IsDefault reports whether the spec changes nothing of the default genesis
+func (s *GenesisSpec) IsDefault() bool {
+	return s == nil || (s.NodeAdmins == nil && len(s.Admins) == 0 && len(s.Strategy) == 0 && s.Balance == "")
+}
+
+// validate checks the strategy names managers BitXHub has and strategies it supports, which BitXHub
+// would only complain about when it starts
+func (s *GenesisSpec) validate() error {
+	for m, strategy := range s.Strategy {
+		if !contains(governanceManagers, m) {
+			return fmt.Errorf("unknown manager %s in strategy, expect one of %s", m, strings.Join(governanceManagers, ", "))
+		}
+		if !contains(proposalStrategies, strategy) {
+			return fmt.Errorf("unknown strategy %s of %s, expect one of %s", strategy, m, strings.Join(proposalStrategies, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Build resolves the genesis from the spec with accounts of the nodes
+func (s *GenesisSpec) Build(repoRoot string, nodeAccounts []string) (*Genesis, error) {
+	if s == nil {
+		s = &GenesisSpec{}
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+
+	genesis := &Genesis{
+		Strategy: make(map[string]string),
+		Balance:  s.Balance,
+	}
+	for _, m := range governanceManagers {
+		genesis.Strategy[m] = repo.SimpleMajority
+	}
+	for m, strategy := range s.Strategy {
+		genesis.Strategy[m] = strategy
+	}
+
+	if s.NodeAdmins == nil || *s.NodeAdmins {
+		for _, addr := range nodeAccounts {
+			genesis.Admins = append(genesis.Admins, &Admin{Address: addr, Weight: 1})
+		}
+	}
+
+	for _, a := range s.Admins {
+		addr, err := a.resolve(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		weight := a.Weight
+		if weight == 0 {
+			weight = 1
+		}
+
+		exist := false
+		for _, admin := range genesis.Admins {
+			if strings.EqualFold(admin.Address, addr) {
+				admin.Weight = weight
+				exist = true
+			}
+		}
+		if !exist {
+			genesis.Admins = append(genesis.Admins, &Admin{Address: addr, Weight: weight})
+		}
+	}
+
+	if len(genesis.Admins) == 0 {
+		return nil, fmt.Errorf("there is no admin in the genesis")
+	}
+
+	return genesis, nil
+}
+
+func (a *AdminSpec) resolve(repoRoot string) (string, error) {
+	if a.Address != "" {
+		return a.Address, nil
+	}
+	if a.Key == "" {
+		return "", fmt.Errorf("admin needs an address or a key")
+	}
+
+	keyPath, err := repo.ResolveKey(repoRoot, a.Key)
+	if err != nil {
+		return "", err
+	}
+	// encrypted admin keys are opened with their default passwords
+	privKey, err := readKey(keyPath, "", crypto2.Secp256k1, &utils.PasswordSource{}, false)
+	if err != nil {
+		return "", fmt.Errorf("read admin key %s: %w", a.Key, err)
+	}
+	addr, err := privKey.PublicKey().Address()
+	if err != nil {
+		return "", fmt.Errorf("get address of admin key %s: %w", a.Key, err)
+	}
+
+	return addr.String(), nil
+}
+
+// writeGenesis sets admins, strategy and balance of the genesis in bitxhub.toml of the node,
+// other genesis items are kept
+func writeGenesis(nodeRoot string, genesis *Genesis) error {
+	path := filepath.Join(nodeRoot, repo.BitXHubConfigName)
+	doc, err := tomledit.Load(path)
+	if err != nil {
+		return err
+	}
+
+	if err := doc.SetTables("genesis.admins", genesis.Admins); err != nil {
+		return err
+	}
+
+	if strategy, ok := doc.Get("genesis.strategy"); ok {
+		if tree, ok := strategy.(*toml.Tree); ok {
+			for _, m := range tree.Keys() {
+				if _, ok := genesis.Strategy[m]; ok {
+					continue
+				}
+				if err := doc.Unset("genesis.strategy." + m); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	modules := make([]string, 0, len(genesis.Strategy))
+	for m := range genesis.Strategy {
+		modules = append(modules, m)
+	}
+	sort.Strings(modules)
+	for _, m := range modules {
+		if err := doc.Set("genesis.strategy."+m, genesis.Strategy[m]); err != nil {
+			return err
+		}
+	}
+
+	if genesis.Balance != "" {
+		if err := doc.Set("genesis.balance", genesis.Balance); err != nil {
+			return err
+		}
+	}
+
+	return doc.Save(path)
+}
+
+// nodeAccounts returns the accounts of the nodes in target by their key.priv
+func nodeAccounts(target string, names []string) ([]string, error) {
+	var accounts []string
+	for _, name := range names {
+		addr, err := getAddressFromPrivateKey(repo.GetPrivKeyPath(repo.KeyPriv, filepath.Join(target, name, "certs")), crypto2.Secp256k1)
+		if err != nil {
+			return nil, fmt.Errorf("get account of %s: %w", name, err)
+		}
+		accounts = append(accounts, addr)
+	}
+
+	return accounts, nil
+}
+
+// applyGenesis writes the genesis of the spec into nodes in target
+func applyGenesis(repoRoot, target string, names []string, spec *GenesisSpec) error {
+	accounts, err := nodeAccounts(target, names)
+	if err != nil {
+		return err
+	}
+
+	genesis, err := spec.Build(repoRoot, accounts)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err := writeGenesis(filepath.Join(target, name), genesis); err != nil {
+			return fmt.Errorf("write genesis of %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/meshplus/goduck/cmd/goduck/ethereum/ethereum"
+	"github.com/meshplus/goduck/internal/utils"
 	"github.com/urfave/cli/v2"
 )
 
@@ -25,6 +26,24 @@ func main() {
 			Name:  "repo",
 			Usage: "GoDuck storage repo path",
 		},
+		&cli.BoolFlag{
+			Name:    "yes",
+			Aliases: []string{"force"},
+			Usage:   "Answer yes to every prompt, e.g. overwrite existing configuration",
+		},
+		&cli.BoolFlag{
+			Name:    "no-input",
+			Aliases: []string{"no"},
+			Usage:   "Never read stdin and answer no to every prompt, e.g. keep existing configuration",
+		},
+	}
+
+	app.Before = func(ctx *cli.Context) error {
+		if ctx.Bool("yes") && ctx.Bool("no-input") {
+			return fmt.Errorf("--yes and --no-input can not be used together")
+		}
+		utils.SetPromptMode(ctx.Bool("yes"), ctx.Bool("no-input"))
+		return nil
 	}
 
 	app.Commands = []*cli.Command{
@@ -39,6 +58,13 @@ func main() {
 		playgroundCMD(),
 		infoCMD(),
 		prometheusCMD(),
+		upCMD(),
+		downCMD(),
+		configCMD(),
+		certCMD(),
+		governanceCMD(),
+		ruleCMD(),
+		serviceCMD(),
 	}
 
 	err := app.Run(os.Args)
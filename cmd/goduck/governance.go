@@ -0,0 +1,533 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	crypto2 "github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/goduck/internal/bxh"
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+// AdminKey is a key of a BitXHub admin which goduck votes with
+type AdminKey struct {
+	Name    string
+	Address string
+	Key     crypto2.PrivateKey
+}
+
+// governanceFlags select the node the governance commands talk to and the key they sign with
+func governanceFlags() []cli.Flag {
+	return append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "gateway",
+			Usage: "Specify the gateway of a BitXHub node as host:port or url (default: the gateway of the first node in $repo/bitxhub/.bitxhub)",
+		},
+		&cli.StringFlag{
+			Name:  "key",
+			Usage: "Specify the admin key signing the transactions, a key label of the key store or a key path (default: the first admin key goduck generated)",
+		},
+	}, passwordFlags("", "the password of the key")...)
+}
+
+func governanceCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "governance",
+		Usage: "List, inspect and vote on BitXHub governance proposals",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "proposals",
+				Usage: "Query proposals",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "list",
+						Usage: "List proposals",
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:  "status",
+								Usage: fmt.Sprintf("List proposals of the status, one of %s (default: all)", strings.Join(bxh.ProposalStatuses, ", ")),
+							},
+							&cli.StringFlag{
+								Name:  "type",
+								Usage: fmt.Sprintf("List proposals of the type, one of %s (default: all)", strings.Join(bxh.ProposalTypes, ", ")),
+							},
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "List proposals in json",
+							},
+						}, governanceFlags()...),
+						Action: listProposals,
+					},
+				},
+			},
+			{
+				Name:  "proposal",
+				Usage: "Inspect a proposal",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "show",
+						Usage:     "Show a proposal and its ballots",
+						ArgsUsage: "<proposal id>",
+						Flags: append([]cli.Flag{
+							&cli.BoolFlag{
+								Name:  "json",
+								Usage: "Show the proposal in json",
+							},
+						}, governanceFlags()...),
+						Action: showProposal,
+					},
+				},
+			},
+			{
+				Name:  "vote",
+				Usage: "Vote on a proposal as an admin",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:     "id",
+						Usage:    "Specify the proposal id",
+						Required: true,
+					},
+					&cli.BoolFlag{
+						Name:  "approve",
+						Usage: "Approve the proposal",
+					},
+					&cli.BoolFlag{
+						Name:  "reject",
+						Usage: "Reject the proposal",
+					},
+					&cli.StringFlag{
+						Name:  "reason",
+						Value: "voted by goduck",
+						Usage: "Specify the reason of the vote",
+					},
+				}, governanceFlags()...),
+				Action: voteProposal,
+			},
+			{
+				Name:  "vote-all",
+				Usage: "Vote on open proposals with every admin key goduck generated until they are closed",
+				Flags: append([]cli.Flag{
+					&cli.StringSliceFlag{
+						Name:  "id",
+						Usage: "Specify the proposals to vote on (default: all open proposals)",
+					},
+					&cli.StringFlag{
+						Name:  "type",
+						Usage: fmt.Sprintf("Vote on proposals of the type only, one of %s", strings.Join(bxh.ProposalTypes, ", ")),
+					},
+					&cli.BoolFlag{
+						Name:  "reject",
+						Usage: "Reject the proposals instead of approving them",
+					},
+					&cli.StringFlag{
+						Name:  "reason",
+						Value: "voted by goduck",
+						Usage: "Specify the reason of the votes",
+					},
+				}, governanceFlags()...),
+				Action: voteAllProposals,
+			},
+		},
+	}
+}
+
+func listProposals(ctx *cli.Context) error {
+	status := ctx.String("status")
+	if status != "" && !contains(bxh.ProposalStatuses, status) {
+		return fmt.Errorf("unsupported proposal status %s, expect one of %s", status, strings.Join(bxh.ProposalStatuses, ", "))
+	}
+	typ := ctx.String("type")
+	if typ != "" && !contains(bxh.ProposalTypes, typ) {
+		return fmt.Errorf("unsupported proposal type %s, expect one of %s", typ, strings.Join(bxh.ProposalTypes, ", "))
+	}
+	client, key, err := governanceClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	all, err := client.Proposals(key, status)
+	if err != nil {
+		return fmt.Errorf("list proposals: %w", err)
+	}
+	proposals := []*bxh.Proposal{}
+	for _, p := range all {
+		if typ == "" || p.Typ == typ {
+			proposals = append(proposals, p)
+		}
+	}
+	sort.Slice(proposals, func(i, j int) bool {
+		return numberedLess(proposals[i].Id, proposals[j].Id)
+	})
+
+	if ctx.Bool("json") {
+		data, err := json.MarshalIndent(proposals, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(proposals) == 0 {
+		fmt.Println("no proposals")
+		return nil
+	}
+	table := [][]string{{"Id", "Type", "Status", "Object", "Approve", "Against", "Electorate", "Threshold", "Description"}}
+	for _, p := range proposals {
+		table = append(table, []string{p.Id, p.Typ, p.Status, p.ObjId,
+			strconv.FormatUint(p.ApproveNum, 10), strconv.FormatUint(p.AgainstNum, 10),
+			strconv.FormatUint(p.ElectorateNum, 10), strconv.FormatUint(p.ThresholdNum, 10), p.Des})
+	}
+	PrintTable(table, true)
+
+	return nil
+}
+
+func showProposal(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expect <proposal id>")
+	}
+	client, key, err := governanceClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	proposal, err := client.Proposal(key, ctx.Args().First())
+	if err != nil {
+		return fmt.Errorf("get proposal: %w", err)
+	}
+
+	if ctx.Bool("json") {
+		data, err := json.MarshalIndent(proposal, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	PrintTable([][]string{
+		{"Id", proposal.Id},
+		{"Type", proposal.Typ},
+		{"Status", proposal.Status},
+		{"Object", proposal.ObjId},
+		{"Description", proposal.Des},
+		{"Votes", fmt.Sprintf("%d approve, %d against, %d electorate, %d threshold",
+			proposal.ApproveNum, proposal.AgainstNum, proposal.ElectorateNum, proposal.ThresholdNum)},
+	}, false)
+
+	if len(proposal.BallotMap) == 0 {
+		return nil
+	}
+	fmt.Println()
+	var voters []string
+	for voter := range proposal.BallotMap {
+		voters = append(voters, voter)
+	}
+	sort.Strings(voters)
+	table := [][]string{{"Voter", "Vote", "Weight", "Reason"}}
+	for _, voter := range voters {
+		b := proposal.BallotMap[voter]
+		table = append(table, []string{voter, b.Approve, strconv.FormatUint(b.Num, 10), b.Reason})
+	}
+	PrintTable(table, true)
+
+	return nil
+}
+
+func voteProposal(ctx *cli.Context) error {
+	if ctx.Bool("approve") == ctx.Bool("reject") {
+		return fmt.Errorf("expect one of --approve and --reject")
+	}
+	client, key, err := governanceClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	id := ctx.String("id")
+	if err := client.Vote(key, id, ctx.Bool("approve"), ctx.String("reason")); err != nil {
+		return fmt.Errorf("vote on proposal %s: %w", id, err)
+	}
+	proposal, err := client.Proposal(key, id)
+	if err != nil {
+		return fmt.Errorf("get proposal: %w", err)
+	}
+	color.Green("Vote on proposal %s successful, it is %s with %d approve and %d against votes", id, proposal.Status, proposal.ApproveNum, proposal.AgainstNum)
+
+	return nil
+}
+
+func voteAllProposals(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	client, key, err := governanceClient(ctx)
+	if err != nil {
+		return err
+	}
+	admins, err := adminKeys(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		return fmt.Errorf("no admin keys in %s", repoRoot)
+	}
+
+	ids := ctx.StringSlice("id")
+	if len(ids) == 0 {
+		proposals, err := client.Proposals(key, bxh.ProposalProposed)
+		if err != nil {
+			return fmt.Errorf("list proposals: %w", err)
+		}
+		for _, p := range proposals {
+			if ctx.String("type") == "" || p.Typ == ctx.String("type") {
+				ids = append(ids, p.Id)
+			}
+		}
+		// proposals of an admin are voted in the order they were submitted
+		sort.Slice(ids, func(i, j int) bool {
+			return numberedLess(ids[i], ids[j])
+		})
+	}
+	if len(ids) == 0 {
+		fmt.Println("no open proposals")
+		return nil
+	}
+
+	for _, id := range ids {
+		proposal, err := voteAll(client, admins, id, !ctx.Bool("reject"), ctx.String("reason"))
+		if err != nil {
+			return err
+		}
+		if proposal.Closed() {
+			color.Green("Proposal %s is %s with %d approve and %d against votes", id, proposal.Status, proposal.ApproveNum, proposal.AgainstNum)
+		} else {
+			color.Yellow("Proposal %s is still open with %d approve and %d against votes, the admin keys goduck has are not enough to close it", id, proposal.ApproveNum, proposal.AgainstNum)
+		}
+	}
+
+	return nil
+}
+
+// voteAll votes on the proposal with the admins which have not voted, until it is closed
+func voteAll(client *bxh.Client, admins []*AdminKey, id string, approve bool, reason string) (*bxh.Proposal, error) {
+	color.Blue("======> Vote on proposal %s", id)
+	proposal, err := client.Proposal(admins[0].Key, id)
+	if err != nil {
+		return nil, fmt.Errorf("get proposal %s: %w", id, err)
+	}
+
+	for _, admin := range admins {
+		if proposal.Closed() {
+			break
+		}
+		if proposal.Voted(admin.Address) {
+			fmt.Printf("%s (%s) has voted\n", admin.Name, admin.Address)
+			continue
+		}
+		if err := client.Vote(admin.Key, id, approve, reason); err != nil {
+			return nil, fmt.Errorf("vote on proposal %s as %s: %w", id, admin.Name, err)
+		}
+		fmt.Printf("%s (%s) voted\n", admin.Name, admin.Address)
+		if proposal, err = client.Proposal(admin.Key, id); err != nil {
+			return nil, fmt.Errorf("get proposal %s: %w", id, err)
+		}
+	}
+
+	return proposal, nil
+}
+
+// governanceClient returns the client of the node selected by the flags and the key signing transactions
+func governanceClient(ctx *cli.Context) (*bxh.Client, crypto2.PrivateKey, error) {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gateway := ctx.String("gateway")
+	if gateway == "" {
+		if gateway, err = localGateway(repoRoot); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if ctx.String("key") != "" {
+		path, err := repo.ResolveKey(repoRoot, ctx.String("key"))
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := readKey(path, "", crypto2.Secp256k1, passwordSource(ctx, ""), true)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bxh.New(gateway), key, nil
+	}
+
+	admins, err := adminKeys(repoRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(admins) == 0 {
+		return nil, nil, fmt.Errorf("no admin keys in %s, specify one with --key", repoRoot)
+	}
+
+	return bxh.New(gateway), admins[0].Key, nil
+}
+
+// localNodes returns the repos of the BitXHub nodes goduck generated, in the order of their names
+func localNodes(repoRoot string) ([]string, error) {
+	configs, err := filepath.Glob(filepath.Join(repoRoot, "bitxhub", ".bitxhub", "*", repo.BitXHubConfigName))
+	if err != nil {
+		return nil, err
+	}
+	var nodes []string
+	for _, config := range configs {
+		nodes = append(nodes, filepath.Dir(config))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return numberedLess(nodes[i], nodes[j])
+	})
+
+	return nodes, nil
+}
+
+// localGateway returns the gateway of the first node goduck generated, or the default one
+func localGateway(repoRoot string) (string, error) {
+	nodes, err := localNodes(repoRoot)
+	if err != nil {
+		return "", err
+	}
+	if len(nodes) == 0 {
+		return fmt.Sprintf("localhost:%d", ports.BaseGateway), nil
+	}
+
+	tree, err := toml.LoadFile(filepath.Join(nodes[0], repo.BitXHubConfigName))
+	if err != nil {
+		return "", fmt.Errorf("load bitxhub config: %w", err)
+	}
+	port, ok := tree.Get("port.gateway").(int64)
+	if !ok {
+		return "", fmt.Errorf("no gateway port in bitxhub config of %s", nodes[0])
+	}
+
+	return fmt.Sprintf("localhost:%d", port), nil
+}
+
+// adminKeys returns the keys of genesis admins goduck generated: the node accounts, and the keys of the key
+// store which are admins. Without a list of admins in the genesis, the node accounts are taken.
+func adminKeys(repoRoot string) ([]*AdminKey, error) {
+	nodes, err := localNodes(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, node := range nodes {
+		candidates = append(candidates, repo.GetPrivKeyPath(repo.KeyPriv, filepath.Join(node, "certs")))
+	}
+	storeKeys, err := filepath.Glob(filepath.Join(repoRoot, repo.KeyDirName, "*"))
+	if err != nil {
+		return nil, err
+	}
+	var admins map[string]bool
+	if len(nodes) != 0 {
+		if admins, err = genesisAdmins(nodes[0]); err != nil {
+			return nil, err
+		}
+	}
+	if len(admins) != 0 {
+		candidates = append(candidates, storeKeys...)
+	}
+
+	var keys []*AdminKey
+	seen := make(map[string]bool)
+	for _, path := range candidates {
+		// keys which are encrypted with other passwords than the default ones are skipped
+		key, err := readKey(path, "", crypto2.Secp256k1, &utils.PasswordSource{}, false)
+		if err != nil {
+			continue
+		}
+		addr, err := key.PublicKey().Address()
+		if err != nil {
+			return nil, err
+		}
+		if seen[addr.String()] || (len(admins) != 0 && !admins[addr.String()]) {
+			continue
+		}
+		seen[addr.String()] = true
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if rel, err := filepath.Rel(filepath.Join(repoRoot, "bitxhub", ".bitxhub"), path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = strings.Split(rel, string(filepath.Separator))[0]
+		}
+		keys = append(keys, &AdminKey{Name: name, Address: addr.String(), Key: key})
+	}
+
+	return keys, nil
+}
+
+// genesisAdmins returns the addresses of the admins in the genesis of the node
+func genesisAdmins(nodeRepo string) (map[string]bool, error) {
+	tree, err := toml.LoadFile(filepath.Join(nodeRepo, repo.BitXHubConfigName))
+	if err != nil {
+		return nil, fmt.Errorf("load bitxhub config: %w", err)
+	}
+
+	admins := make(map[string]bool)
+	list, _ := tree.Get("genesis.admins").([]*toml.Tree)
+	for _, admin := range list {
+		if addr, ok := admin.Get("address").(string); ok {
+			admins[addr] = true
+		}
+	}
+
+	return admins, nil
+}
+
+// numberedLess orders strings ending with numbers like node2 and node10, or proposal ids like 0x...-2 and
+// 0x...-10, by their prefixes and then by their numbers
+func numberedLess(a, b string) bool {
+	prefixA, numA := splitNumber(a)
+	prefixB, numB := splitNumber(b)
+	if prefixA != prefixB {
+		return prefixA < prefixB
+	}
+	if len(numA) != len(numB) {
+		return len(numA) < len(numB)
+	}
+	if numA != numB {
+		return numA < numB
+	}
+
+	return a < b
+}
+
+// splitNumber splits the trailing number off s, without its leading zeros
+func splitNumber(s string) (string, string) {
+	i := len(s)
+	for i > 0 && s[i-1] >= '0' && s[i-1] <= '9' {
+		i--
+	}
+
+	return s[:i], strings.TrimLeft(s[i:], "0")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
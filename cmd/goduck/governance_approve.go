@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/goduck/internal/bxh"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/urfave/cli/v2"
+)
+
+// autoApproveFlags turn on approving the proposals a command creates with the local admin keys
+func autoApproveFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "auto-approve",
+			Usage: "Approve the proposals created by the command with every admin key goduck generated, and wait until they are approved",
+		},
+		&cli.StringFlag{
+			Name:  "gateway",
+			Usage: "Specify the gateway of the BitXHub node goduck talks to (default: the gateway of the first node in $repo/bitxhub/.bitxhub)",
+		},
+		&cli.DurationFlag{
+			Name:  "approve-wait",
+			Value: time.Minute,
+			Usage: "Specify how long the proposals are waited for, first to show up and then to be approved",
+		},
+	}
+}
+
+// withAutoApprove runs action and, if --auto-approve is set, approves the proposals of typ it created with
+// the local admin keys. Proposals are told by not existing before action, and by their proposer if it is
+// given. It returns when they are all approved, so the next step can run straight away.
+func withAutoApprove(ctx *cli.Context, typ, proposer string, action func() error) error {
+	if !ctx.Bool("auto-approve") {
+		return action()
+	}
+
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	gateway := ctx.String("gateway")
+	if gateway == "" {
+		if gateway, err = localGateway(repoRoot); err != nil {
+			return err
+		}
+	}
+	admins, err := adminKeys(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		return fmt.Errorf("no admin keys in %s to approve proposals with", repoRoot)
+	}
+	client := bxh.New(gateway)
+	key := admins[0].Key
+
+	existing, err := client.Proposals(key, "")
+	if err != nil {
+		return fmt.Errorf("list proposals: %w", err)
+	}
+	before := make(map[string]bool)
+	for _, p := range existing {
+		before[p.Id] = true
+	}
+
+	if err := action(); err != nil {
+		return err
+	}
+
+	color.Blue("======> Wait for %s proposals", typ)
+	var created []*bxh.Proposal
+	deadline := time.Now().Add(ctx.Duration("approve-wait"))
+	for {
+		proposals, err := client.Proposals(key, "")
+		if err != nil {
+			return fmt.Errorf("list proposals: %w", err)
+		}
+		created = newProposals(proposals, before, typ, proposer)
+		if len(created) != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("no %s proposal shows up in %s", typ, ctx.Duration("approve-wait"))
+		}
+		time.Sleep(time.Second)
+	}
+
+	for _, p := range created {
+		if err := approveProposal(client, admins, p, time.Until(deadline)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// approveProposal votes for the proposal with the admin keys unless it is closed, and waits until it is approved
+func approveProposal(client *bxh.Client, admins []*AdminKey, p *bxh.Proposal, wait time.Duration) error {
+	if !p.Closed() {
+		if _, err := voteAll(client, admins, p.Id, true, "approved by goduck"); err != nil {
+			return err
+		}
+	}
+
+	return waitProposalClosed(client, admins[0], p.Id, wait)
+}
+
+// checkAutoApprove fails if --auto-approve is set for a pier which does not submit proposals
+func checkAutoApprove(ctx *cli.Context, release *versions.Pier) error {
+	if ctx.Bool("auto-approve") && !release.RegisterByProposal() {
+		return fmt.Errorf("pier %s does not submit proposals, --auto-approve needs v1.6.0+", release.Version)
+	}
+
+	return nil
+}
+
+// newProposals returns the proposals of typ which are not in before. Proposal ids start with the address
+// of their proposer, the ones of proposer are taken if there are any.
+func newProposals(proposals []*bxh.Proposal, before map[string]bool, typ, proposer string) []*bxh.Proposal {
+	var created, proposed []*bxh.Proposal
+	for _, p := range proposals {
+		if before[p.Id] || p.Typ != typ {
+			continue
+		}
+		created = append(created, p)
+		if proposer != "" && strings.HasPrefix(p.Id, proposer+"-") {
+			proposed = append(proposed, p)
+		}
+	}
+	if len(proposed) != 0 {
+		return proposed
+	}
+
+	return created
+}
+
+// waitProposalClosed waits until the proposal is closed, and fails unless it is approved
+func waitProposalClosed(client *bxh.Client, admin *AdminKey, id string, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	for {
+		proposal, err := client.Proposal(admin.Key, id)
+		if err != nil {
+			return fmt.Errorf("get proposal %s: %w", id, err)
+		}
+		switch proposal.Status {
+		case bxh.ProposalApproved:
+			color.Green("Proposal %s of %s is approved", id, proposal.ObjId)
+			return nil
+		case bxh.ProposalProposed:
+			if time.Now().After(deadline) {
+				return fmt.Errorf("proposal %s is still open with %d approve votes of %d needed, the admin keys goduck has are not enough to approve it",
+					id, proposal.ApproveNum, proposal.ThresholdNum)
+			}
+			time.Sleep(time.Second)
+		default:
+			return fmt.Errorf("proposal %s is %s", id, proposal.Status)
+		}
+	}
+}
+
+// pierProposer returns the address of the pier account, which submits the proposals of the pier.
+// It is empty if the key of the pier can not be read.
+func pierProposer(pierRepo string) string {
+	key, err := pierKey(pierRepo)
+	if err != nil {
+		return ""
+	}
+	addr, err := key.PublicKey().Address()
+	if err != nil {
+		return ""
+	}
+
+	return addr.String()
+}
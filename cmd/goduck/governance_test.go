@@ -0,0 +1,31 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNumberedLess(t *testing.T) {
+	tests := []struct {
+		list []string
+		want []string
+	}{
+		{
+			list: []string{"/repo/node10", "/repo/node2", "/repo/node1", "/repo/nodeSolo"},
+			want: []string{"/repo/node1", "/repo/node2", "/repo/node10", "/repo/nodeSolo"},
+		},
+		{
+			list: []string{"0xb-1", "0xa-10", "0xa-9", "0xa-09", "0xa-100"},
+			want: []string{"0xa-09", "0xa-9", "0xa-10", "0xa-100", "0xb-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		sort.Slice(tt.list, func(i, j int) bool {
+			return numberedLess(tt.list[i], tt.list[j])
+		})
+		require.Equal(t, tt.want, tt.list)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"path/filepath"
+
+	"github.com/fatih/color"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/meshplus/goduck/internal/utils"
@@ -65,6 +68,43 @@ func showPierInfo(repoPath string) error {
 		return err
 	}
 
+	return showRegistrations(repoPath)
+}
+
+// showRegistrations prints the appchain registrations of the piers in the repo. Their statuses are brought up
+// to date if BitXHub is reachable.
+func showRegistrations(repoPath string) error {
+	pierRepos, err := filepath.Glob(filepath.Join(repoPath, "pier", ".pier_*"))
+	if err != nil {
+		return err
+	}
+	// without a local BitXHub node the recorded statuses are shown as they are
+	gateway, gatewayErr := localGateway(repoPath)
+
+	rows := [][]string{{"Pier", "Appchain ID", "Proposal ID", "Status"}}
+	for _, pierRepo := range pierRepos {
+		registration, err := loadRegistration(pierRepo)
+		if err != nil {
+			return err
+		}
+		if gatewayErr == nil {
+			// the recorded status is kept if the proposal can not be got
+			if refreshed, err := refreshRegistration(gateway, pierRepo); err == nil {
+				registration = refreshed
+			}
+		}
+		if registration == nil {
+			continue
+		}
+		rows = append(rows, []string{filepath.Base(pierRepo), registration.AppchainID, registration.ProposalID, registration.Status})
+	}
+	if len(rows) == 1 {
+		return nil
+	}
+
+	color.Blue("======> Appchain registrations of piers")
+	PrintTable(rows, true)
+
 	return nil
 }
 
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -15,6 +14,8 @@ import (
 	"github.com/gobuffalo/packr"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
 	"github.com/urfave/cli/v2"
 )
 
@@ -42,10 +43,11 @@ func Initialize(ctx *cli.Context) error {
 	}
 	if fileutil.Exist(repoRoot) {
 		fmt.Println("GoDuck configuration file already exists")
-		fmt.Println("reinitializing would overwrite your configuration, Y/N?")
-		input := bufio.NewScanner(os.Stdin)
-		input.Scan()
-		if input.Text() != "Y" && input.Text() != "y" {
+		overwrite, err := utils.Confirm("reinitializing would overwrite your configuration")
+		if err != nil {
+			return err
+		}
+		if !overwrite {
 			return nil
 		}
 	}
@@ -92,7 +94,7 @@ func ModifyConfigInit(repo string) error {
 	}
 
 	if runtime.GOOS == types.DarwinSystem {
-		for _, v := range bxhConfigMap {
+		for _, v := range versions.BitXHubConfigDirs() {
 			bxhConfigPath := filepath.Join(repo, types.BxhConfigRepo, v, types.BxhModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i '' \"s/REPO/%s/g\" %s", repoTmp, bxhConfigPath)).Run()
 			if err != nil {
@@ -100,7 +102,7 @@ func ModifyConfigInit(repo string) error {
 			}
 		}
 
-		for _, v := range pierConfigMap {
+		for _, v := range versions.PierConfigDirs() {
 			pierConfigPath := filepath.Join(repo, types.PierConfigRepo, v, types.PierModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i '' \"s/REPO/%s/g\" %s", repoTmp, pierConfigPath)).Run()
 			if err != nil {
@@ -108,7 +110,7 @@ func ModifyConfigInit(repo string) error {
 			}
 		}
 	} else if runtime.GOOS == types.LinuxSystem {
-		for _, v := range bxhConfigMap {
+		for _, v := range versions.BitXHubConfigDirs() {
 			bxhConfigPath := filepath.Join(repo, types.BxhConfigRepo, v, types.BxhModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i \"s/REPO/%s/g\" %s", repoTmp, bxhConfigPath)).Run()
 			if err != nil {
@@ -116,7 +118,7 @@ func ModifyConfigInit(repo string) error {
 			}
 		}
 
-		for _, v := range pierConfigMap {
+		for _, v := range versions.PierConfigDirs() {
 			pierConfigPath := filepath.Join(repo, types.PierConfigRepo, v, types.PierModifyConfig)
 			err := sh.Command("/bin/bash", "-c", fmt.Sprintf("sed -i \"s/REPO/%s/g\" %s", repoTmp, pierConfigPath)).Run()
 			if err != nil {
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/fatih/color"
 	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -16,10 +19,17 @@ import (
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/bitxhub/pkg/cert"
 	libp2pcert "github.com/meshplus/go-libp2p-cert"
+	"github.com/meshplus/goduck/internal/hdkey"
 	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/utils"
 	"github.com/urfave/cli/v2"
 )
 
+var keySeedFlag = &cli.StringFlag{
+	Name:  "seed",
+	Usage: "Derive the key from the seed and the key name, the same seed and name always give the same key",
+}
+
 func keyCMD() *cli.Command {
 	return &cli.Command{
 		Name:  "key",
@@ -27,10 +37,44 @@ func keyCMD() *cli.Command {
 		Subcommands: []*cli.Command{
 			Secp256k1(),
 			ECDSA_P256(),
+			keyListCMD(),
+			keyImportCMD(),
+			keyExportCMD(),
+			keyMnemonicCMD(),
+			{
+				Name:      "passwd",
+				Usage:     "Re-encrypt key.json or ethereum keystore files with a new password",
+				ArgsUsage: "<key file>...",
+				Flags:     append(passwordFlags("", "the current password of the key"), passwordFlags("new-", "the new password of the key")...),
+				Action:    changeKeyPassword,
+			},
+		},
+	}
+}
+
+// passwordFlags returns the flags giving a password: --<prefix>password, which can be set by its env var,
+// and --<prefix>password-file
+func passwordFlags(prefix, what string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    prefix + "password",
+			Usage:   fmt.Sprintf("Specify %s, it is asked on the terminal if no password is given", what),
+			EnvVars: []string{"GODUCK_" + strings.ToUpper(strings.ReplaceAll(prefix, "-", "_")) + "PASSWORD"},
+		},
+		&cli.StringFlag{
+			Name:  prefix + "password-file",
+			Usage: fmt.Sprintf("Specify the file whose first line is %s", what),
 		},
 	}
 }
 
+func passwordSource(ctx *cli.Context, prefix string) *utils.PasswordSource {
+	return &utils.PasswordSource{
+		Password: ctx.String(prefix + "password"),
+		File:     ctx.String(prefix + "password-file"),
+	}
+}
+
 func Secp256k1() *cli.Command {
 	return &cli.Command{
 		Name:  "Secp256k1",
@@ -50,6 +94,7 @@ func Secp256k1() *cli.Command {
 						Usage:    "Specific target directory (default: $HOEM/.goduck/key/$name)",
 						Required: false,
 					},
+					keySeedFlag,
 				},
 				Action: func(ctx *cli.Context) error {
 					return generateKey(ctx, crypto2.Secp256k1)
@@ -58,7 +103,7 @@ func Secp256k1() *cli.Command {
 			{
 				Name:  "convert",
 				Usage: "Convert new key file from private key",
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.BoolFlag{
 						Aliases: []string{"save", "s"},
 						Usage:   "Save key into repo",
@@ -68,7 +113,7 @@ func Secp256k1() *cli.Command {
 						Usage:    "Private key path",
 						Required: true,
 					},
-				},
+				}, passwordFlags("", "the password of the key")...),
 				Action: convertKey,
 			},
 			{
@@ -106,6 +151,7 @@ func ECDSA_P256() *cli.Command {
 						Usage:    "Specific target directory (default: $HOEM/.goduck/key/$name)",
 						Required: false,
 					},
+					keySeedFlag,
 				},
 				Action: func(ctx *cli.Context) error {
 					return generateKey(ctx, crypto2.ECDSA_P256)
@@ -136,11 +182,11 @@ func generateKey(ctx *cli.Context, opt crypto2.KeyType) error {
 	name := ctx.String("name")
 	target := ctx.String("target")
 	if target == "" {
-		target = filepath.Join(repoRoot, "key")
+		target = filepath.Join(repoRoot, repo.KeyDirName)
 	}
 	keyPath := filepath.Join(target, fmt.Sprintf("%s.priv", name))
 
-	privKey, err := asym.GenerateKeyPair(opt)
+	privKey, err := hdkey.New(ctx.String("seed")).Child("key").Key(opt, name)
 	if err != nil {
 		return fmt.Errorf("generate key: %w", err)
 	}
@@ -184,13 +230,18 @@ func convertKey(ctx *cli.Context) error {
 		return fmt.Errorf("parse private key: %w", err)
 	}
 
+	password, err := passwordSource(ctx, "").Read("Password of the key", true)
+	if err != nil {
+		return err
+	}
+
 	if ctx.Bool("save") {
 		repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 		if err != nil {
 			return fmt.Errorf("get reporoot: %w", err)
 		}
 
-		keyPath := filepath.Join(repoRoot, "key")
+		keyPath := filepath.Join(repoRoot, repo.KeyDirName)
 		if !fileutil.Exist(keyPath) {
 			err := os.MkdirAll(keyPath, 0755)
 			if err != nil {
@@ -198,13 +249,13 @@ func convertKey(ctx *cli.Context) error {
 			}
 		}
 
-		if err := asym.StorePrivateKey(privKey, filepath.Join(keyPath, repo.KeyName), "bitxhub"); err != nil {
+		if err := asym.StorePrivateKey(privKey, filepath.Join(keyPath, repo.KeyName), password); err != nil {
 			return fmt.Errorf("store private key: %w", err)
 		} else {
 			color.Green("Store converted key in %s successful", filepath.Join(keyPath, repo.KeyName))
 		}
 	} else {
-		keyStore, err := asym.GenKeyStore(privKey, "bitxhub")
+		keyStore, err := asym.GenKeyStore(privKey, password)
 		if err != nil {
 			return err
 		}
@@ -220,6 +271,86 @@ func convertKey(ctx *cli.Context) error {
 	return nil
 }
 
+func changeKeyPassword(ctx *cli.Context) error {
+	if ctx.NArg() == 0 {
+		return fmt.Errorf("expect <key file>...")
+	}
+	current := passwordSource(ctx, "")
+
+	var paths []string
+	for _, path := range ctx.Args().Slice() {
+		// nodes and piers decrypt their key.json with the default password when they start
+		dir := filepath.Dir(path)
+		if filepath.Base(path) == repo.KeyName &&
+			(fileutil.Exist(filepath.Join(dir, repo.BitXHubConfigName)) || fileutil.Exist(filepath.Join(dir, repo.PierConfigName))) {
+			ok, err := utils.Confirm(fmt.Sprintf("%s is read by the node or pier in %s with the default password, which then fails to start, continue?", path, dir))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	password, err := passwordSource(ctx, "new-").Read("New password of the key", true)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := reencryptKey(path, current, password); err != nil {
+			return fmt.Errorf("re-encrypt %s: %w", path, err)
+		}
+		color.Green("%s is encrypted with the new password", path)
+	}
+
+	return nil
+}
+
+// reencryptKey encrypts the key.json or ethereum keystore in path with password
+func reencryptKey(path string, current *utils.PasswordSource, password string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	prompt := fmt.Sprintf("Password of %s", path)
+
+	// ethereum keystores keep the encrypted key in crypto, key.json of BitXHub in cipher
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("parse key: %w", err)
+	}
+	if _, ok := fields["crypto"]; ok {
+		var key *keystore.Key
+		if err := current.Unlock(prompt, []string{""}, func(password string) error {
+			key, err = keystore.DecryptKey(data, password)
+			return err
+		}); err != nil {
+			return fmt.Errorf("decrypt keystore: %w", err)
+		}
+		encrypted, err := keystore.EncryptKey(key, password, keystore.StandardScryptN, keystore.StandardScryptP)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, encrypted, 0600)
+	}
+
+	var privKey crypto2.PrivateKey
+	if err := current.Unlock(prompt, []string{repo.KeyPassword}, func(password string) error {
+		privKey, err = asym.RestorePrivateKey(path, password)
+		return err
+	}); err != nil {
+		return fmt.Errorf("decrypt key: %w", err)
+	}
+
+	return asym.StorePrivateKey(privKey, path, password)
+}
+
 func getPid(ctx *cli.Context) error {
 	privPath := ctx.String("path")
 
@@ -259,7 +390,7 @@ func getPidFromPrivateKey(privPath string) (string, error) {
 func getAddress(ctx *cli.Context) error {
 	privPath := ctx.String("path")
 
-	addr, err := getAddressFromPrivateKey(privPath, crypto.Secp256k1)
+	addr, err := getAddressFromPrivateKey(privPath, crypto2.Secp256k1)
 	if err != nil {
 		return fmt.Errorf("get address from private key: %s", err)
 	}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/hdkey"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/tyler-smith/go-bip39"
+	"github.com/urfave/cli/v2"
+)
+
+// entropy bits of BIP-39 mnemonics by their number of words
+var mnemonicEntropyBits = map[int]int{12: 128, 15: 160, 18: 192, 21: 224, 24: 256}
+
+// DerivedKey is an account derived from a mnemonic into the key store
+type DerivedKey struct {
+	Label   string
+	Path    string
+	Address string
+	File    string
+}
+
+// mnemonicDeriveFlags are the flags deriving accounts from a mnemonic
+var mnemonicDeriveFlags = []cli.Flag{
+	keyTargetFlag,
+	&cli.StringFlag{
+		Name:    "passphrase",
+		Usage:   "Specify the BIP-39 passphrase protecting the mnemonic, empty by default",
+		EnvVars: []string{"GODUCK_MNEMONIC_PASSPHRASE"},
+	},
+	&cli.StringFlag{
+		Name:  "path",
+		Value: hdkey.EthereumPath,
+		Usage: "Specify the BIP-32 path of the accounts, the index of an account is appended to it",
+	},
+	&cli.UintFlag{
+		Name:  "index",
+		Usage: "Specify the index of the first account",
+	},
+	&cli.UintFlag{
+		Name:  "count",
+		Value: 1,
+		Usage: "Specify the number of accounts to derive",
+	},
+	&cli.StringFlag{
+		Name:  "label",
+		Value: "account",
+		Usage: "Specify the label prefix of the accounts, an account is labeled <label>-<index> in the key store",
+	},
+	&cli.StringSliceFlag{
+		Name:  "labels",
+		Usage: "Specify the label of each account in order instead of --label and --count",
+	},
+}
+
+func keyMnemonicCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "mnemonic",
+		Usage: "Create BIP-39 mnemonics and derive Secp256k1 accounts from them into the key store",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "new",
+				Usage: "Create a mnemonic and derive accounts from it",
+				Flags: append([]cli.Flag{
+					&cli.IntFlag{
+						Name:  "words",
+						Value: 12,
+						Usage: "Specify the number of words of the mnemonic, one of 12, 15, 18, 21, 24",
+					},
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "Specify the file to write the mnemonic instead of printing it",
+					},
+				}, mnemonicDeriveFlags...),
+				Action: newMnemonic,
+			},
+			{
+				Name:  "derive",
+				Usage: "Derive accounts from a mnemonic, it is asked on the terminal if no mnemonic is given",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:    "mnemonic",
+						Usage:   "Specify the mnemonic",
+						EnvVars: []string{"GODUCK_MNEMONIC"},
+					},
+					&cli.StringFlag{
+						Name:  "mnemonic-file",
+						Usage: "Specify the file holding the mnemonic",
+					},
+				}, mnemonicDeriveFlags...),
+				Action: deriveMnemonic,
+			},
+		},
+	}
+}
+
+func newMnemonic(ctx *cli.Context) error {
+	bits, ok := mnemonicEntropyBits[ctx.Int("words")]
+	if !ok {
+		return fmt.Errorf("unsupported number of words %d, expect one of 12, 15, 18, 21, 24", ctx.Int("words"))
+	}
+	entropy, err := bip39.NewEntropy(bits)
+	if err != nil {
+		return fmt.Errorf("generate entropy: %w", err)
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return fmt.Errorf("generate mnemonic: %w", err)
+	}
+
+	if output := ctx.String("output"); output != "" {
+		if err := ioutil.WriteFile(output, []byte(mnemonic+"\n"), 0600); err != nil {
+			return fmt.Errorf("write mnemonic: %w", err)
+		}
+		color.Green("Write mnemonic in %s successful", output)
+	} else {
+		color.Yellow("Write down the mnemonic, it is not stored by goduck and recovers all of the accounts:")
+		fmt.Printf("\n%s\n\n", mnemonic)
+	}
+
+	return deriveAccounts(ctx, mnemonic)
+}
+
+func deriveMnemonic(ctx *cli.Context) error {
+	mnemonic := ctx.String("mnemonic")
+	if mnemonic == "" && ctx.String("mnemonic-file") != "" {
+		data, err := ioutil.ReadFile(ctx.String("mnemonic-file"))
+		if err != nil {
+			return fmt.Errorf("read mnemonic file: %w", err)
+		}
+		mnemonic = string(data)
+	}
+	if mnemonic == "" {
+		var err error
+		mnemonic, err = utils.AskSecret("Mnemonic")
+		if err == utils.ErrNoTerminal {
+			return fmt.Errorf("no mnemonic is given, use --mnemonic, its env var or --mnemonic-file")
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return deriveAccounts(ctx, strings.Join(strings.Fields(mnemonic), " "))
+}
+
+// deriveAccounts derives the accounts selected by the flags from mnemonic and stores them as pem keys named
+// by their labels, so other commands refer to them by label
+func deriveAccounts(ctx *cli.Context, mnemonic string) error {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, ctx.String("passphrase"))
+	if err != nil {
+		return fmt.Errorf("invalid mnemonic: %w", err)
+	}
+	basePath := strings.TrimSuffix(ctx.String("path"), "/")
+	if _, err := hdkey.ParsePath(basePath); err != nil {
+		return err
+	}
+	dir, err := keyDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	var labels []string
+	for _, value := range ctx.StringSlice("labels") {
+		for _, label := range strings.Split(value, ",") {
+			labels = append(labels, strings.TrimSpace(label))
+		}
+	}
+	if len(labels) == 0 {
+		for i := uint(0); i < ctx.Uint("count"); i++ {
+			labels = append(labels, fmt.Sprintf("%s-%d", ctx.String("label"), ctx.Uint("index")+i))
+		}
+	}
+	if err := checkLabels(labels); err != nil {
+		return err
+	}
+
+	var keys []*DerivedKey
+	var existing []string
+	for i, label := range labels {
+		key := &DerivedKey{
+			Label: label,
+			Path:  fmt.Sprintf("%s/%d", basePath, ctx.Uint("index")+uint(i)),
+			File:  filepath.Join(dir, label+".priv"),
+		}
+		keys = append(keys, key)
+		if fileutil.Exist(key.File) {
+			existing = append(existing, key.File)
+		}
+	}
+	if len(existing) != 0 {
+		overwrite, err := utils.Confirm(fmt.Sprintf("keys %s already exist, overwrite them", strings.Join(existing, ", ")))
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return nil
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create folder: %w", err)
+	}
+	for _, key := range keys {
+		path, err := hdkey.ParsePath(key.Path)
+		if err != nil {
+			return err
+		}
+		std, err := hdkey.BIP32(seed, path)
+		if err != nil {
+			return fmt.Errorf("derive %s: %w", key.Path, err)
+		}
+		privKey, err := asym.PrivateKeyFromStdKey(std)
+		if err != nil {
+			return err
+		}
+		encoded, err := encodeKey(privKey, keyFormatPEM, "")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(key.File, encoded, 0600); err != nil {
+			return err
+		}
+		addr, err := privKey.PublicKey().Address()
+		if err != nil {
+			return err
+		}
+		key.Address = addr.String()
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+	table := [][]string{{"Label", "Path", "Address", "File"}}
+	for _, key := range keys {
+		table = append(table, []string{key.Label, key.Path, key.Address, key.File})
+	}
+	PrintTable(table, true)
+	color.Green("Derive accounts into %s successful", dir)
+
+	return nil
+}
+
+// checkLabels checks that the labels name distinct files in the key store, so no key is written outside of it
+// or over another key derived in the same run
+func checkLabels(labels []string) error {
+	seen := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		if label == "" {
+			return fmt.Errorf("empty label")
+		}
+		if strings.ContainsAny(label, `/\`) || strings.Contains(label, "..") {
+			return fmt.Errorf("invalid label %s, labels can not contain path separators or ..", label)
+		}
+		if seen[label] {
+			return fmt.Errorf("duplicate label %s", label)
+		}
+		seen[label] = true
+	}
+
+	return nil
+}
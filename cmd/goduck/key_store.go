@@ -0,0 +1,572 @@
+package main
+
+import (
+	ecdsa2 "crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/fatih/color"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	crypto2 "github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/bitxhub-kit/crypto/asym/ecdsa"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/pborman/uuid"
+	"github.com/urfave/cli/v2"
+)
+
+// formats of key files
+const (
+	keyFormatPEM         = "pem"
+	keyFormatHex         = "hex"
+	keyFormatKeystore    = "keystore"
+	keyFormatBitXHubJSON = "bitxhub-json"
+)
+
+var keyFormats = []string{keyFormatPEM, keyFormatHex, keyFormatKeystore, keyFormatBitXHubJSON}
+
+var keyCurves = map[crypto2.KeyType]elliptic.Curve{
+	crypto2.Secp256k1:  ecdsa.S256(),
+	crypto2.ECDSA_P256: elliptic.P256(),
+	crypto2.ECDSA_P384: elliptic.P384(),
+	crypto2.ECDSA_P521: elliptic.P521(),
+}
+
+// keyTypes are the key types of crypto2.KeyType which keys can be generated and converted with
+var keyTypes = map[string]crypto2.KeyType{
+	"Secp256k1":  crypto2.Secp256k1,
+	"ECDSA_P256": crypto2.ECDSA_P256,
+	"ECDSA_P384": crypto2.ECDSA_P384,
+	"ECDSA_P521": crypto2.ECDSA_P521,
+}
+
+// KeyInfo is a key found in the key directory
+type KeyInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Format  string `json:"format"`
+	Address string `json:"address"`
+	Pid     string `json:"pid"`
+	Path    string `json:"path"`
+}
+
+var keyTargetFlag = &cli.StringFlag{
+	Name:  "target",
+	Usage: "Specify the key directory (default: $repo/key)",
+}
+
+var keyTypeFlag = &cli.StringFlag{
+	Name:  "type",
+	Value: "Secp256k1",
+	Usage: fmt.Sprintf("Specify the type of a hex key, one of %s", strings.Join(keyTypeNames(), ", ")),
+}
+
+func keyListCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "list",
+		Usage: "List keys in the key directory with their type, address and libp2p pid",
+		Flags: append([]cli.Flag{
+			keyTargetFlag,
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "List keys in json",
+			},
+		}, passwordFlags("", "the password of the key")...),
+		Action: keyList,
+	}
+}
+
+func keyImportCMD() *cli.Command {
+	return &cli.Command{
+		Name:      "import",
+		Usage:     "Import a key into the key directory as a pem private key",
+		ArgsUsage: "<key file>",
+		Flags: append([]cli.Flag{
+			keyTargetFlag,
+			&cli.StringFlag{
+				Name:     "format",
+				Usage:    fmt.Sprintf("Specify the format of the key file, one of %s", strings.Join(keyFormats, ", ")),
+				Required: true,
+			},
+			keyTypeFlag,
+			&cli.StringFlag{
+				Name:  "name",
+				Usage: "Specify the name of the imported key (default: the file name)",
+			},
+		}, passwordFlags("", "the password of the key")...),
+		Action: keyImport,
+	}
+}
+
+func keyExportCMD() *cli.Command {
+	return &cli.Command{
+		Name:      "export",
+		Usage:     "Export a key in another format",
+		ArgsUsage: "<key name|key file>",
+		Flags: append(append([]cli.Flag{
+			keyTargetFlag,
+			&cli.StringFlag{
+				Name:     "format",
+				Usage:    fmt.Sprintf("Specify the format to export, one of %s", strings.Join(keyFormats, ", ")),
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Usage: "Specify the file to write the exported key (default: stdout)",
+			},
+			keyTypeFlag,
+		}, passwordFlags("", "the password of the key")...), passwordFlags("export-", "the password of the exported key")...),
+		Action: keyExport,
+	}
+}
+
+func keyTypeNames() []string {
+	var names []string
+	for name := range keyTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func keyTypeName(typ crypto2.KeyType) string {
+	for name, t := range keyTypes {
+		if t == typ {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("unknown(%d)", typ)
+}
+
+func parseKeyType(name string) (crypto2.KeyType, error) {
+	for n, t := range keyTypes {
+		if strings.EqualFold(n, name) {
+			return t, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unsupported key type %s, expect one of %s", name, strings.Join(keyTypeNames(), ", "))
+}
+
+func keyDir(ctx *cli.Context) (string, error) {
+	if target := ctx.String("target"); target != "" {
+		return target, nil
+	}
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(repoRoot, repo.KeyDirName), nil
+}
+
+// detectKeyFormat tells the format of a key file by its content
+func detectKeyFormat(data []byte) (string, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		return keyFormatPEM, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err == nil {
+		if _, ok := fields["crypto"]; ok {
+			return keyFormatKeystore, nil
+		}
+		if _, ok := fields["cipher"]; ok {
+			return keyFormatBitXHubJSON, nil
+		}
+		return "", fmt.Errorf("unknown json key")
+	}
+
+	if raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x")); err == nil && len(raw) != 0 {
+		return keyFormatHex, nil
+	}
+
+	return "", fmt.Errorf("unknown key format")
+}
+
+// readKey parses the key file in format, which is detected if it is empty, typ is the type of hex keys
+// which do not tell it. Encrypted keys are opened with the given password, or with the default ones and
+// then the password asked on the terminal if prompt is set.
+func readKey(path, format string, typ crypto2.KeyType, src *utils.PasswordSource, prompt bool) (crypto2.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key: %w", err)
+	}
+	if format == "" {
+		if format, err = detectKeyFormat(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	unlock := func(defaults []string, open func(password string) error) error {
+		if prompt {
+			return src.Unlock("Password of the key", defaults, open)
+		}
+		if src.Given() {
+			password, err := src.Read("", false)
+			if err != nil {
+				return err
+			}
+			return open(password)
+		}
+		for _, password := range defaults {
+			if open(password) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("encrypted")
+	}
+
+	switch format {
+	case keyFormatPEM:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no pem block")
+		}
+		// pem keys of goduck hold raw Secp256k1 keys or DER encoded keys of the other curves
+		if std, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+			return asym.PrivateKeyFromStdKey(std)
+		}
+		return ecdsa.UnmarshalPrivateKey(block.Bytes, crypto2.Secp256k1)
+	case keyFormatHex:
+		raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("decode hex: %w", err)
+		}
+		return scalarToKey(raw, typ)
+	case keyFormatKeystore:
+		var key *keystore.Key
+		err := unlock([]string{""}, func(password string) error {
+			var err error
+			key, err = keystore.DecryptKey(data, password)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("decrypt keystore: %w", err)
+		}
+		// the curve of go-ethereum is not the Secp256k1 of bitxhub-kit, so the key is rebuilt from its scalar
+		return scalarToKey(key.PrivateKey.D.Bytes(), crypto2.Secp256k1)
+	case keyFormatBitXHubJSON:
+		var privKey crypto2.PrivateKey
+		err := unlock([]string{repo.KeyPassword}, func(password string) error {
+			privKey, err = asym.RestorePrivateKey(path, password)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("decrypt key: %w", err)
+		}
+		return privKey, nil
+	default:
+		return nil, fmt.Errorf("unsupported key format %s, expect one of %s", format, strings.Join(keyFormats, ", "))
+	}
+}
+
+// scalarToKey builds the private key of the curve of typ from its scalar
+func scalarToKey(d []byte, typ crypto2.KeyType) (crypto2.PrivateKey, error) {
+	curve, ok := keyCurves[typ]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %s", keyTypeName(typ))
+	}
+	k := new(big.Int).SetBytes(d)
+	if len(d) > (curve.Params().BitSize+7)/8 || k.Sign() == 0 || k.Cmp(curve.Params().N) >= 0 {
+		return nil, fmt.Errorf("invalid %s private key", keyTypeName(typ))
+	}
+
+	std := &ecdsa2.PrivateKey{D: k}
+	std.Curve = curve
+	std.X, std.Y = curve.ScalarBaseMult(d)
+
+	return asym.PrivateKeyFromStdKey(std)
+}
+
+// encodeKey encodes the key in format, encrypted formats are encrypted with password
+func encodeKey(privKey crypto2.PrivateKey, format, password string) ([]byte, error) {
+	switch format {
+	case keyFormatPEM:
+		raw, err := privKey.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: raw}), nil
+	case keyFormatHex:
+		std, err := asym.PrivKeyToStdKey(privKey)
+		if err != nil {
+			return nil, err
+		}
+		size := (std.Curve.Params().BitSize + 7) / 8
+		return []byte(hex.EncodeToString(ecdsa.PaddedBigBytes(std.D, size)) + "\n"), nil
+	case keyFormatKeystore:
+		if privKey.Type() != crypto2.Secp256k1 {
+			return nil, fmt.Errorf("ethereum keystores only hold Secp256k1 keys, the key is %s", keyTypeName(privKey.Type()))
+		}
+		std, err := asym.PrivKeyToStdKey(privKey)
+		if err != nil {
+			return nil, err
+		}
+		addr, err := privKey.PublicKey().Address()
+		if err != nil {
+			return nil, err
+		}
+		key := &keystore.Key{Id: uuid.NewRandom(), PrivateKey: &std}
+		copy(key.Address[:], addr.Bytes())
+		return keystore.EncryptKey(key, password, keystore.StandardScryptN, keystore.StandardScryptP)
+	case keyFormatBitXHubJSON:
+		keyStore, err := asym.GenKeyStore(privKey, password)
+		if err != nil {
+			return nil, err
+		}
+		return json.MarshalIndent(keyStore, "", " ")
+	default:
+		return nil, fmt.Errorf("unsupported key format %s, expect one of %s", format, strings.Join(keyFormats, ", "))
+	}
+}
+
+// keyPid returns the libp2p peer id of the key
+func keyPid(privKey crypto2.PrivateKey) (string, error) {
+	std, err := asym.PrivKeyToStdKey(privKey)
+	if err != nil {
+		return "", err
+	}
+
+	var pk crypto.PubKey
+	if privKey.Type() == crypto2.Secp256k1 {
+		// libp2p encodes Secp256k1 keys by their own type, which x509 does not know
+		sk, err := crypto.UnmarshalSecp256k1PrivateKey(ecdsa.PaddedBigBytes(std.D, 32))
+		if err != nil {
+			return "", err
+		}
+		pk = sk.GetPublic()
+	} else {
+		if _, pk, err = crypto.KeyPairFromStdKey(&std); err != nil {
+			return "", err
+		}
+	}
+
+	pid, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return "", err
+	}
+
+	return pid.String(), nil
+}
+
+func keyList(ctx *cli.Context) error {
+	dir, err := keyDir(ctx)
+	if err != nil {
+		return err
+	}
+	src := passwordSource(ctx, "")
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read key directory: %w", err)
+	}
+
+	var keys []*KeyInfo
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		format, err := detectKeyFormat(data)
+		if err != nil {
+			continue
+		}
+		info := &KeyInfo{
+			Name:   strings.TrimSuffix(file.Name(), filepath.Ext(file.Name())),
+			Type:   "-",
+			Format: format,
+			// the address of ethereum keystores is readable without the password
+			Address: keystoreAddress(data),
+			Pid:     "-",
+			Path:    path,
+		}
+		keys = append(keys, info)
+
+		privKey, err := readKey(path, format, crypto2.Secp256k1, src, false)
+		if err != nil {
+			if info.Address == "" {
+				info.Address = "-"
+			}
+			continue
+		}
+		info.Type = keyTypeName(privKey.Type())
+		addr, err := privKey.PublicKey().Address()
+		if err != nil {
+			return err
+		}
+		info.Address = addr.String()
+		if pid, err := keyPid(privKey); err == nil {
+			info.Pid = pid
+		}
+	}
+
+	if ctx.Bool("json") {
+		if keys == nil {
+			keys = []*KeyInfo{}
+		}
+		data, err := json.MarshalIndent(keys, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(keys) == 0 {
+		fmt.Printf("no keys in %s\n", dir)
+		return nil
+	}
+	table := [][]string{{"Name", "Type", "Format", "Address", "Pid", "Path"}}
+	for _, k := range keys {
+		table = append(table, []string{k.Name, k.Type, k.Format, k.Address, k.Pid, k.Path})
+	}
+	PrintTable(table, true)
+
+	return nil
+}
+
+// keystoreAddress returns the address recorded in an ethereum keystore
+func keystoreAddress(data []byte) string {
+	var ks struct {
+		Address string `json:"address"`
+		Crypto  json.RawMessage
+	}
+	if err := json.Unmarshal(data, &ks); err != nil || ks.Crypto == nil || ks.Address == "" {
+		return ""
+	}
+
+	return "0x" + strings.TrimPrefix(ks.Address, "0x")
+}
+
+func keyImport(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expect <key file>")
+	}
+	path := ctx.Args().First()
+	dir, err := keyDir(ctx)
+	if err != nil {
+		return err
+	}
+	typ, err := parseKeyType(ctx.String("type"))
+	if err != nil {
+		return err
+	}
+
+	privKey, err := readKey(path, ctx.String("format"), typ, passwordSource(ctx, ""), true)
+	if err != nil {
+		return err
+	}
+
+	name := ctx.String("name")
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	keyPath := filepath.Join(dir, fmt.Sprintf("%s.priv", name))
+	if fileutil.Exist(keyPath) {
+		overwrite, err := utils.Confirm(fmt.Sprintf("key %s already exists, overwrite it", keyPath))
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return nil
+		}
+	}
+
+	encoded, err := encodeKey(privKey, keyFormatPEM, "")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create folder: %w", err)
+	}
+	if err := ioutil.WriteFile(keyPath, encoded, 0600); err != nil {
+		return err
+	}
+	addr, err := privKey.PublicKey().Address()
+	if err != nil {
+		return err
+	}
+	color.Green("Import %s key %s with address %s in %s successful", keyTypeName(privKey.Type()), name, addr.String(), keyPath)
+
+	return nil
+}
+
+func keyExport(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("expect <key name|key file>")
+	}
+	dir, err := keyDir(ctx)
+	if err != nil {
+		return err
+	}
+
+	// a key name is looked up in the key directory
+	path := ctx.Args().First()
+	if !fileutil.Exist(path) {
+		matches, err := filepath.Glob(filepath.Join(dir, path+".*"))
+		if err != nil {
+			return err
+		}
+		if len(matches) != 1 {
+			return fmt.Errorf("key %s is not a file and there are %d keys named so in %s", path, len(matches), dir)
+		}
+		path = matches[0]
+	}
+
+	typ, err := parseKeyType(ctx.String("type"))
+	if err != nil {
+		return err
+	}
+	privKey, err := readKey(path, "", typ, passwordSource(ctx, ""), true)
+	if err != nil {
+		return err
+	}
+
+	password := ""
+	exportFormat := ctx.String("format")
+	if exportFormat == keyFormatKeystore && privKey.Type() != crypto2.Secp256k1 {
+		return fmt.Errorf("ethereum keystores only hold Secp256k1 keys, the key is %s", keyTypeName(privKey.Type()))
+	}
+	if exportFormat == keyFormatKeystore || exportFormat == keyFormatBitXHubJSON {
+		if password, err = passwordSource(ctx, "export-").Read("Password of the exported key", true); err != nil {
+			return err
+		}
+	}
+	encoded, err := encodeKey(privKey, exportFormat, password)
+	if err != nil {
+		return err
+	}
+
+	output := ctx.String("output")
+	if output == "" {
+		fmt.Print(string(encoded))
+		return nil
+	}
+	if err := ioutil.WriteFile(output, encoded, 0600); err != nil {
+		return err
+	}
+	color.Green("Export key %s as %s in %s successful", path, exportFormat, output)
+
+	return nil
+}
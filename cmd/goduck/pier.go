@@ -1,9 +1,7 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -13,18 +11,14 @@ import (
 
 	"github.com/meshplus/bitxhub-kit/fileutil"
 	"github.com/meshplus/goduck/cmd/goduck/pier"
+	"github.com/meshplus/goduck/internal/hdkey"
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
 	"github.com/urfave/cli/v2"
 )
 
-var pierConfigMap = map[string]string{
-	"v1.6.1": "v1.6.1",
-	"v1.7.0": "v1.6.1",
-	"v1.8.0": "v1.8.0",
-	"v1.9.0": "v1.8.0",
-}
-
 var pierCMD = &cli.Command{
 	Name:  "pier",
 	Usage: "Operation about pier",
@@ -62,7 +56,7 @@ var pierCMD = &cli.Command{
 		{
 			Name:  "register",
 			Usage: "Register pier to BitXHub",
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
 					Usage: "Specify appchain type, one of ethereum or fabric",
@@ -73,9 +67,9 @@ var pierCMD = &cli.Command{
 					Usage: "Specify the startup path of the pier (default:$repo/pier/.pier_$chainType)",
 				},
 				&cli.StringFlag{
-					Name:  "upType",
-					Usage: "Specify the startup type, one of binary or docker",
-					Value: types.TypeBinary,
+					Name:   "upType",
+					Usage:  "Ignored, the appchain is registered through the gateway of BitXHub whatever the startup type is",
+					Hidden: true,
 				},
 				&cli.StringFlag{
 					Name:  "method",
@@ -83,21 +77,22 @@ var pierCMD = &cli.Command{
 					Value: "appchain",
 				},
 				&cli.StringFlag{
-					Name:  "cid",
-					Usage: "Specify the contanierID of the pier, only useful for docker",
+					Name:   "cid",
+					Usage:  "Ignored, the appchain is registered through the gateway of BitXHub whatever the startup type is",
+					Hidden: true,
 				},
 				&cli.StringFlag{
 					Aliases: []string{"version", "v"},
 					Value:   "v1.6.1",
 					Usage:   "Pier version",
 				},
-			},
+			}, autoApproveFlags()...),
 			Action: pierRegister,
 		},
 		{
 			Name:  "rule",
 			Usage: "deploy rule to BitXHub",
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				&cli.StringFlag{
 					Name:  "appchain",
 					Usage: "Specify appchain type, one of ethereum or fabric",
@@ -105,20 +100,21 @@ var pierCMD = &cli.Command{
 				},
 				&cli.StringFlag{
 					Name:  "pierRepo",
-					Usage: "Specify the startup path of the pier, only useful for binary (default:$repo/pier/.pier_$chainType)",
+					Usage: "Specify the startup path of the pier (default:$repo/pier/.pier_$chainType)",
 				},
 				&cli.StringFlag{
-					Name:  "cid",
-					Usage: "Specify the contanierID of the pier, only useful for docker",
+					Name:   "cid",
+					Usage:  "Ignored, the rule is deployed through the gateway of BitXHub whatever the startup type is",
+					Hidden: true,
 				},
 				&cli.StringFlag{
 					Name:  "ruleRepo",
 					Usage: "Specify the path of the rule (default:$repo/pier/.pier_$chainType/$chainType/validating.wasm)",
 				},
 				&cli.StringFlag{
-					Name:  "upType",
-					Usage: "Specify the startup type, one of binary or docker",
-					Value: types.TypeBinary,
+					Name:   "upType",
+					Usage:  "Ignored, the rule is deployed through the gateway of BitXHub whatever the startup type is",
+					Hidden: true,
 				},
 				&cli.StringFlag{
 					Name:  "method",
@@ -130,7 +126,7 @@ var pierCMD = &cli.Command{
 					Value:   "v1.6.1",
 					Usage:   "Pier version",
 				},
-			},
+			}, autoApproveFlags()...),
 			Action: pierRuleDeploy,
 		},
 		{
@@ -142,6 +138,7 @@ var pierCMD = &cli.Command{
 					Usage: "Specify appchain type, one of ethereum or fabric",
 					Value: types.ChainTypeEther,
 				},
+				stopTimeoutFlag,
 			},
 			Action: pierStop,
 		},
@@ -154,6 +151,7 @@ var pierCMD = &cli.Command{
 					Usage: "Specify appchain type, one of ethereum or fabric",
 					Value: types.ChainTypeEther,
 				},
+				stopTimeoutFlag,
 			},
 			Action: pierClean,
 		},
@@ -184,6 +182,10 @@ var pierCMD = &cli.Command{
 					Value:   "v1.6.1",
 					Usage:   "Pier version",
 				},
+				&cli.StringFlag{
+					Name:  "seed",
+					Usage: "Derive the pier keys from the seed and the appchain type, so that the same seed always gives the same account and pid",
+				},
 			},
 			Action: generatePierConfig,
 		},
@@ -202,20 +204,11 @@ func pierStart(ctx *cli.Context) error {
 		return err
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	release, err := versions.LookupPier(version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
-	}
-
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport pier verison")
-	}
-
 	if pierRepo == "" {
 		pierRepo = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
 	}
@@ -227,7 +220,7 @@ func pierStart(ctx *cli.Context) error {
 	}
 
 	if configPath == "" {
-		configPath = filepath.Join(repoRoot, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, pierConfigMap[version], types.PierModifyConfig))
+		configPath = filepath.Join(repoRoot, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, release.ConfigDir, types.PierModifyConfig))
 	}
 
 	if err := pier.DownloadPierBinary(repoRoot, version, runtime.GOOS); err != nil {
@@ -241,105 +234,116 @@ func pierStart(ctx *cli.Context) error {
 		return fmt.Errorf("download pier binary error:%w", err)
 	}
 
+	if upType == types.TypeDocker {
+		if err := configPier(repoRoot, pierRepo, configPath, chainType, version, upType, ""); err != nil {
+			return err
+		}
+		httpPort, err := utils.GetModifyConfigValue(configPath, "httpPort")
+		if err != nil {
+			return err
+		}
+		pprofPort, err := utils.GetModifyConfigValue(configPath, "pprofPort")
+		if err != nil {
+			return err
+		}
+		return pier.StartPierDocker(repoRoot, chainType, pierRepo, version, httpPort, pprofPort)
+	}
+
 	return pier.StartPier(repoRoot, chainType, pierRepo, upType, configPath, version)
 }
 
 func pierRegister(ctx *cli.Context) error {
 	chainType := ctx.String("appchain")
-	upType := ctx.String("upType")
 	method := ctx.String("method")
 	pierRepo := ctx.String("pierRepo")
 	version := ctx.String("version")
-	cid := ctx.String("cid")
 
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
 		return err
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	release, err := versions.LookupPier(version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
+	if pierRepo == "" {
+		pierRepo = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
 	}
 
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport pier verison")
+	if !fileutil.Exist(pierRepo) {
+		return fmt.Errorf("the pier startup path(%s) does not exist", pierRepo)
 	}
 
-	if pierRepo == "" {
-		pierRepo = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
+	if err := checkAutoApprove(ctx, release); err != nil {
+		return err
 	}
 
-	if upType == types.TypeBinary && !fileutil.Exist(pierRepo) {
-		return fmt.Errorf("the pier startup path(%s) does not have a startup binary", pierRepo)
+	gateway := ctx.String("gateway")
+	if gateway == "" {
+		if gateway, err = localGateway(repoRoot); err != nil {
+			return err
+		}
 	}
 
-	if upType == types.TypeDocker && cid == "" {
-		return fmt.Errorf("Docker mode needs to specify CID (you can find it by using the conmand `goduck status list`)")
+	err = withAutoApprove(ctx, "AppchainMgr", pierProposer(pierRepo), func() error {
+		_, err := registerAppchain(gateway, pierRepo, chainType, method, release)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	if upType == types.TypeBinary {
-		if err := pier.DownloadPierBinary(repoRoot, version, runtime.GOOS); err != nil {
-			return fmt.Errorf("download pier binary error:%w", err)
+	if ctx.Bool("auto-approve") {
+		if _, err := refreshRegistration(gateway, pierRepo); err != nil {
+			return err
 		}
-		binPath := filepath.Join(repoRoot, fmt.Sprintf("bin/%s", fmt.Sprintf("pier_%s_%s", runtime.GOOS, version)))
-		color.Blue("pier binary path: %s", binPath)
+	} else {
+		color.Blue("Waiting for the administrators of BitXHub to vote for approval. If approved, use the 'goduck pier rule' command to deploy rule to bitxhub")
 	}
 
-	return pier.RegisterPier(repoRoot, pierRepo, chainType, upType, method, version, cid)
-	//return pier.RegisterPier(repoRoot, chainType, cryptoPath, pierUpType, version, tls, http, pport, aport, overwrite, appchainIP, appchainAddr, appchainPorts, appchainContractAddr, pierRepo, adminKey, method)
+	return nil
 }
 
 func pierRuleDeploy(ctx *cli.Context) error {
 	chainType := ctx.String("appchain")
 	pierRepo := ctx.String("pierRepo")
 	ruleRepo := ctx.String("ruleRepo")
-	upType := ctx.String("upType")
 	method := ctx.String("method")
 	version := ctx.String("version")
-	cid := ctx.String("cid")
 
 	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
 	if err != nil {
 		return err
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
+	release, err := versions.LookupPier(version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
-	}
-
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport pier verison")
-	}
-
 	if pierRepo == "" {
 		pierRepo = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
 	}
 
-	if upType == types.TypeBinary && !fileutil.Exist(pierRepo) {
-		return fmt.Errorf("the pier startup path(%s) does not have a startup binary", pierRepo)
+	if err := checkAutoApprove(ctx, release); err != nil {
+		return err
 	}
 
-	if upType == types.TypeDocker && cid == "" {
-		return fmt.Errorf("Docker mode needs to specify CID (you can find it by using the conmand `goduck status list`)")
+	if ruleRepo == "" {
+		ruleRepo = filepath.Join(pierRepo, chainType, types.RuleName)
 	}
 
-	if ruleRepo == "" {
-		ruleRepo = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s/%s/validating.wasm", chainType, chainType))
+	t, err := newAppchainTarget(repoRoot, ctx.String("gateway"), pierRepo, chainType, method, "", release)
+	if err != nil {
+		return err
 	}
 
-	return pier.DeployRule(repoRoot, chainType, pierRepo, ruleRepo, upType, method, version, cid)
+	return withAutoApprove(ctx, "RuleMgr", pierProposer(pierRepo), func() error {
+		_, err := deployRule(t, ruleRepo)
+		return err
+	})
 }
 
 func pierStop(ctx *cli.Context) error {
@@ -350,7 +354,7 @@ func pierStop(ctx *cli.Context) error {
 		return err
 	}
 
-	return pier.StopPier(repoRoot, chainType)
+	return pier.StopPier(repoRoot, chainType, ctx.Duration("timeout"))
 }
 
 func pierClean(ctx *cli.Context) error {
@@ -361,7 +365,7 @@ func pierClean(ctx *cli.Context) error {
 		return err
 	}
 
-	return pier.CleanPier(repoRoot, chainType)
+	return pier.CleanPier(repoRoot, chainType, ctx.Duration("timeout"))
 }
 
 func generatePierConfig(ctx *cli.Context) error {
@@ -379,22 +383,28 @@ func generatePierConfig(ctx *cli.Context) error {
 		return fmt.Errorf("please `goduck init` first")
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoPath, "release.json"))
+	release, err := versions.LookupPier(version)
 	if err != nil {
 		return err
 	}
 
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
-		return err
+	if target == "" {
+		target = filepath.Join(repoPath, fmt.Sprintf("pier/.pier_%s", chainType))
 	}
 
-	if !AdjustVersion(version, release.Pier) {
-		return fmt.Errorf("unsupport Pier verison")
+	if configPath == "" {
+		configPath = filepath.Join(repoPath, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, release.ConfigDir, types.PierModifyConfig))
 	}
 
-	if target == "" {
-		target = filepath.Join(repoPath, fmt.Sprintf("pier/.pier_%s", chainType))
+	return configPier(repoPath, target, configPath, chainType, version, upType, ctx.String("seed"))
+}
+
+// configPier generates configuration of pier into target by the modify config,
+// the pier keys are derived from seed if it is not empty
+func configPier(repoPath, target, configPath, chainType, version, upType, seed string) error {
+	release, err := versions.LookupPier(version)
+	if err != nil {
+		return err
 	}
 
 	if _, err := os.Stat(target); os.IsNotExist(err) {
@@ -403,10 +413,6 @@ func generatePierConfig(ctx *cli.Context) error {
 		}
 	}
 
-	if configPath == "" {
-		configPath = filepath.Join(repoPath, fmt.Sprintf("%s/%s/%s", types.PierConfigRepo, pierConfigMap[version], types.PierModifyConfig))
-	}
-
 	if err := pier.DownloadPierBinary(repoPath, version, runtime.GOOS); err != nil {
 		return fmt.Errorf("download pier binary error:%w", err)
 	}
@@ -421,7 +427,20 @@ func generatePierConfig(ctx *cli.Context) error {
 	pluginPath := filepath.Join(repoPath, fmt.Sprintf("bin/%s", fmt.Sprintf("pier_%s_%s", pluginSys, version)))
 	color.Blue("pier binary path: %s", binPath)
 
-	return pier.GeneratePier(filepath.Join(repoPath, types.PierConfigRepo, pierConfigMap[version], types.PierConfigScript), repoPath, target, configPath, chainType, binPath, pluginPath)
+	if err := pier.GeneratePier(filepath.Join(repoPath, types.PierConfigRepo, release.ConfigDir, types.PierConfigScript), repoPath, target, configPath, chainType, binPath, pluginPath); err != nil {
+		return err
+	}
+
+	if keys := hdkey.New(seed); keys != nil {
+		// the keys pier generated are replaced, as the generator of `goduck up` derives them
+		pid, err := writeSeededPierKeys(target, keys.Child(types.Pier, chainType), release.HasNodeKey())
+		if err != nil {
+			return fmt.Errorf("derive pier keys: %w", err)
+		}
+		color.Green("pier keys are derived from the seed, the pid is %s", pid)
+	}
+
+	return nil
 }
 
 // TODO: delete
@@ -0,0 +1,145 @@
+package pier
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/goduck/internal/orchestrator"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+)
+
+// ContainerName returns the name of the pier container of the appchain
+func ContainerName(appchainType string) string {
+	return fmt.Sprintf("pier-%s", appchainType)
+}
+
+// StartPierDocker starts pier of the appchain in a container labeled with the project ID of repo,
+// the configuration in pierRepo must have been generated
+func StartPierDocker(repoRoot, appchainType, pierRepo, version, httpPort, pprofPort string) error {
+	o, err := orchestrator.New(repo.ProjectID(repoRoot))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	containers, err := o.List(ctx, types.Pier)
+	if err != nil {
+		return err
+	}
+	name := ContainerName(appchainType)
+	for _, c := range containers {
+		if c.Labels[orchestrator.LabelName] == name {
+			return fmt.Errorf("%s container already exists, please clean them first", name)
+		}
+	}
+
+	if err := copyDockerScripts(repoRoot, pierRepo); err != nil {
+		return err
+	}
+
+	if err := replaceInFile(filepath.Join(pierRepo, types.PierConfigName), "localhost", "host.docker.internal"); err != nil {
+		return err
+	}
+	if err := replaceInFile(filepath.Join(pierRepo, appchainType, appchainType+".toml"), "127.0.0.1", "host.docker.internal"); err != nil {
+		return err
+	}
+
+	color.Blue("======> Start pier of %s-%s in docker...", appchainType, version)
+	cid, err := o.Up(ctx, &orchestrator.ContainerSpec{
+		Name:       name,
+		Component:  types.Pier,
+		Image:      fmt.Sprintf(types.PierImage, version),
+		WorkingDir: "/root/.pier",
+		Binds: []string{
+			"/var/run/:/host/var/run/",
+			fmt.Sprintf("%s:/root/.pier/", pierRepo),
+		},
+		Ports: []string{
+			fmt.Sprintf("%s:34544", httpPort),
+			fmt.Sprintf("%s:34555", pprofPort),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Start %s, container: %s\n", name, cid[:12])
+
+	color.Blue("You can use the \"goduck status list\" command to check the status of the startup pier.")
+	return nil
+}
+
+// DockerContainerID returns the ID of the pier container of the appchain, empty if it does not exist
+func DockerContainerID(repoRoot, appchainType string) (string, error) {
+	o, err := orchestrator.New(repo.ProjectID(repoRoot))
+	if err != nil {
+		return "", err
+	}
+
+	containers, err := o.List(context.Background(), types.Pier)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range containers {
+		if c.Labels[orchestrator.LabelName] == ContainerName(appchainType) {
+			return c.ID[:12], nil
+		}
+	}
+
+	return "", nil
+}
+
+// StopPierDocker stops the pier container of the appchain
+func StopPierDocker(repoRoot, appchainType string, timeout time.Duration) error {
+	o, err := orchestrator.New(repo.ProjectID(repoRoot))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !o.Available(ctx) {
+		return nil
+	}
+
+	return o.Stop(ctx, types.Pier, ContainerName(appchainType), timeout)
+}
+
+// CleanPierDocker removes the pier container of the appchain
+func CleanPierDocker(repoRoot, appchainType string) error {
+	o, err := orchestrator.New(repo.ProjectID(repoRoot))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !o.Available(ctx) {
+		return nil
+	}
+
+	return o.Remove(ctx, types.Pier, ContainerName(appchainType))
+}
+
+func copyDockerScripts(repoRoot, pierRepo string) error {
+	src := filepath.Join(repoRoot, "docker", types.Pier)
+	dst := filepath.Join(pierRepo, "scripts")
+	if err := utils.CopyDir(src, dst); err != nil {
+		return fmt.Errorf("copy pier docker scripts: %w", err)
+	}
+
+	return nil
+}
+
+func replaceInFile(path, old, new string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, []byte(strings.Replace(string(data), old, new, -1)), 0644)
+}
@@ -1,10 +1,34 @@
 package pier
 
 import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meshplus/goduck/internal/supervisor"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/meshplus/goduck/internal/utils"
+	gops "github.com/shirou/gopsutil/process"
 )
 
+// StatePath returns the path of the file which records the piers started by goduck in binary
+func StatePath(repoRoot string) string {
+	return filepath.Join(repoRoot, types.Pier, types.PierStateFile)
+}
+
+// stateName is the name the pier of the appchain is recorded by
+func stateName(appchainType string) string {
+	return fmt.Sprintf("pier-%s", appchainType)
+}
+
+// PidPath returns the pid file the pier script writes for the pier of the appchain started in binary
+func PidPath(repoRoot, appchainType string) string {
+	return filepath.Join(repoRoot, types.Pier, fmt.Sprintf("pier-%s.pid", appchainType))
+}
+
 func GeneratePier(scriptPath, repoRoot, pierRepo, configPath, appchainType, pierBinPath, pluginPath string) error {
 	args := []string{scriptPath, "-a", appchainType, "-p", pierRepo, "-c", configPath, "-b", pierBinPath, "-g", pluginPath}
 	return utils.ExecuteShell(args, repoRoot)
@@ -12,32 +36,114 @@ func GeneratePier(scriptPath, repoRoot, pierRepo, configPath, appchainType, pier
 
 func StartPier(repoRoot, appchainType, pierRepo, upType, configPath, version string) error {
 	args := []string{types.PierScript, "up", "-a", appchainType, "-p", pierRepo, "-u", upType, "-c", configPath, "-v", version}
-	return utils.ExecuteShell(args, repoRoot)
-}
+	if err := utils.ExecuteShell(args, repoRoot); err != nil {
+		return err
+	}
+	if upType != types.TypeBinary {
+		return nil
+	}
 
-func RegisterPier(repoRoot, pierRepo, appchainType, upType, method, version, cid string) error {
-	args := []string{types.PierScript, "register", "-a", appchainType, "-p", pierRepo, "-u", upType, "-m", method, "-v", version, "-i", cid}
-	return utils.ExecuteShell(args, repoRoot)
+	return recordPier(repoRoot, appchainType, pierRepo, version)
 }
 
-func DeployRule(repoRoot, appchainType, pierRepo, ruleRepo, upType, method, version, cid string) error {
-	args := []string{types.PierScript, "rule", "-a", appchainType, "-p", pierRepo, "-r", ruleRepo, "-u", upType, "-m", method, "-v", version, "-i", cid}
-	return utils.ExecuteShell(args, repoRoot)
+// StartPierBinary starts pier of the appchain in binary with the configuration already in pierRepo
+func StartPierBinary(repoRoot, appchainType, pierRepo, version string) error {
+	args := []string{types.PierScript, "start", "-a", appchainType, "-p", pierRepo, "-u", types.TypeBinary, "-v", version}
+	if err := utils.ExecuteShell(args, repoRoot); err != nil {
+		return err
+	}
+
+	return recordPier(repoRoot, appchainType, pierRepo, version)
 }
 
-func StopPier(repoRoot, appchainType string) error {
+func StopPier(repoRoot, appchainType string, timeout time.Duration) error {
 	args := []string{types.PierScript, "down", "-a", appchainType}
 	if err := utils.ExecuteShell(args, repoRoot); err != nil {
 		return err
 	}
-	return nil
+	if err := dropPier(repoRoot, appchainType); err != nil {
+		return err
+	}
+
+	return StopPierDocker(repoRoot, appchainType, timeout)
 }
 
-func CleanPier(repoRoot, appchainType string) error {
+func CleanPier(repoRoot, appchainType string, timeout time.Duration) error {
+	if err := StopPierDocker(repoRoot, appchainType, timeout); err != nil {
+		return err
+	}
+
+	if err := CleanPierDocker(repoRoot, appchainType); err != nil {
+		return err
+	}
+
 	args := []string{types.PierScript, "clean", "-a", appchainType}
 	if err := utils.ExecuteShell(args, repoRoot); err != nil {
 		return err
 	}
 
-	return nil
+	return dropPier(repoRoot, appchainType)
+}
+
+// recordPier records the pier of the appchain which the script started in binary by the pid it wrote,
+// so the pier is told apart from other processes like the BitXHub nodes goduck started
+func recordPier(repoRoot, appchainType, pierRepo, version string) error {
+	data, err := ioutil.ReadFile(PidPath(repoRoot, appchainType))
+	if err != nil {
+		return fmt.Errorf("read pid of pier: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("parse pid of pier: %w", err)
+	}
+
+	state := &supervisor.State{
+		Name:      stateName(appchainType),
+		Component: types.Pier,
+		Version:   version,
+		Pid:       pid,
+		Args:      []string{"--repo", pierRepo, "start"},
+		Dir:       pierRepo,
+		Repo:      pierRepo,
+		StartedAt: time.Now(),
+	}
+	if process, err := gops.NewProcess(int32(pid)); err == nil {
+		state.Bin, _ = process.Exe()
+	}
+
+	states, err := supervisor.LoadStates(StatePath(repoRoot))
+	if err != nil {
+		return err
+	}
+	kept := make([]*supervisor.State, 0, len(states)+1)
+	for _, s := range states {
+		if s.Name != state.Name {
+			kept = append(kept, s)
+		}
+	}
+
+	return supervisor.SaveStates(StatePath(repoRoot), "", append(kept, state))
+}
+
+// dropPier removes the pier of the appchain from the recorded piers
+func dropPier(repoRoot, appchainType string) error {
+	states, err := supervisor.LoadStates(StatePath(repoRoot))
+	if err != nil {
+		return err
+	}
+
+	var kept []*supervisor.State
+	for _, s := range states {
+		if s.Name != stateName(appchainType) {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == len(states) {
+		return nil
+	}
+	if len(kept) == 0 {
+		return supervisor.RemoveStates(StatePath(repoRoot), "")
+	}
+
+	return supervisor.SaveStates(StatePath(repoRoot), "", kept)
 }
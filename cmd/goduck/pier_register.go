@@ -0,0 +1,253 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	crypto2 "github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/bxh"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/urfave/cli/v2"
+)
+
+// the DID document appchains are registered with since v1.8.0
+const (
+	appchainDocAddr = "/ipfs/QmQVxzUqN2Yv2UHUQXYwH8dSNkM8ReJ9qPqwJsf8zzoNUi"
+	appchainDocHash = "QmQVxzUqN2Yv2UHUQXYwH8dSNkM8ReJ9qPqwJsf8zzoNUi"
+)
+
+// appchainMeta returns the metadata the appchain of the pier in pierRepo is registered with
+func appchainMeta(pierRepo, chainType, method string, release *versions.Pier) (*bxh.Appchain, error) {
+	var chain *bxh.Appchain
+	validatorsPath := ""
+	switch chainType {
+	case types.ChainTypeFabric:
+		chain = &bxh.Appchain{Name: "chainA", Type: "fabric", Desc: "chainA-description", Version: "1.4.3"}
+		validatorsPath = filepath.Join(pierRepo, "fabric", "fabric.validators")
+	case types.ChainTypeEther:
+		chain = &bxh.Appchain{Name: "chainB", Type: "ether", Desc: "chainB-description", Version: "1.9.13"}
+		validatorsPath = filepath.Join(pierRepo, "ethereum", "ether.validators")
+	default:
+		return nil, fmt.Errorf("not support chain type %s", chainType)
+	}
+
+	validators, err := ioutil.ReadFile(validatorsPath)
+	if err != nil {
+		return nil, fmt.Errorf("read validators of %s: %w", chainType, err)
+	}
+	chain.Validators = string(validators)
+	// the consensus type is an int32 which the pier leaves 0 before v1.7.0
+	if release.RegisterWithConsensus() {
+		chain.ConsensusType = "consensusType"
+	}
+	if release.RegisterByMethod() {
+		chain.Method = method
+		chain.DocAddr = appchainDocAddr
+		chain.DocHash = appchainDocHash
+	}
+
+	return chain, nil
+}
+
+// registerAppchain registers the appchain of the pier in pierRepo to BitXHub through gateway with the key of
+// the pier, and saves the registration in the pier repo
+func registerAppchain(gateway, pierRepo, chainType, method string, release *versions.Pier) (*bxh.Registration, error) {
+	color.Blue("======> Register pier(%s) to BitXHub", chainType)
+
+	chain, err := appchainMeta(pierRepo, chainType, method, release)
+	if err != nil {
+		return nil, err
+	}
+	key, err := pierKey(pierRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	registration, err := bxh.New(gateway).RegisterAppchain(key, chain, release)
+	if err != nil {
+		return nil, fmt.Errorf("register appchain: %w", err)
+	}
+	if err := saveRegistration(pierRepo, registration); err != nil {
+		return nil, err
+	}
+
+	if registration.ProposalID == "" {
+		color.Green("Appchain %s is registered", registration.AppchainID)
+	} else {
+		color.Green("Appchain %s is registered by proposal %s, which is %s", registration.AppchainID, registration.ProposalID, registration.Status)
+	}
+	return registration, nil
+}
+
+// pierKey returns the account key of the pier in pierRepo
+func pierKey(pierRepo string) (crypto2.PrivateKey, error) {
+	key, err := readKey(filepath.Join(pierRepo, repo.KeyName), keyFormatBitXHubJSON, crypto2.Secp256k1, &utils.PasswordSource{}, false)
+	if err != nil {
+		return nil, fmt.Errorf("read pier key: %w", err)
+	}
+
+	return key, nil
+}
+
+// loadRegistration returns the registration saved in the pier repo, or nil if the pier is not registered
+func loadRegistration(pierRepo string) (*bxh.Registration, error) {
+	data, err := ioutil.ReadFile(filepath.Join(pierRepo, types.PierRegistration))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	registration := &bxh.Registration{}
+	if err := json.Unmarshal(data, registration); err != nil {
+		return nil, fmt.Errorf("unmarshal registration: %w", err)
+	}
+
+	return registration, nil
+}
+
+func saveRegistration(pierRepo string, registration *bxh.Registration) error {
+	data, err := json.MarshalIndent(registration, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(pierRepo, types.PierRegistration), data, 0644)
+}
+
+// refreshRegistration updates the status of the registration in the pier repo from its proposal
+func refreshRegistration(gateway, pierRepo string) (*bxh.Registration, error) {
+	registration, err := loadRegistration(pierRepo)
+	if err != nil || registration == nil || registration.ProposalID == "" {
+		return registration, err
+	}
+	key, err := pierKey(pierRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := bxh.New(gateway).Proposal(key, registration.ProposalID)
+	if err != nil {
+		return nil, fmt.Errorf("get proposal %s: %w", registration.ProposalID, err)
+	}
+	if proposal.Status != registration.Status {
+		registration.Status = proposal.Status
+		if err := saveRegistration(pierRepo, registration); err != nil {
+			return nil, err
+		}
+	}
+
+	return registration, nil
+}
+
+// appchainTarget is the appchain the rule and service commands work on, with the key of the pier administering it
+type appchainTarget struct {
+	release   *versions.Pier
+	pierRepo  string
+	chainType string
+	chainID   string
+	client    *bxh.Client
+	key       crypto2.PrivateKey
+}
+
+// appchainFlags select the appchain the rule and service commands work on, of pier version by default
+func appchainFlags(version string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:  "appchain",
+			Usage: "Specify appchain type, one of ethereum or fabric",
+			Value: types.ChainTypeEther,
+		},
+		&cli.StringFlag{
+			Name:  "pierRepo",
+			Usage: "Specify the path of the pier administering the appchain (default:$repo/pier/.pier_$chainType)",
+		},
+		&cli.StringFlag{
+			Name:  "appchain-id",
+			Usage: "Specify the appchain id (default: the one the pier registered)",
+		},
+		&cli.StringFlag{
+			Name:  "method",
+			Usage: "Specify appchain method, only useful for v1.8.0+",
+			Value: "appchain",
+		},
+		&cli.StringFlag{
+			Aliases: []string{"version", "v"},
+			Value:   version,
+			Usage:   "Pier version",
+		},
+	}
+}
+
+func appchainTargetOf(ctx *cli.Context) (*appchainTarget, error) {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return nil, err
+	}
+	release, err := versions.LookupPier(ctx.String("version"))
+	if err != nil {
+		return nil, err
+	}
+	chainType := ctx.String("appchain")
+	pierRepo := ctx.String("pierRepo")
+	if pierRepo == "" {
+		pierRepo = filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", chainType))
+	}
+
+	return newAppchainTarget(repoRoot, ctx.String("gateway"), pierRepo, chainType, ctx.String("method"), ctx.String("appchain-id"), release)
+}
+
+// newAppchainTarget returns the appchain administered by the pier in pierRepo. Unless chainID is given, it is
+// the appchain the pier registered, or the id BitXHub gives to the appchain of the pier.
+func newAppchainTarget(repoRoot, gateway, pierRepo, chainType, method, chainID string, release *versions.Pier) (*appchainTarget, error) {
+	if !fileutil.Exist(pierRepo) {
+		return nil, fmt.Errorf("the pier startup path(%s) does not exist", pierRepo)
+	}
+	key, err := pierKey(pierRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	if chainID == "" {
+		registration, err := loadRegistration(pierRepo)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case registration != nil:
+			chainID = registration.AppchainID
+		case release.RegisterByMethod():
+			chainID = fmt.Sprintf("did:bitxhub:%s:.", method)
+		default:
+			addr, err := key.PublicKey().Address()
+			if err != nil {
+				return nil, err
+			}
+			chainID = addr.String()
+		}
+	}
+
+	if gateway == "" {
+		if gateway, err = localGateway(repoRoot); err != nil {
+			return nil, err
+		}
+	}
+
+	return &appchainTarget{
+		release:   release,
+		pierRepo:  pierRepo,
+		chainType: chainType,
+		chainID:   chainID,
+		client:    bxh.New(gateway),
+		key:       key,
+	}, nil
+}
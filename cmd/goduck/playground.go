@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -16,6 +15,7 @@ import (
 	"github.com/meshplus/goduck/internal/repo"
 	"github.com/meshplus/goduck/internal/types"
 	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
 	"github.com/urfave/cli/v2"
 )
 
@@ -70,20 +70,10 @@ func dockerUp(ctx *cli.Context) error {
 		return err
 	}
 
-	data, err := ioutil.ReadFile(filepath.Join(repoRoot, "release.json"))
-	if err != nil {
-		return err
-	}
-
-	var release *Release
-	if err := json.Unmarshal(data, &release); err != nil {
+	if _, err := versions.LookupBitXHub(version); err != nil {
 		return err
 	}
 
-	if !AdjustVersion(version, release.Bitxhub) {
-		return fmt.Errorf("unsupport verison")
-	}
-
 	//get bitxhub addr
 	keyData, err := ioutil.ReadFile(filepath.Join(repoRoot, "docker/quick_start/bitxhubCerts/key.priv"))
 	if err != nil {
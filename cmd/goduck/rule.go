@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+
+	"github.com/fatih/color"
+	crypto2 "github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/goduck/internal/bxh"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/urfave/cli/v2"
+)
+
+func ruleCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "rule",
+		Usage: "Deploy and manage validation rules of appchains",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "deploy",
+				Usage: "Deploy a wasm rule and register it to the appchain",
+				Flags: append(append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "path",
+						Usage: "Specify the path of the wasm rule (default:$pierRepo/$chainType/validating.wasm)",
+					},
+				}, appchainFlags("v1.6.1")...), autoApproveFlags()...),
+				Action: ruleDeployWasm,
+			},
+			{
+				Name:  "list",
+				Usage: "List the rules of the appchain with their status",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "gateway",
+						Usage: "Specify the gateway of the BitXHub node goduck talks to (default: the gateway of the first node in $repo/bitxhub/.bitxhub)",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "List rules in json",
+					},
+				}, appchainFlags("v1.6.1")...),
+				Action: ruleList,
+			},
+			{
+				Name:   "bind",
+				Usage:  "Bind a deployed rule to the appchain, only useful for v1.8.0+",
+				Flags:  ruleAddrFlags(),
+				Action: ruleProposal("BindRule", (*versions.Pier).RuleBinding, (*bxh.Client).BindRule),
+			},
+			{
+				Name:   "unbind",
+				Usage:  "Unbind a rule from the appchain, only useful for v1.8.0+",
+				Flags:  ruleAddrFlags(),
+				Action: ruleProposal("UnbindRule", (*versions.Pier).RuleBinding, (*bxh.Client).UnbindRule),
+			},
+			{
+				Name:   "update",
+				Usage:  "Make a bound rule the master rule of the appchain, only useful for v1.9.0+",
+				Flags:  ruleAddrFlags(),
+				Action: ruleProposal("UpdateMasterRule", (*versions.Pier).MasterRule, (*bxh.Client).UpdateMasterRule),
+			},
+		},
+	}
+}
+
+func ruleAddrFlags() []cli.Flag {
+	return append(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "addr",
+			Usage:    "Specify the address of the rule",
+			Required: true,
+		},
+	}, appchainFlags("v1.6.1")...), autoApproveFlags()...)
+}
+
+func ruleDeployWasm(ctx *cli.Context) error {
+	t, err := appchainTargetOf(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkAutoApprove(ctx, t.release); err != nil {
+		return err
+	}
+	path := ctx.String("path")
+	if path == "" {
+		path = filepath.Join(t.pierRepo, t.chainType, types.RuleName)
+	}
+
+	return withAutoApprove(ctx, "RuleMgr", pierProposer(t.pierRepo), func() error {
+		_, err := deployRule(t, path)
+		return err
+	})
+}
+
+func ruleList(ctx *cli.Context) error {
+	t, err := appchainTargetOf(ctx)
+	if err != nil {
+		return err
+	}
+
+	var rules []*bxh.Rule
+	if t.release.RuleBinding() {
+		if rules, err = t.client.Rules(t.key, t.chainID); err != nil {
+			return fmt.Errorf("list rules: %w", err)
+		}
+	} else {
+		addr, err := t.client.RuleAddress(t.key, t.chainID, t.chainType)
+		if err != nil {
+			return fmt.Errorf("get rule: %w", err)
+		}
+		rules = []*bxh.Rule{{Address: addr, ChainId: t.chainID, Status: "available", Master: true}}
+	}
+
+	if ctx.Bool("json") {
+		data, err := json.MarshalIndent(rules, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(rules) == 0 {
+		fmt.Printf("no rules of appchain %s\n", t.chainID)
+		return nil
+	}
+	rows := [][]string{{"Address", "Status", "Master"}}
+	for _, r := range rules {
+		rows = append(rows, []string{r.Address, r.Status, strconv.FormatBool(r.Master)})
+	}
+	PrintTable(rows, true)
+
+	return nil
+}
+
+// ruleProposal returns the action submitting a RuleMgr proposal by method, which needs a pier release
+// supporting it
+func ruleProposal(method string, supported func(*versions.Pier) bool, submit func(*bxh.Client, crypto2.PrivateKey, string, string) (string, error)) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		t, err := appchainTargetOf(ctx)
+		if err != nil {
+			return err
+		}
+		if !supported(t.release) {
+			return fmt.Errorf("pier %s does not support %s", t.release.Version, method)
+		}
+		addr := ctx.String("addr")
+
+		return withAutoApprove(ctx, "RuleMgr", pierProposer(t.pierRepo), func() error {
+			id, err := submit(t.client, t.key, t.chainID, addr)
+			if err != nil {
+				return fmt.Errorf("%s: %w", method, err)
+			}
+			color.Green("%s of rule %s for appchain %s is submitted by proposal %s", method, addr, t.chainID, id)
+			return nil
+		})
+	}
+}
+
+// deployRule deploys the wasm rule of path, and registers it to the appchain the way the pier release
+// does. It returns the address of the rule.
+func deployRule(t *appchainTarget, path string) (string, error) {
+	color.Blue("======> Deploy rule %s to BitXHub", path)
+	code, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read rule: %w", err)
+	}
+
+	addr, err := t.client.DeployXVM(t.key, code)
+	if err != nil {
+		return "", fmt.Errorf("deploy rule: %w", err)
+	}
+	color.Green("Rule is deployed at %s", addr)
+
+	register := t.client.RegisterRule
+	if t.release.RuleBinding() && !t.release.MasterRule() {
+		register = t.client.BindRule
+	}
+	id, err := register(t.key, t.chainID, addr)
+	if err != nil {
+		return "", fmt.Errorf("register rule: %w", err)
+	}
+	color.Green("Rule %s is registered to appchain %s by proposal %s", addr, t.chainID, id)
+
+	return addr, nil
+}
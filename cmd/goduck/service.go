@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	crypto2 "github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/goduck/internal/bxh"
+	"github.com/urfave/cli/v2"
+)
+
+func serviceCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "service",
+		Usage: "Register and manage interchain services of appchains, only useful for v1.8.0+",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "register",
+				Usage: "Register an interchain service of the appchain",
+				Flags: serviceFlags(true,
+					&cli.StringFlag{
+						Name:  "type",
+						Usage: fmt.Sprintf("Specify the service type, one of %s", strings.Join(bxh.ServiceTypes, ", ")),
+						Value: bxh.ServiceTypes[0],
+					},
+				),
+				Action: serviceRegister,
+			},
+			{
+				Name:  "list",
+				Usage: "List the interchain services of the appchain with their status",
+				Flags: append([]cli.Flag{
+					&cli.StringFlag{
+						Name:  "gateway",
+						Usage: "Specify the gateway of the BitXHub node goduck talks to (default: the gateway of the first node in $repo/bitxhub/.bitxhub)",
+					},
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "List services in json",
+					},
+				}, appchainFlags("v1.8.0")...),
+				Action: serviceList,
+			},
+			{
+				Name:   "update",
+				Usage:  "Update the name, intro, order, permission or details of a service",
+				Flags:  serviceFlags(false),
+				Action: serviceUpdate,
+			},
+			{
+				Name:   "freeze",
+				Usage:  "Freeze a service, so it is no longer called",
+				Flags:  serviceIDFlags(),
+				Action: serviceStatus("FreezeService", (*bxh.Client).FreezeService),
+			},
+			{
+				Name:   "activate",
+				Usage:  "Activate a frozen service",
+				Flags:  serviceIDFlags(),
+				Action: serviceStatus("ActivateService", (*bxh.Client).ActivateService),
+			},
+		},
+	}
+}
+
+// serviceIDFlags select a service of the appchain and the reason of the proposal on it
+func serviceIDFlags(flags ...cli.Flag) []cli.Flag {
+	flags = append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "id",
+			Usage:    "Specify the service id, which is the address of the service contract on the appchain",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "reason",
+			Usage: "Specify the reason of the proposal",
+		},
+	}, flags...)
+	flags = append(flags, appchainFlags("v1.8.0")...)
+
+	return append(flags, autoApproveFlags()...)
+}
+
+// serviceFlags are the flags of services, the name is required for registering
+func serviceFlags(register bool, flags ...cli.Flag) []cli.Flag {
+	return serviceIDFlags(append([]cli.Flag{
+		&cli.StringFlag{
+			Name:     "name",
+			Usage:    "Specify the service name",
+			Required: register,
+		},
+		&cli.StringFlag{
+			Name:  "intro",
+			Usage: "Specify the introduction of the service",
+		},
+		&cli.BoolFlag{
+			Name:  "ordered",
+			Usage: "Specify whether calls to the service are executed in order",
+			Value: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  "permit",
+			Usage: "Specify the appchains allowed to call the service, repeated or separated by commas (default: all)",
+		},
+		&cli.StringFlag{
+			Name:  "details",
+			Usage: "Specify the details of the service, like its methods",
+		},
+	}, flags...)...)
+}
+
+func serviceRegister(ctx *cli.Context) error {
+	t, err := serviceTarget(ctx)
+	if err != nil {
+		return err
+	}
+	if !contains(bxh.ServiceTypes, ctx.String("type")) {
+		return fmt.Errorf("unknown service type %s, choose one of %s", ctx.String("type"), strings.Join(bxh.ServiceTypes, ", "))
+	}
+
+	service := &bxh.Service{
+		ChainID:    t.chainID,
+		ServiceID:  ctx.String("id"),
+		Name:       ctx.String("name"),
+		Type:       ctx.String("type"),
+		Intro:      ctx.String("intro"),
+		Ordered:    ctx.Bool("ordered"),
+		Permission: servicePermits(ctx),
+		Details:    ctx.String("details"),
+	}
+
+	return withAutoApprove(ctx, "ServiceMgr", pierProposer(t.pierRepo), func() error {
+		id, err := t.client.RegisterService(t.key, service, ctx.String("reason"))
+		if err != nil {
+			return fmt.Errorf("register service: %w", err)
+		}
+		color.Green("Service %s is registered by proposal %s", service.ChainServiceID(), id)
+		return nil
+	})
+}
+
+func serviceList(ctx *cli.Context) error {
+	t, err := serviceTarget(ctx)
+	if err != nil {
+		return err
+	}
+
+	services, err := t.client.Services(t.key, t.chainID)
+	if err != nil {
+		return fmt.Errorf("list services: %w", err)
+	}
+
+	if ctx.Bool("json") {
+		data, err := json.MarshalIndent(services, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(services) == 0 {
+		fmt.Printf("no services of appchain %s\n", t.chainID)
+		return nil
+	}
+	rows := [][]string{{"Id", "Name", "Type", "Status", "Ordered", "Permit"}}
+	for _, s := range services {
+		permit := "all"
+		if len(s.Permission) != 0 {
+			var chains []string
+			for chain := range s.Permission {
+				chains = append(chains, chain)
+			}
+			sort.Strings(chains)
+			permit = strings.Join(chains, ",")
+		}
+		rows = append(rows, []string{s.ServiceID, s.Name, s.Type, s.Status, strconv.FormatBool(s.Ordered), permit})
+	}
+	PrintTable(rows, true)
+
+	return nil
+}
+
+func serviceUpdate(ctx *cli.Context) error {
+	t, err := serviceTarget(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the flags left out keep what the service has
+	services, err := t.client.Services(t.key, t.chainID)
+	if err != nil {
+		return fmt.Errorf("list services: %w", err)
+	}
+	var service *bxh.Service
+	for _, s := range services {
+		if s.ServiceID == ctx.String("id") {
+			service = s
+		}
+	}
+	if service == nil {
+		return fmt.Errorf("no service %s of appchain %s", ctx.String("id"), t.chainID)
+	}
+	if ctx.IsSet("name") {
+		service.Name = ctx.String("name")
+	}
+	if ctx.IsSet("intro") {
+		service.Intro = ctx.String("intro")
+	}
+	if ctx.IsSet("ordered") {
+		service.Ordered = ctx.Bool("ordered")
+	}
+	if ctx.IsSet("permit") {
+		service.Permission = servicePermits(ctx)
+	}
+	if ctx.IsSet("details") {
+		service.Details = ctx.String("details")
+	}
+
+	return withAutoApprove(ctx, "ServiceMgr", pierProposer(t.pierRepo), func() error {
+		id, err := t.client.UpdateService(t.key, service, ctx.String("reason"))
+		if err != nil {
+			return fmt.Errorf("update service: %w", err)
+		}
+		color.Green("Update of service %s is submitted by proposal %s", service.ChainServiceID(), id)
+		return nil
+	})
+}
+
+// serviceStatus returns the action changing the status of a service by method
+func serviceStatus(method string, submit func(*bxh.Client, crypto2.PrivateKey, string, string) (string, error)) cli.ActionFunc {
+	return func(ctx *cli.Context) error {
+		t, err := serviceTarget(ctx)
+		if err != nil {
+			return err
+		}
+		chainServiceID := fmt.Sprintf("%s:%s", t.chainID, ctx.String("id"))
+
+		return withAutoApprove(ctx, "ServiceMgr", pierProposer(t.pierRepo), func() error {
+			id, err := submit(t.client, t.key, chainServiceID, ctx.String("reason"))
+			if err != nil {
+				return fmt.Errorf("%s: %w", method, err)
+			}
+			color.Green("%s of service %s is submitted by proposal %s", method, chainServiceID, id)
+			return nil
+		})
+	}
+}
+
+// serviceTarget returns the appchain of the services, whose pier release must support them
+func serviceTarget(ctx *cli.Context) (*appchainTarget, error) {
+	t, err := appchainTargetOf(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !t.release.InterchainServices() {
+		return nil, fmt.Errorf("pier %s has no interchain services, they need v1.8.0+", t.release.Version)
+	}
+
+	return t, nil
+}
+
+// servicePermits returns the appchains of --permit
+func servicePermits(ctx *cli.Context) map[string]struct{} {
+	permission := make(map[string]struct{})
+	for _, value := range ctx.StringSlice("permit") {
+		for _, chain := range strings.Split(value, ",") {
+			if chain != "" {
+				permission[chain] = struct{}{}
+			}
+		}
+	}
+
+	return permission
+}
@@ -8,32 +8,17 @@ import (
 	"time"
 
 	"github.com/cheynewallace/tabby"
-	"github.com/codeskyblue/go-sh"
-	"github.com/docker/docker/client"
 	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/cmd/goduck/pier"
+	"github.com/meshplus/goduck/internal/orchestrator"
 	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/supervisor"
+	"github.com/meshplus/goduck/internal/types"
 	gops "github.com/shirou/gopsutil/process"
 	"github.com/urfave/cli/v2"
 )
 
-var processesNames = []string{
-	"bitxhub/node",
-	"pier_ethereum",
-	"pier_fabric",
-}
-
-var containerNames = []string{
-	"bitxhub_solo",
-	"bitxhub_node",
-	"pier-ethereum",
-	"pier-fabric",
-}
-
-var modes = []string{
-	"binary",
-	"docker",
-}
-
 func GetStatusCMD() *cli.Command {
 	return &cli.Command{
 		Name:  "status",
@@ -41,12 +26,12 @@ func GetStatusCMD() *cli.Command {
 		Subcommands: []*cli.Command{
 			{
 				Name:   "list",
-				Usage:  "Check the BitxHub, PIER and other components which in default configuration directories or start in docker",
+				Usage:  "Check the BitXHub nodes and piers goduck started in binary or in docker",
 				Action: showStatus,
 			},
 			{
 				Name:  "component",
-				Usage: "Check status of specified BitXHub or Pier",
+				Usage: "Check status of the BitXHub node or pier goduck started which listens on the port",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:  "port",
@@ -60,27 +45,43 @@ func GetStatusCMD() *cli.Command {
 	}
 }
 
-func showStatus(ctx *cli.Context) error {
-	repoRoot, err := repo.PathRoot()
-	if err != nil {
-		return err
-	}
+// componentStatus is the status of a component goduck started, with the ports it listens on
+type componentStatus struct {
+	name      string
+	component string
+	mode      string
+	id        string
+	status    string
+	created   string
+	args      string
+	ports     []int
+}
 
-	if !fileutil.Exist(repoRoot) {
-		return fmt.Errorf("please `goduck init` first")
+func (s *componentStatus) row() []string {
+	return []string{s.name, s.component, s.mode, s.id, s.status, s.created, s.args}
+}
+
+func (s *componentStatus) listens(port int) bool {
+	for _, p := range s.ports {
+		if p == port {
+			return true
+		}
 	}
 
-	var table [][]string
-	table = append(table, []string{"Name", "Component", "Mode", "PID/ContanierID", "Status", "Created Time", "Args"})
+	return false
+}
+
+var statusHeader = []string{"Name", "Component", "Mode", "PID/ContainerID", "Status", "Created Time", "Args"}
 
-	table, err = existProcess(table)
+func showStatus(ctx *cli.Context) error {
+	statuses, err := componentStatuses()
 	if err != nil {
 		return err
 	}
 
-	table, err = existContainer(table)
-	if err != nil {
-		return err
+	table := [][]string{statusHeader}
+	for _, s := range statuses {
+		table = append(table, s.row())
 	}
 
 	PrintTable(table, true)
@@ -88,234 +89,159 @@ func showStatus(ctx *cli.Context) error {
 }
 
 func showComponentStatus(ctx *cli.Context) error {
-	port := ctx.String("port")
-
-	repoRoot, err := repo.PathRoot()
+	port, err := strconv.Atoi(ctx.String("port"))
 	if err != nil {
-		return err
-	}
-
-	if !fileutil.Exist(repoRoot) {
-		return fmt.Errorf("please `goduck init` first")
+		return fmt.Errorf("invalid port %s: %w", ctx.String("port"), err)
 	}
 
-	var table [][]string
-	table = append(table, []string{"Name", "Component", "Mode", "PID/ContanierID", "Status", "Created Time", "Args"})
-
-	paramsBin, err := binaryStatus(port)
+	statuses, err := componentStatuses()
 	if err != nil {
-		return fmt.Errorf("binary status error: %w", err)
-	}
-	if paramsBin != nil {
-		if paramsBin[1] == "pier" || paramsBin[1] == "bitxhub" {
-			table = append(table, paramsBin)
-		}
+		return err
 	}
 
-	paramsDocker, err := dockerStatus(port)
-	if err != nil {
-		return fmt.Errorf("docker status error: %w", err)
-	}
-	if paramsDocker != nil {
-		table = append(table, paramsDocker)
+	table := [][]string{statusHeader}
+	for _, s := range statuses {
+		if s.listens(port) {
+			table = append(table, s.row())
+		}
 	}
 
 	PrintTable(table, true)
 	return nil
 }
 
-func binaryStatus(port string) ([]string, error) {
-	// lsof -i:60011 | grep LISTEN | awk '{print $2}'
-	pidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("lsof -i:%s | grep LISTEN | awk '{print $2}'", port)).Output()
+// componentStatuses returns the status of the components goduck recorded when it started them, the
+// processes in the state files and the containers labeled with the project of the repo
+func componentStatuses() ([]*componentStatus, error) {
+	repoRoot, err := repo.PathRoot()
 	if err != nil {
-		return nil, fmt.Errorf("get pid error: %w", err)
+		return nil, err
 	}
 
-	if string(pidOut) != "" {
-		params, err := getProcessParam(string(pidOut[:len(pidOut)-1]), "")
-		if err != nil {
-			return nil, fmt.Errorf("get process param error: %w", err)
-		}
-		return params, nil
+	if !fileutil.Exist(repoRoot) {
+		return nil, fmt.Errorf("please `goduck init` first")
 	}
 
-	return nil, nil
-}
-
-func dockerStatus(port string) ([]string, error) {
-	tmpOut, _ := sh.Command("/bin/bash", "-c", "docker ps -a -q").Output()
-	if string(tmpOut) == "" {
-		return nil, nil
-	}
-	// docker inspect -f "{{.Id}} {{.HostConfig.PortBindings}} {{.Name}} " $(docker ps -q) | grep HostPort:6001
-	cidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("docker inspect -f \"{{.Id}} {{.HostConfig.PortBindings}} {{.Name}} \" $(docker ps -a -q) | grep HostPort:%s | awk '{print $1}'", port)).Output()
+	statuses, err := binaryStatuses(repoRoot)
 	if err != nil {
-		return nil, fmt.Errorf("get cid error: %w", err)
+		return nil, fmt.Errorf("binary status error: %w", err)
 	}
 
-	ctx := context.Background()
-	mycli, err := client.NewClientWithOpts(client.FromEnv)
+	containers, err := dockerStatuses(repoRoot)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("docker status error: %w", err)
 	}
 
-	if string(cidOut) != "" {
-		params, err := getContainerParam(string(cidOut[:len(cidOut)-1]), mycli, ctx)
+	return append(statuses, containers...), nil
+}
+
+// binaryStatuses returns the status of the BitXHub nodes and piers recorded in the state files of the repo
+func binaryStatuses(repoRoot string) ([]*componentStatus, error) {
+	var states []*supervisor.State
+	for _, path := range []string{bitxhub.StatePath(repoRoot), pier.StatePath(repoRoot)} {
+		s, err := supervisor.LoadStates(path)
 		if err != nil {
-			return nil, fmt.Errorf("get container param error: %w", err)
+			return nil, err
 		}
-		return params, nil
+		states = append(states, s...)
 	}
 
-	return nil, nil
-}
-
-func existProcess(table [][]string) ([][]string, error) {
-	for _, pname := range processesNames {
-		pidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("ps | grep %s | grep start | grep -v grep | awk '{print $1}'", pname)).Output()
-		if err != nil {
-			return nil, fmt.Errorf("get cid error: %w", err)
+	statuses := make([]*componentStatus, 0, len(states))
+	for _, s := range states {
+		status := &componentStatus{
+			name:      s.Name,
+			component: s.Component,
+			mode:      types.TypeBinary,
+			id:        strconv.Itoa(s.Pid),
+			status:    "exited",
+			created:   s.StartedAt.Format(time.RFC3339),
+			args:      truncateArgs(append([]string{s.Bin}, s.Args...)),
 		}
-		pidOutStr := string(pidOut)
-
-		if pidOutStr != "" {
-			processesIDs := strings.Split(pidOutStr, "\n")
-			for i, pid := range processesIDs {
-				if pid != "" {
-					params, err := getProcessParam(pid, fmt.Sprintf("%s_%d", pname, i+1))
-					if err != nil {
-						return table, err
-					}
-
-					table = append(table, params)
-				}
-			}
+		if s.Running() {
+			processStatus(status, int32(s.Pid))
 		}
+		statuses = append(statuses, status)
 	}
 
-	return table, nil
-
+	return statuses, nil
 }
 
-func getProcessParam(pidStr, pName string) ([]string, error) {
-	pid, err := strconv.Atoi(string(pidStr))
+// processStatus fills the status with what the running process of pid reports, it is left exited if the
+// process is gone in the meantime
+func processStatus(status *componentStatus, pid int32) {
+	process, err := gops.NewProcess(pid)
 	if err != nil {
-		return nil, err
+		return
 	}
+	status.status = "running"
 
-	var status, name, timeFormat, args string
-	exist, err := gops.PidExists(int32(pid))
-	if err != nil {
-		// the process is killed by the outside
-		return nil, fmt.Errorf("pid exist: %w", err)
-	} else if exist {
-		status = "running"
-		process, err := gops.NewProcess(int32(pid))
-		if err != nil {
-			return nil, err
-		}
-
-		createTime, err := process.CreateTime()
-		if err != nil {
-			return nil, err
-		}
-
-		tm := time.Unix(0, createTime*int64(time.Millisecond))
-		timeFormat = tm.Format(time.RFC3339)
-
-		name, _ = process.Name()
-
-		if pName == "" {
-			pName = name
-		}
-
-		slice, _ := process.CmdlineSlice()
-		args = strings.Join(slice, " ")
-		if len(strings.Join(slice, " ")) > 1000 {
-			args = args[:1000] + "..."
-		}
-	} else {
-		status = "exited"
-		if strings.Contains(pName, "bitxhub") {
-			name = "bitxhub"
-		} else if strings.Contains(pName, "pier") {
-			name = "pier"
-		}
+	if createTime, err := process.CreateTime(); err == nil {
+		status.created = time.Unix(0, createTime*int64(time.Millisecond)).Format(time.RFC3339)
 	}
-
-	return []string{
-		pName,
-		name,
-		modes[0],
-		pidStr,
-		status,
-		timeFormat,
-		args,
-	}, nil
-}
-
-func existContainer(table [][]string) ([][]string, error) {
-	ctx := context.Background()
-	mycli, err := client.NewClientWithOpts(client.FromEnv)
-	if err != nil {
-		return table, err
+	if slice, err := process.CmdlineSlice(); err == nil {
+		status.args = truncateArgs(slice)
 	}
 
-	for _, cname := range containerNames {
-		cidOut, err := sh.Command("/bin/bash", "-c", fmt.Sprintf("docker ps -qf \"name=%s\"", cname)).Output()
-		if err != nil {
-			return nil, fmt.Errorf("get cid error: %w", err)
-		}
-		cidOutStr := string(cidOut)
-		if cidOutStr != "" {
-			containerIDs := strings.Split(cidOutStr, "\n")
-			for _, cid := range containerIDs {
-				if cid != "" {
-					params, err := getContainerParam(cid, mycli, ctx)
-					if err != nil {
-						return table, err
-					}
-
-					table = append(table, params)
-				}
-			}
+	// connections of processes of other users are not readable, their ports are left out
+	conns, _ := process.Connections()
+	for _, c := range conns {
+		if c.Status == "LISTEN" {
+			status.ports = append(status.ports, int(c.Laddr.Port))
 		}
 	}
-
-	return table, nil
 }
 
-func getContainerParam(cid string, mycli *client.Client, ctx context.Context) ([]string, error) {
-	containerInfo, err := mycli.ContainerInspect(ctx, cid)
+// dockerStatuses returns the status of the containers goduck started for the repo, none if docker is not
+// available
+func dockerStatuses(repoRoot string) ([]*componentStatus, error) {
+	o, err := orchestrator.New(repo.ProjectID(repoRoot))
 	if err != nil {
 		return nil, err
 	}
 
-	info, _, err := mycli.ImageInspectWithRaw(ctx, containerInfo.Image[7:])
+	ctx := context.Background()
+	if !o.Available(ctx) {
+		return nil, nil
+	}
+
+	containers, err := o.List(ctx, "")
 	if err != nil {
 		return nil, err
 	}
 
-	pos1 := strings.LastIndex(info.RepoTags[0], "/")
-	pos2 := strings.Index(info.RepoTags[0], "-")
-	if pos2 == -1 {
-		pos2 = len(info.RepoTags[0])
+	statuses := make([]*componentStatus, 0, len(containers))
+	for _, c := range containers {
+		name := c.Labels[orchestrator.LabelName]
+		if name == "" && len(c.Names) != 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		status := &componentStatus{
+			name:      name,
+			component: c.Labels[orchestrator.LabelComponent],
+			mode:      types.TypeDocker,
+			id:        c.ID[:12],
+			status:    c.State,
+			created:   time.Unix(c.Created, 0).Format(time.RFC3339),
+			args:      truncateArgs([]string{c.Command}),
+		}
+		for _, p := range c.Ports {
+			if p.PublicPort != 0 {
+				status.ports = append(status.ports, int(p.PublicPort))
+			}
+		}
+		statuses = append(statuses, status)
 	}
-	component := info.RepoTags[0][pos1+1 : pos2]
 
-	args := strings.Join(containerInfo.Args, " ")
-	if len(strings.Join(containerInfo.Args, " ")) > 1000 {
-		args = args[:1000] + "..."
+	return statuses, nil
+}
+
+func truncateArgs(args []string) string {
+	s := strings.TrimSpace(strings.Join(args, " "))
+	if len(s) > 1000 {
+		s = s[:1000] + "..."
 	}
-	return []string{
-		containerInfo.Name[1:],
-		component,
-		modes[1],
-		cid,
-		containerInfo.State.Status,
-		containerInfo.Created,
-		args,
-	}, nil
+
+	return s
 }
 
 // PrintTable accepts a matrix of strings and print them as ASCII table to terminal
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/cmd/goduck/bitxhub"
+	"github.com/meshplus/goduck/cmd/goduck/ethereum/ethereum"
+	"github.com/meshplus/goduck/cmd/goduck/fabric"
+	"github.com/meshplus/goduck/cmd/goduck/pier"
+	"github.com/meshplus/goduck/internal/bxh"
+	"github.com/meshplus/goduck/internal/ports"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/topology"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/utils"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/pelletier/go-toml"
+	"github.com/urfave/cli/v2"
+)
+
+const defaultEtherContractAddr = "0xD3880ea40670eD51C3e3C0ea089fDbDc9e3FBBb4"
+
+// pierProgress records the steps of a pier which are done, so that `goduck up` can be rerun
+type pierProgress struct {
+	Registered bool `json:"registered"`
+	RuleDeploy bool `json:"rule_deployed"`
+}
+
+type topologyState struct {
+	Piers map[string]*pierProgress `json:"piers"`
+}
+
+var topologyFlag = &cli.StringFlag{
+	Name:     "file",
+	Aliases:  []string{"f"},
+	Usage:    "Specify the topology file, in yaml or toml",
+	Required: true,
+}
+
+func upCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "up",
+		Usage: "Bring up BitXHub, appchains and piers described in a topology file",
+		Flags: []cli.Flag{
+			topologyFlag,
+			&cli.DurationFlag{
+				Name:  "approve-wait",
+				Value: time.Minute,
+				Usage: "Specify how long the registration proposals of appchains are waited for to be approved",
+			},
+		},
+		Action: topologyUp,
+	}
+}
+
+func downCMD() *cli.Command {
+	return &cli.Command{
+		Name:  "down",
+		Usage: "Stop piers, appchains and BitXHub described in a topology file",
+		Flags: []cli.Flag{
+			topologyFlag,
+			&cli.BoolFlag{
+				Name:  "clean",
+				Usage: "Remove configuration, containers and data of the components as well",
+			},
+			stopTimeoutFlag,
+		},
+		Action: topologyDown,
+	}
+}
+
+func topologyUp(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
+		return fmt.Errorf("please `goduck init` first")
+	}
+
+	t, err := topology.Load(ctx.String("file"))
+	if err != nil {
+		return err
+	}
+
+	state, err := loadTopologyState(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	if t.BitXHub != nil {
+		if err := upBitXHub(repoRoot, t.BitXHub); err != nil {
+			return fmt.Errorf("bring up bitxhub: %w", err)
+		}
+	}
+
+	for _, a := range t.Appchains {
+		if err := upAppchain(repoRoot, a); err != nil {
+			return fmt.Errorf("bring up appchain %s: %w", a.Name, err)
+		}
+	}
+
+	for _, p := range t.Piers {
+		a := t.Appchain(p.Appchain)
+		progress, ok := state.Piers[a.Chain]
+		if !ok {
+			progress = &pierProgress{}
+			state.Piers[a.Chain] = progress
+		}
+
+		err := upPier(repoRoot, t.BitXHub, a, p, progress, ctx.Duration("approve-wait"))
+		if err := saveTopologyState(repoRoot, state); err != nil {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("bring up pier of %s: %w", a.Name, err)
+		}
+	}
+
+	color.Green("The topology in %s is up", ctx.String("file"))
+	return nil
+}
+
+func topologyDown(ctx *cli.Context) error {
+	repoRoot, err := repo.PathRootWithDefault(ctx.String("repo"))
+	if err != nil {
+		return err
+	}
+	if !fileutil.Exist(filepath.Join(repoRoot, types.PlaygroundScript)) {
+		return fmt.Errorf("please `goduck init` first")
+	}
+
+	t, err := topology.Load(ctx.String("file"))
+	if err != nil {
+		return err
+	}
+
+	clean := ctx.Bool("clean")
+	timeout := ctx.Duration("timeout")
+
+	for i := len(t.Piers) - 1; i >= 0; i-- {
+		chain := t.Appchain(t.Piers[i].Appchain).Chain
+		if clean {
+			err = pier.CleanPier(repoRoot, chain, timeout)
+		} else {
+			err = pier.StopPier(repoRoot, chain, timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("bring down pier of %s: %w", t.Piers[i].Appchain, err)
+		}
+	}
+
+	for i := len(t.Appchains) - 1; i >= 0; i-- {
+		a := t.Appchains[i]
+		switch a.Chain {
+		case types.ChainTypeEther:
+			err = ethereum.StopEthereum(repoRoot)
+		case types.ChainTypeFabric:
+			err = fabric.Stop(repoRoot)
+		}
+		if err != nil {
+			return fmt.Errorf("bring down appchain %s: %w", a.Name, err)
+		}
+	}
+
+	if t.BitXHub != nil {
+		if _, err := bitxhub.StopBinaryNodes(repoRoot, timeout); err != nil {
+			return fmt.Errorf("stop binary nodes error:%w", err)
+		}
+		if err := bitxhub.StopDockerNodes(repoRoot, timeout); err != nil {
+			return fmt.Errorf("stop docker nodes error:%w", err)
+		}
+
+		if clean {
+			if err := bitxhub.CleanDockerNodes(repoRoot); err != nil {
+				return fmt.Errorf("clean docker nodes error:%w", err)
+			}
+			args := []string{filepath.Join(repoRoot, types.PlaygroundScript), "clean"}
+			if err := utils.ExecuteShell(args, repoRoot); err != nil {
+				return err
+			}
+		}
+	}
+
+	if clean {
+		if err := os.RemoveAll(topologyStatePath(repoRoot)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func upBitXHub(repoRoot string, b *topology.BitXHub) error {
+	release, err := versions.LookupBitXHub(b.Version)
+	if err != nil {
+		return err
+	}
+
+	running, err := bitxhub.BinaryNodesRunning(repoRoot)
+	if err != nil {
+		return err
+	}
+	exist, err := bitxhub.DockerNodesExist(repoRoot)
+	if err != nil {
+		return err
+	}
+	if running || exist {
+		color.Blue("BitXHub is already up, skip it")
+		return nil
+	}
+
+	if err := bitxhub.DownloadBitxhubBinary(repoRoot, b.Version); err != nil {
+		return fmt.Errorf("download binary error:%w", err)
+	}
+	if err := bitxhub.PrepareBitxhubBinary(repoRoot, b.Version); err != nil {
+		return err
+	}
+
+	genesis, err := loadGenesisSpec(b.Genesis)
+	if err != nil {
+		return err
+	}
+
+	target := filepath.Join(repoRoot, "bitxhub/.bitxhub")
+	generator := NewBitXHubConfigGenerator(b.Type, b.Mode, b.Consensus, target, b.Nodes, b.IPs, b.Subnet, b.TLS, b.Version, ports.ManifestPath(repoRoot), genesis, b.Seed)
+	ok, err := generator.Initialized()
+	if err != nil {
+		return err
+	}
+	if ok {
+		color.Blue("BitXHub configuration in %s already exists, use it", target)
+	} else if err := generator.InitConfig(); err != nil {
+		return err
+	}
+
+	if b.Type == types.TypeDocker {
+		configPath := filepath.Join(repoRoot, types.BxhConfigRepo, release.ConfigDir, types.BxhModifyConfig)
+		return bitxhub.StartDockerNodes(repoRoot, target, b.Version, b.Mode, configPath, b.Subnet, b.Nodes)
+	}
+
+	return bitxhub.StartBinaryNodes(repoRoot, target, b.Version, b.Mode, b.Nodes)
+}
+
+func upAppchain(repoRoot string, a *topology.Appchain) error {
+	switch a.Chain {
+	case types.ChainTypeEther:
+		return ethereum.StartEthereum(repoRoot, a.Type)
+	case types.ChainTypeFabric:
+		return fabric.Start(repoRoot, a.CryptoConfig)
+	}
+
+	return nil
+}
+
+func upPier(repoRoot string, b *topology.BitXHub, a *topology.Appchain, p *topology.Pier, progress *pierProgress, wait time.Duration) error {
+	release, err := versions.LookupPier(p.Version)
+	if err != nil {
+		return err
+	}
+
+	if err := pier.DownloadPierBinary(repoRoot, p.Version, runtime.GOOS); err != nil {
+		return fmt.Errorf("download pier binary error:%w", err)
+	}
+	pluginSys := runtime.GOOS
+	if p.Type == types.TypeDocker {
+		pluginSys = types.LinuxSystem
+	}
+	if err := pier.DownloadPierPlugin(repoRoot, a.Chain, p.Version, pluginSys); err != nil {
+		return fmt.Errorf("download pier binary error:%w", err)
+	}
+
+	pierRepo := filepath.Join(repoRoot, fmt.Sprintf("pier/.pier_%s", a.Chain))
+	if err := configTopologyPier(repoRoot, pierRepo, b, a, p); err != nil {
+		return err
+	}
+
+	if p.Type == types.TypeDocker {
+		cid, err := pier.DockerContainerID(repoRoot, a.Chain)
+		if err != nil {
+			return err
+		}
+		if cid == "" {
+			if err := pier.StartPierDocker(repoRoot, a.Chain, pierRepo, p.Version, p.HttpPort, p.PprofPort); err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.Mode == types.PierModeRelay {
+		if !progress.Registered {
+			if err := registerTopologyAppchain(repoRoot, pierRepo, a, p, release, wait); err != nil {
+				return err
+			}
+			progress.Registered = true
+		}
+
+		if !progress.RuleDeploy {
+			t, err := newAppchainTarget(repoRoot, "", pierRepo, a.Chain, p.Method, "", release)
+			if err != nil {
+				return err
+			}
+			if _, err := deployRule(t, filepath.Join(pierRepo, a.Chain, types.RuleName)); err != nil {
+				return err
+			}
+			progress.RuleDeploy = true
+		}
+	}
+
+	if p.Type == types.TypeBinary {
+		if fileutil.Exist(pier.PidPath(repoRoot, a.Chain)) {
+			color.Blue("pier of %s is already up, skip it", a.Name)
+			return nil
+		}
+		return pier.StartPierBinary(repoRoot, a.Chain, pierRepo, p.Version)
+	}
+
+	return nil
+}
+
+// registerTopologyAppchain registers the appchain of the pier unless its registration is open or approved,
+// and approves the registration proposal with the admin keys goduck generated
+func registerTopologyAppchain(repoRoot, pierRepo string, a *topology.Appchain, p *topology.Pier, release *versions.Pier, wait time.Duration) error {
+	gateway, err := localGateway(repoRoot)
+	if err != nil {
+		return err
+	}
+	registration, err := refreshRegistration(gateway, pierRepo)
+	if err != nil {
+		return err
+	}
+	if registration == nil || registration.Status == bxh.ProposalRejected {
+		if registration, err = registerAppchain(gateway, pierRepo, a.Chain, p.Method, release); err != nil {
+			return err
+		}
+	}
+	if !release.RegisterByProposal() || registration.ProposalID == "" {
+		return nil
+	}
+
+	admins, err := adminKeys(repoRoot)
+	if err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		return fmt.Errorf("no admin keys in %s to approve the registration of %s with", repoRoot, a.Name)
+	}
+	client := bxh.New(gateway)
+	proposal, err := client.Proposal(admins[0].Key, registration.ProposalID)
+	if err != nil {
+		return fmt.Errorf("get proposal %s: %w", registration.ProposalID, err)
+	}
+	if err := approveProposal(client, admins, proposal, wait); err != nil {
+		return fmt.Errorf("approve registration of %s: %w", a.Name, err)
+	}
+
+	_, err = refreshRegistration(gateway, pierRepo)
+	return err
+}
+
+// configTopologyPier generates configuration of the pier with PierConfigGenerator unless it exists
+func configTopologyPier(repoRoot, pierRepo string, b *topology.BitXHub, a *topology.Appchain, p *topology.Pier) error {
+	appPorts, appchainAddr, appchainIP, err := getAppchainParams(a.Chain, a.IP, strings.Replace(a.Ports, " ", "", -1), a.Addr, a.CryptoConfig)
+	if err != nil {
+		return err
+	}
+
+	bitxhubAddr := ""
+	var validators []string
+	if p.Mode == types.PierModeRelay {
+		if bitxhubAddr, validators, err = bitxhubEndpoint(repoRoot, b); err != nil {
+			return err
+		}
+	}
+
+	contractAddr := a.ContractAddr
+	if contractAddr == "" && a.Chain == types.ChainTypeEther {
+		contractAddr = defaultEtherContractAddr
+	}
+
+	pierPath := filepath.Join(repoRoot, "bin", fmt.Sprintf("pier_%s_%s", runtime.GOOS, p.Version), types.Pier)
+	generator := NewPierConfigGenerator(p.Mode, p.Type, bitxhubAddr, validators, p.Port, p.Peers, p.Connectors, p.Providers,
+		a.Chain, appchainIP, appchainAddr, appPorts, contractAddr, pierRepo, fmt.Sprintf("%t", p.TLS),
+		p.HttpPort, p.PprofPort, p.ApiPort, p.Version, pierPath, a.CryptoConfig, p.Method, p.Seed)
+
+	ok, err := generator.Initialized()
+	if err != nil {
+		return err
+	}
+	if ok {
+		color.Blue("Pier configuration in %s already exists, use it", pierRepo)
+		return nil
+	}
+
+	return generator.InitConfig()
+}
+
+// bitxhubEndpoint returns the grpc address of the first BitXHub node and the accounts of all nodes
+func bitxhubEndpoint(repoRoot string, b *topology.BitXHub) (string, []string, error) {
+	nodeRepo := filepath.Join(repoRoot, "bitxhub/.bitxhub", bitxhub.NodeNames(b.Mode, b.Nodes)[0])
+
+	manifest, err := ports.Load(ports.ManifestPath(repoRoot))
+	if err != nil {
+		return "", nil, err
+	}
+	grpcPort := ""
+	if allocated := manifest.Lookup(nodeRepo); allocated != nil {
+		grpcPort = strconv.Itoa(allocated.Grpc)
+	} else {
+		release, err := versions.LookupBitXHub(b.Version)
+		if err != nil {
+			return "", nil, err
+		}
+		configPath := filepath.Join(repoRoot, types.BxhConfigRepo, release.ConfigDir, types.BxhModifyConfig)
+		value, err := utils.GetModifyConfigValue(configPath, "grpc_port")
+		if err != nil {
+			return "", nil, err
+		}
+		grpcPort = strings.Fields(value)[0]
+	}
+
+	tree, err := toml.LoadFile(filepath.Join(nodeRepo, repo.NetworkConfigName))
+	if err != nil {
+		return "", nil, fmt.Errorf("load network config of bitxhub: %w", err)
+	}
+
+	netConfig := &NetworkConfig{}
+	if err := tree.Unmarshal(netConfig); err != nil {
+		return "", nil, fmt.Errorf("unmarshal network config of bitxhub: %w", err)
+	}
+
+	var validators []string
+	for _, n := range netConfig.Nodes {
+		validators = append(validators, n.Account)
+	}
+
+	return fmt.Sprintf("localhost:%s", grpcPort), validators, nil
+}
+
+func topologyStatePath(repoRoot string) string {
+	return filepath.Join(repoRoot, types.TopologyStateFile)
+}
+
+func loadTopologyState(repoRoot string) (*topologyState, error) {
+	state := &topologyState{Piers: make(map[string]*pierProgress)}
+	data, err := ioutil.ReadFile(topologyStatePath(repoRoot))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unmarshal topology state: %w", err)
+	}
+	if state.Piers == nil {
+		state.Piers = make(map[string]*pierProgress)
+	}
+
+	return state, nil
+}
+
+func saveTopologyState(repoRoot string, state *topologyState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(topologyStatePath(repoRoot), data, 0644)
+}
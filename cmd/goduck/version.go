@@ -1,11 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"path/filepath"
 
-	"github.com/meshplus/goduck/internal/repo"
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
 
 	"github.com/meshplus/goduck"
 	"github.com/urfave/cli/v2"
@@ -31,11 +31,10 @@ func getVersionCMD() *cli.Command {
 }
 
 func allVersion(ctx *cli.Context) error {
-	root, err := repo.PathRoot()
-	if err != nil {
-		return err
-	}
-	data, err := ioutil.ReadFile(filepath.Join(root, "release.json"))
+	data, err := json.MarshalIndent(map[string][]string{
+		types.BitXHub: versions.BitXHubReleases(),
+		types.Pier:    versions.PierReleases(),
+	}, "", "  ")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,121 @@
+package bxh
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/goduck/internal/versions"
+)
+
+// AppchainMgrContractAddr is the address of the built-in appchain manager contract
+const AppchainMgrContractAddr = "0x000000000000000000000000000000000000000e"
+
+// Appchain is the metadata an appchain is registered with
+type Appchain struct {
+	Name          string
+	Type          string
+	Desc          string
+	Version       string
+	Validators    string
+	ConsensusType string
+	// Method is the DID method of the appchain, it is registered as a method of the pier admin since
+	// BitXHub v1.8.0 and left empty before
+	Method  string
+	DocAddr string
+	DocHash string
+}
+
+// Registration is the result of an appchain registration
+type Registration struct {
+	AppchainID string `json:"appchain_id"`
+	ProposalID string `json:"proposal_id"`
+	Status     string `json:"status"`
+	TxHash     string `json:"tx_hash"`
+}
+
+// governanceResult is what the managers return for the proposals they submit
+type governanceResult struct {
+	ProposalID string `json:"proposal_id"`
+	Extra      []byte `json:"extra"`
+}
+
+// RegistrationRegistered is the status of appchains registered without a proposal, before BitXHub v1.6.0
+const RegistrationRegistered = "registered"
+
+// RegisterAppchain registers the appchain as the pier of key with the arguments the pier release passes, and
+// returns the registration with the status of its proposal, or registered if the release has no proposals
+func (c *Client) RegisterAppchain(key crypto.PrivateKey, chain *Appchain, release *versions.Pier) (*Registration, error) {
+	addr, err := key.PublicKey().Address()
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := key.PublicKey().Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	appchainID := addr.String()
+	var args []*Arg
+	if release.RegisterWithConsensus() {
+		args = []*Arg{
+			String(chain.Validators),
+			String(chain.ConsensusType),
+			String(chain.Type),
+			String(chain.Name),
+			String(chain.Desc),
+			String(chain.Version),
+			String(base64.StdEncoding.EncodeToString(pubKey)),
+		}
+	} else {
+		// the consensus type is an int32 and the pubkey is passed as it is before v1.7.0
+		consensusType := 0
+		if chain.ConsensusType != "" {
+			if consensusType, err = strconv.Atoi(chain.ConsensusType); err != nil {
+				return nil, fmt.Errorf("consensus type %s is not an integer: %w", chain.ConsensusType, err)
+			}
+		}
+		args = []*Arg{
+			String(chain.Validators),
+			Int32(int32(consensusType)),
+			String(chain.Type),
+			String(chain.Name),
+			String(chain.Desc),
+			String(chain.Version),
+			String(string(pubKey)),
+		}
+	}
+	if chain.Method != "" {
+		appchainID = fmt.Sprintf("did:bitxhub:%s:.", chain.Method)
+		adminDID := fmt.Sprintf("did:bitxhub:relayroot:%s", addr.String())
+		args = append([]*Arg{String(adminDID), String(chain.Method), String(chain.DocAddr), String(chain.DocHash)}, args...)
+	}
+
+	receipt, err := c.InvokeBVM(key, AppchainMgrContractAddr, "Register", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	registration := &Registration{
+		AppchainID: appchainID,
+		Status:     RegistrationRegistered,
+		TxHash:     receipt.TxHash,
+	}
+	if !release.RegisterByProposal() {
+		return registration, nil
+	}
+
+	ret := &governanceResult{}
+	if err := json.Unmarshal(receipt.Ret, ret); err != nil {
+		return nil, fmt.Errorf("unmarshal registration result %q: %w", receipt.Ret, err)
+	}
+	registration.ProposalID = ret.ProposalID
+	registration.Status = ProposalProposed
+	if proposal, err := c.Proposal(key, ret.ProposalID); err == nil {
+		registration.Status = proposal.Status
+	}
+
+	return registration, nil
+}
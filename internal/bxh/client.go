@@ -0,0 +1,258 @@
+// Package bxh is a client of the HTTP gateway of BitXHub nodes. It signs and sends BVM transactions the
+// way the bitxhub client does, so goduck does not shell out to the bitxhub binary in node containers.
+package bxh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/types"
+)
+
+// Client talks to the gateway of a BitXHub node
+type Client struct {
+	gateway string
+	http    *http.Client
+	// Wait is how long transactions are waited for their receipts
+	Wait time.Duration
+}
+
+// Receipt is the receipt of a transaction
+type Receipt struct {
+	TxHash string
+	Ret    []byte
+	Failed bool
+}
+
+// New returns the client of the gateway, which is an url like http://localhost:9091/v1/ or just host:port
+func New(gateway string) *Client {
+	if !strings.Contains(gateway, "://") {
+		gateway = "http://" + gateway
+	}
+	if !strings.Contains(strings.SplitN(gateway, "://", 2)[1], "/") {
+		gateway += "/v1/"
+	}
+	if !strings.HasSuffix(gateway, "/") {
+		gateway += "/"
+	}
+
+	return &Client{
+		gateway: gateway,
+		http:    &http.Client{Timeout: 10 * time.Second},
+		Wait:    30 * time.Second,
+	}
+}
+
+// Gateway returns the url of the gateway
+func (c *Client) Gateway() string {
+	return c.gateway
+}
+
+// PendingNonce returns the nonce of the next transaction of the account
+func (c *Client) PendingNonce(addr string) (uint64, error) {
+	var resp struct {
+		Data []byte `json:"data"`
+	}
+	if err := c.do(http.MethodGet, "pendingNonce/"+addr, nil, &resp); err != nil {
+		return 0, fmt.Errorf("get pending nonce: %w", err)
+	}
+	nonce, err := strconv.ParseUint(string(resp.Data), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse pending nonce %q: %w", resp.Data, err)
+	}
+
+	return nonce, nil
+}
+
+// InvokeBVM calls method of the built-in contract with args in a transaction signed by key, and waits for
+// its receipt. A failed receipt is returned as an error with the message the contract returns.
+func (c *Client) InvokeBVM(key crypto.PrivateKey, contract, method string, args ...*Arg) (*Receipt, error) {
+	receipt, err := c.send(key, contract, invokePayload(method, args))
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Failed {
+		return nil, fmt.Errorf("%s of %s failed: %s", method, contract, receipt.Ret)
+	}
+
+	return receipt, nil
+}
+
+// DeployXVM deploys the wasm contract in a transaction signed by key, and returns its address
+func (c *Client) DeployXVM(key crypto.PrivateKey, code []byte) (string, error) {
+	receipt, err := c.send(key, zeroAddr, deployPayload(code))
+	if err != nil {
+		return "", err
+	}
+	if receipt.Failed {
+		return "", fmt.Errorf("deploy contract failed: %s", receipt.Ret)
+	}
+
+	return types.NewAddress(receipt.Ret).String(), nil
+}
+
+// ViewBVM calls method of the built-in contract with args without changing the state
+func (c *Client) ViewBVM(key crypto.PrivateKey, contract, method string, args ...*Arg) ([]byte, error) {
+	tx, err := c.newTransaction(key, contract, invokePayload(method, args))
+	if err != nil {
+		return nil, err
+	}
+
+	var r receiptJSON
+	if err := c.do(http.MethodPost, "view", txJSON(tx), &r); err != nil {
+		return nil, fmt.Errorf("send view: %w", err)
+	}
+	receipt := r.receipt()
+	if receipt.Failed {
+		return nil, fmt.Errorf("%s of %s failed: %s", method, contract, receipt.Ret)
+	}
+
+	return receipt.Ret, nil
+}
+
+// Receipt returns the receipt of the transaction
+func (c *Client) Receipt(hash string) (*Receipt, error) {
+	var r receiptJSON
+	if err := c.do(http.MethodGet, "receipt/"+hash, nil, &r); err != nil {
+		return nil, err
+	}
+
+	return r.receipt(), nil
+}
+
+func (c *Client) waitReceipt(hash string) (*Receipt, error) {
+	deadline := time.Now().Add(c.Wait)
+	for {
+		receipt, err := c.Receipt(hash)
+		if err == nil {
+			return receipt, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("no receipt of transaction %s after %s: %w", hash, c.Wait, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// send sends the transaction with payload to the contract, and waits for its receipt
+func (c *Client) send(key crypto.PrivateKey, contract string, payload []byte) (*Receipt, error) {
+	tx, err := c.newTransaction(key, contract, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var sent struct {
+		TxHash string `json:"tx_hash"`
+	}
+	if err := c.do(http.MethodPost, "transaction", txJSON(tx), &sent); err != nil {
+		return nil, fmt.Errorf("send transaction: %w", err)
+	}
+
+	return c.waitReceipt(sent.TxHash)
+}
+
+func (c *Client) newTransaction(key crypto.PrivateKey, contract string, payload []byte) (*Transaction, error) {
+	from, err := key.PublicKey().Address()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := c.PendingNonce(from.String())
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &Transaction{
+		From:      from,
+		To:        types.NewAddressByStr(contract),
+		Timestamp: time.Now().UnixNano(),
+		Payload:   payload,
+		Nonce:     nonce,
+	}
+	if err := tx.Sign(key); err != nil {
+		return nil, fmt.Errorf("sign transaction: %w", err)
+	}
+
+	return tx, nil
+}
+
+// do sends a request to path of the gateway, body and the response are json
+func (c *Client) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.gateway+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	// the gateway answers errors as {"error": ..., "code": ..., "message": ...}
+	var gatewayErr struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &gatewayErr) == nil && (gatewayErr.Error != "" || gatewayErr.Message != "") {
+		if gatewayErr.Message != "" {
+			return fmt.Errorf("%s", gatewayErr.Message)
+		}
+		return fmt.Errorf("%s", gatewayErr.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// txJSON is the transaction in the json the gateway decodes pb.Transaction from
+func txJSON(tx *Transaction) map[string]interface{} {
+	return map[string]interface{}{
+		"from":             tx.From.String(),
+		"to":               tx.To.String(),
+		"timestamp":        strconv.FormatInt(tx.Timestamp, 10),
+		"payload":          tx.Payload,
+		"nonce":            strconv.FormatUint(tx.Nonce, 10),
+		"signature":        tx.Signature,
+		"transaction_hash": tx.Hash().String(),
+	}
+}
+
+// receiptJSON is pb.Receipt in json, the status is SUCCESS or FAILED, or their numbers
+type receiptJSON struct {
+	TxHash string          `json:"tx_hash"`
+	Ret    []byte          `json:"ret"`
+	Status json.RawMessage `json:"status"`
+}
+
+func (r *receiptJSON) receipt() *Receipt {
+	status := strings.Trim(string(r.Status), `"`)
+
+	return &Receipt{
+		TxHash: r.TxHash,
+		Ret:    r.Ret,
+		Failed: status == "1" || status == "FAILED",
+	}
+}
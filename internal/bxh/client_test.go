@@ -0,0 +1,305 @@
+package bxh
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	"github.com/meshplus/bitxhub-kit/types"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/stretchr/testify/require"
+)
+
+// sentTx is a transaction as the gateway receives it
+type sentTx struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Timestamp string `json:"timestamp"`
+	Payload   []byte `json:"payload"`
+	Nonce     string `json:"nonce"`
+	Signature []byte `json:"signature"`
+	Hash      string `json:"transaction_hash"`
+}
+
+// gateway is a fake gateway answering the receipt of every transaction and view by ret
+type gateway struct {
+	t     *testing.T
+	nonce uint64
+	ret   func(tx *sentTx) (string, string)
+	// pending is how many times the receipt of a transaction is not found before it is answered
+	pending int
+
+	mu      sync.Mutex
+	txs     []*sentTx
+	views   []*sentTx
+	queried map[string]int
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case strings.HasPrefix(path, "pendingNonce/"):
+		writeJSON(g.t, w, map[string]interface{}{"data": []byte(strconv.FormatUint(g.nonce, 10))})
+	case path == "transaction" || path == "view":
+		tx := &sentTx{}
+		require.Nil(g.t, json.NewDecoder(r.Body).Decode(tx))
+		if path == "view" {
+			g.views = append(g.views, tx)
+			ret, status := g.ret(tx)
+			writeJSON(g.t, w, map[string]interface{}{"tx_hash": tx.Hash, "ret": []byte(ret), "status": status})
+			return
+		}
+		g.txs = append(g.txs, tx)
+		writeJSON(g.t, w, map[string]interface{}{"tx_hash": tx.Hash})
+	case strings.HasPrefix(path, "receipt/"):
+		hash := strings.TrimPrefix(path, "receipt/")
+		g.queried[hash]++
+		if g.queried[hash] <= g.pending {
+			w.WriteHeader(http.StatusInternalServerError)
+			writeJSON(g.t, w, map[string]interface{}{"error": "not found in DB", "code": 2})
+			return
+		}
+		for _, tx := range g.txs {
+			if tx.Hash == hash {
+				ret, status := g.ret(tx)
+				writeJSON(g.t, w, map[string]interface{}{"tx_hash": hash, "ret": []byte(ret), "status": status})
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v interface{}) {
+	require.Nil(t, json.NewEncoder(w).Encode(v))
+}
+
+func newGateway(t *testing.T, ret func(tx *sentTx) (string, string)) (*gateway, *Client) {
+	g := &gateway{t: t, nonce: 3, ret: ret, queried: make(map[string]int)}
+	server := httptest.NewServer(g)
+	t.Cleanup(server.Close)
+
+	c := New(server.URL)
+	c.Wait = 5 * time.Second
+
+	return g, c
+}
+
+func newKey(t *testing.T) crypto.PrivateKey {
+	key, err := asym.GenerateKeyPair(crypto.Secp256k1)
+	require.Nil(t, err)
+
+	return key
+}
+
+// verifyTx checks the transaction is the one signed by key to the contract with the payload
+func verifyTx(t *testing.T, key crypto.PrivateKey, tx *sentTx, contract string, payload []byte, nonce uint64) {
+	from, err := key.PublicKey().Address()
+	require.Nil(t, err)
+	require.Equal(t, from.String(), tx.From)
+	require.Equal(t, contract, tx.To)
+	require.Equal(t, payload, tx.Payload)
+	require.Equal(t, strconv.FormatUint(nonce, 10), tx.Nonce)
+
+	timestamp, err := strconv.ParseInt(tx.Timestamp, 10, 64)
+	require.Nil(t, err)
+	signed := &Transaction{
+		From:      from,
+		To:        types.NewAddressByStr(tx.To),
+		Timestamp: timestamp,
+		Payload:   tx.Payload,
+		Nonce:     nonce,
+		Signature: tx.Signature,
+	}
+	ok, err := asym.Verify(crypto.Secp256k1, tx.Signature, signed.SignHash(), *from)
+	require.Nil(t, err)
+	require.True(t, ok)
+	require.Equal(t, signed.Hash().String(), tx.Hash)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		gateway string
+		want    string
+	}{
+		{"localhost:9091", "http://localhost:9091/v1/"},
+		{"http://localhost:9091", "http://localhost:9091/v1/"},
+		{"https://bxh.example.com/v1", "https://bxh.example.com/v1/"},
+		{"http://localhost:9091/v1/", "http://localhost:9091/v1/"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, New(tt.gateway).Gateway(), tt.gateway)
+	}
+}
+
+func TestInvokePayload(t *testing.T) {
+	// pb.TransactionData{Type: INVOKE, VmType: BVM, Payload: pb.InvokePayload{Method: "Vote", Args: [String("a")]}}
+	want := []byte{
+		0x08, 0x01, // type
+		0x22, 0x0d, // payload
+		0x0a, 0x04, 'V', 'o', 't', 'e', // method
+		0x12, 0x05, 0x08, 0x06, 0x1a, 0x01, 'a', // args
+	}
+	require.Equal(t, want, invokePayload("Vote", []*Arg{String("a")}))
+
+	// the type of int32 args is the default value, which is left out
+	want = []byte{
+		0x08, 0x01,
+		0x22, 0x09,
+		0x0a, 0x02, 'G', 'o',
+		0x12, 0x03, 0x1a, 0x01, '0',
+	}
+	require.Equal(t, want, invokePayload("Go", []*Arg{Int32(0)}))
+}
+
+func TestInvokeBVM(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		pending int
+		wantErr string
+	}{
+		{"success", "SUCCESS", 0, ""},
+		{"success in numbers", "0", 0, ""},
+		{"receipt found later", "SUCCESS", 2, ""},
+		{"failed", "FAILED", 0, "Vote of " + GovernanceContractAddr + " failed: no permission"},
+		{"failed in numbers", "1", 0, "failed: no permission"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ret := "ok"
+			if tt.wantErr != "" {
+				ret = "no permission"
+			}
+			g, c := newGateway(t, func(tx *sentTx) (string, string) { return ret, tt.status })
+			g.pending = tt.pending
+			key := newKey(t)
+
+			args := []*Arg{String("0x1-1"), String(ProposalApproved), String("")}
+			receipt, err := c.InvokeBVM(key, GovernanceContractAddr, "Vote", args...)
+			if tt.wantErr != "" {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, []byte("ok"), receipt.Ret)
+				require.Equal(t, g.txs[0].Hash, receipt.TxHash)
+			}
+
+			require.Len(t, g.txs, 1)
+			verifyTx(t, key, g.txs[0], GovernanceContractAddr, invokePayload("Vote", args), 3)
+			require.Equal(t, tt.pending+1, g.queried[g.txs[0].Hash])
+		})
+	}
+}
+
+func TestGatewayError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(t, w, map[string]interface{}{"error": "invalid nonce", "code": 3, "message": "tx nonce is too low"})
+	}))
+	defer server.Close()
+
+	_, err := New(server.URL).InvokeBVM(newKey(t), GovernanceContractAddr, "Vote")
+	require.EqualError(t, err, "get pending nonce: tx nonce is too low")
+}
+
+func TestProposal(t *testing.T) {
+	proposal := `{"id":"0x1-2","typ":"AppchainMgr","status":"proposed","obj_id":"0x1","des":"register",
+"approve_num":1,"against_num":0,"electorate_num":4,"threshold_num":3,
+"ballot_map":{"0xa":{"voter_addr":"0xa","approve":"approve","num":1,"reason":"","vote_time":1}}}`
+	g, c := newGateway(t, func(tx *sentTx) (string, string) {
+		if strings.Contains(string(tx.Payload), "GetProposalsByStatus") {
+			if strings.Contains(string(tx.Payload), ProposalProposed) {
+				return "[" + proposal + "]", "SUCCESS"
+			}
+			return "null", "SUCCESS"
+		}
+		return proposal, "SUCCESS"
+	})
+	key := newKey(t)
+
+	p, err := c.Proposal(key, "0x1-2")
+	require.Nil(t, err)
+	require.Equal(t, "0x1-2", p.Id)
+	require.Equal(t, "AppchainMgr", p.Typ)
+	require.False(t, p.Closed())
+	require.True(t, p.Voted("0xa"))
+	require.False(t, p.Voted("0xb"))
+	require.Equal(t, uint64(3), p.ThresholdNum)
+	require.Equal(t, uint64(1), p.BallotMap["0xa"].Num)
+	verifyTx(t, key, g.views[0], GovernanceContractAddr, invokePayload("GetProposal", []*Arg{String("0x1-2")}), 3)
+	require.Empty(t, g.txs)
+
+	all, err := c.Proposals(key, "")
+	require.Nil(t, err)
+	require.Len(t, all, 1)
+	require.Len(t, g.views, 1+len(ProposalStatuses))
+}
+
+func TestRegisterAppchain(t *testing.T) {
+	tests := []struct {
+		version    string
+		proposalID string
+		status     string
+		views      int
+	}{
+		{"v1.4.0", "", RegistrationRegistered, 0},
+		{"v1.6.0", "0x1-1", ProposalProposed, 1},
+		{"v1.8.0", "0x1-1", ProposalProposed, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			g, c := newGateway(t, func(tx *sentTx) (string, string) {
+				if strings.Contains(string(tx.Payload), "GetProposal") {
+					return `{"id":"0x1-1","status":"proposed"}`, "SUCCESS"
+				}
+				if tt.proposalID == "" {
+					return `{"id":"0x1"}`, "SUCCESS"
+				}
+				return `{"proposal_id":"0x1-1"}`, "SUCCESS"
+			})
+			release, err := versions.PierOf(tt.version)
+			require.Nil(t, err)
+			key := newKey(t)
+			chain := &Appchain{Name: "chainA", Type: "fabric", Desc: "chainA-description", Version: "1.4.3", Validators: "v"}
+			if release.RegisterWithConsensus() {
+				chain.ConsensusType = "consensusType"
+			}
+			if release.RegisterByMethod() {
+				chain.Method = "appchain1"
+			}
+
+			registration, err := c.RegisterAppchain(key, chain, release)
+			require.Nil(t, err)
+			require.Equal(t, tt.proposalID, registration.ProposalID)
+			require.Equal(t, tt.status, registration.Status)
+			require.Len(t, g.views, tt.views)
+			require.Len(t, g.txs, 1)
+			require.Equal(t, g.txs[0].Hash, registration.TxHash)
+
+			addr, err := key.PublicKey().Address()
+			require.Nil(t, err)
+			if release.RegisterByMethod() {
+				require.Equal(t, "did:bitxhub:appchain1:.", registration.AppchainID)
+			} else {
+				require.Equal(t, addr.String(), registration.AppchainID)
+			}
+		})
+	}
+}
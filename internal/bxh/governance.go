@@ -0,0 +1,124 @@
+package bxh
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+)
+
+// GovernanceContractAddr is the address of the built-in governance contract
+const GovernanceContractAddr = "0x0000000000000000000000000000000000000012"
+
+// proposal statuses
+const (
+	ProposalProposed = "proposed"
+	ProposalApproved = "approve"
+	ProposalRejected = "reject"
+)
+
+// ProposalStatuses are the statuses proposals can be listed by
+var ProposalStatuses = []string{ProposalProposed, ProposalApproved, ProposalRejected}
+
+// ProposalTypes are the managers proposals are submitted to
+var ProposalTypes = []string{"AppchainMgr", "RuleMgr", "NodeMgr", "ServiceMgr"}
+
+// Proposal is a proposal of the governance contract
+type Proposal struct {
+	Id            string             `json:"id"`
+	Typ           string             `json:"typ"`
+	Status        string             `json:"status"`
+	ObjId         string             `json:"obj_id"`
+	Des           string             `json:"des"`
+	EventType     json.RawMessage    `json:"event_type,omitempty"`
+	ApproveNum    uint64             `json:"approve_num"`
+	AgainstNum    uint64             `json:"against_num"`
+	ElectorateNum uint64             `json:"electorate_num"`
+	ThresholdNum  uint64             `json:"threshold_num"`
+	BallotMap     map[string]*Ballot `json:"ballot_map"`
+}
+
+// Ballot is the vote of an admin on a proposal
+type Ballot struct {
+	VoterAddr string `json:"voter_addr"`
+	Approve   string `json:"approve"`
+	Num       uint64 `json:"num"`
+	Reason    string `json:"reason"`
+	VoteTime  int64  `json:"vote_time"`
+}
+
+// Closed reports whether the proposal is no longer voted on
+func (p *Proposal) Closed() bool {
+	return p.Status != ProposalProposed
+}
+
+// Voted reports whether the admin of addr has voted on the proposal
+func (p *Proposal) Voted(addr string) bool {
+	_, ok := p.BallotMap[addr]
+
+	return ok
+}
+
+// Proposals returns the proposals of status, or of all statuses if it is empty. key signs the queries.
+func (c *Client) Proposals(key crypto.PrivateKey, status string) ([]*Proposal, error) {
+	statuses := ProposalStatuses
+	if status != "" {
+		statuses = []string{status}
+	}
+
+	var proposals []*Proposal
+	for _, s := range statuses {
+		ret, err := c.ViewBVM(key, GovernanceContractAddr, "GetProposalsByStatus", String(s))
+		if err != nil {
+			return nil, err
+		}
+		var ps []*Proposal
+		if err := json.Unmarshal(ret, &ps); err != nil {
+			return nil, fmt.Errorf("unmarshal proposals: %w", err)
+		}
+		proposals = append(proposals, ps...)
+	}
+
+	return proposals, nil
+}
+
+// Proposal returns the proposal of id
+func (c *Client) Proposal(key crypto.PrivateKey, id string) (*Proposal, error) {
+	ret, err := c.ViewBVM(key, GovernanceContractAddr, "GetProposal", String(id))
+	if err != nil {
+		return nil, err
+	}
+
+	proposal := &Proposal{}
+	if err := json.Unmarshal(ret, proposal); err != nil {
+		return nil, fmt.Errorf("unmarshal proposal: %w", err)
+	}
+
+	return proposal, nil
+}
+
+// Vote votes on the proposal of id as the admin of key
+func (c *Client) Vote(key crypto.PrivateKey, id string, approve bool, reason string) error {
+	info := ProposalRejected
+	if approve {
+		info = ProposalApproved
+	}
+	_, err := c.InvokeBVM(key, GovernanceContractAddr, "Vote", String(id), String(info), String(reason))
+
+	return err
+}
+
+// submit calls method of the manager contract which submits a proposal, and returns the id of the proposal
+func (c *Client) submit(key crypto.PrivateKey, contract, method string, args ...*Arg) (string, error) {
+	receipt, err := c.InvokeBVM(key, contract, method, args...)
+	if err != nil {
+		return "", err
+	}
+
+	ret := &governanceResult{}
+	if err := json.Unmarshal(receipt.Ret, ret); err != nil {
+		return "", fmt.Errorf("unmarshal %s result %q: %w", method, receipt.Ret, err)
+	}
+
+	return ret.ProposalID, nil
+}
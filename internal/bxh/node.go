@@ -0,0 +1,59 @@
+package bxh
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+)
+
+// NodeMgrContractAddr is the address of the built-in node manager contract
+const NodeMgrContractAddr = "0x0000000000000000000000000000000000000014"
+
+// NodeTypeVP is the type of nodes taking part in consensus
+const NodeTypeVP = "vpNode"
+
+// node statuses
+const (
+	NodeAvailable   = "available"
+	NodeUnavailable = "unavailable"
+)
+
+// Node is a node registered to the node manager
+type Node struct {
+	VPNodeId uint64 `json:"vp_node_id"`
+	Pid      string `json:"pid"`
+	Account  string `json:"account"`
+	NodeType string `json:"node_type"`
+	Status   string `json:"status"`
+}
+
+// Nodes returns the nodes registered to the node manager
+func (c *Client) Nodes(key crypto.PrivateKey) ([]*Node, error) {
+	ret, err := c.ViewBVM(key, NodeMgrContractAddr, "Nodes")
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []*Node
+	if err := json.Unmarshal(ret, &nodes); err != nil {
+		return nil, fmt.Errorf("unmarshal nodes: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// RegisterNode registers the node to join the cluster, and returns the id of the proposal it submits
+func (c *Client) RegisterNode(key crypto.PrivateKey, node *Node) (string, error) {
+	info, err := json.Marshal(node)
+	if err != nil {
+		return "", err
+	}
+
+	return c.submit(key, NodeMgrContractAddr, "RegisterNode", Bytes(info))
+}
+
+// LogoutNode removes the node of pid from the cluster, and returns the id of the proposal it submits
+func (c *Client) LogoutNode(key crypto.PrivateKey, pid string) (string, error) {
+	return c.submit(key, NodeMgrContractAddr, "LogoutNode", String(pid))
+}
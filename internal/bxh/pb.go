@@ -0,0 +1,174 @@
+package bxh
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/types"
+)
+
+// types of BVM contract arguments, as pb.Arg_Type of bitxhub-model
+const (
+	argI32    = 0
+	argU64    = 3
+	argString = 6
+	argBytes  = 7
+	argBool   = 8
+)
+
+// TransactionData types and VM types of bitxhub-model
+const (
+	txInvoke = 1
+	vmBVM    = 0
+	vmXVM    = 1
+)
+
+// zeroAddr is the receiver of the transactions deploying contracts
+const zeroAddr = "0x0000000000000000000000000000000000000000"
+
+// Arg is an argument of a BVM contract method
+type Arg struct {
+	typ   uint64
+	value []byte
+}
+
+// String returns a string argument
+func String(s string) *Arg {
+	return &Arg{typ: argString, value: []byte(s)}
+}
+
+// Bytes returns a bytes argument
+func Bytes(b []byte) *Arg {
+	return &Arg{typ: argBytes, value: b}
+}
+
+// Bool returns a bool argument
+func Bool(b bool) *Arg {
+	return &Arg{typ: argBool, value: []byte(strconv.FormatBool(b))}
+}
+
+// Int32 returns an int32 argument
+func Int32(i int32) *Arg {
+	return &Arg{typ: argI32, value: []byte(strconv.Itoa(int(i)))}
+}
+
+// Uint64 returns a uint64 argument
+func Uint64(i uint64) *Arg {
+	return &Arg{typ: argU64, value: []byte(strconv.FormatUint(i, 10))}
+}
+
+// Transaction is a BitXHub transaction, encoded as pb.Transaction of bitxhub-model
+type Transaction struct {
+	From      *types.Address
+	To        *types.Address
+	Timestamp int64
+	Payload   []byte
+	Nonce     uint64
+	Signature []byte
+}
+
+// Sign signs the transaction with key
+func (tx *Transaction) Sign(key crypto.PrivateKey) error {
+	sign, err := key.Sign(tx.SignHash())
+	if err != nil {
+		return err
+	}
+	tx.Signature = sign
+
+	return nil
+}
+
+// SignHash is the hash signed by the sender, it leaves out the signature
+func (tx *Transaction) SignHash() []byte {
+	sum := sha256.Sum256(tx.marshal(false))
+
+	return sum[:]
+}
+
+// Hash is the hash of the signed transaction
+func (tx *Transaction) Hash() *types.Hash {
+	sum := sha256.Sum256(tx.marshal(true))
+
+	return types.NewHash(sum[:])
+}
+
+func (tx *Transaction) marshal(signed bool) []byte {
+	var b buffer
+	b.bytes(2, tx.From.Bytes())
+	b.bytes(3, tx.To.Bytes())
+	b.varint(4, uint64(tx.Timestamp))
+	b.bytes(6, tx.Payload)
+	b.varint(8, tx.Nonce)
+	if signed {
+		b.bytes(10, tx.Signature)
+	}
+
+	return b
+}
+
+// invokePayload encodes the pb.TransactionData invoking method of a BVM contract with args
+func invokePayload(method string, args []*Arg) []byte {
+	var invoke buffer
+	invoke.bytes(1, []byte(method))
+	for _, arg := range args {
+		var a buffer
+		a.varint(1, arg.typ)
+		a.bytes(3, arg.value)
+		invoke.message(2, a)
+	}
+
+	var data buffer
+	data.varint(1, txInvoke)
+	data.varint(3, vmBVM)
+	data.bytes(4, invoke)
+
+	return data
+}
+
+// deployPayload encodes the pb.TransactionData deploying the wasm contract
+func deployPayload(code []byte) []byte {
+	var data buffer
+	data.varint(1, txInvoke)
+	data.varint(3, vmXVM)
+	data.bytes(4, code)
+
+	return data
+}
+
+// buffer encodes protobuf fields, which are written in the order of their numbers and left out if they
+// have the default value, like the generated code does
+type buffer []byte
+
+func (b *buffer) tag(field int, wireType uint64) {
+	b.uvarint(uint64(field)<<3 | wireType)
+}
+
+func (b *buffer) uvarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	*b = append(*b, buf[:n]...)
+}
+
+func (b *buffer) varint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.tag(field, 0)
+	b.uvarint(v)
+}
+
+func (b *buffer) bytes(field int, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	b.message(field, v)
+}
+
+// message writes an embedded message, which is kept even if it is empty
+func (b *buffer) message(field int, v []byte) {
+	b.tag(field, 2)
+	b.uvarint(uint64(len(v)))
+	*b = append(*b, v...)
+}
@@ -0,0 +1,65 @@
+package bxh
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+)
+
+// RuleMgrContractAddr is the address of the built-in rule manager contract
+const RuleMgrContractAddr = "0x000000000000000000000000000000000000000c"
+
+// Rule is a validation rule of an appchain
+type Rule struct {
+	Address string `json:"address"`
+	ChainId string `json:"chain_id"`
+	Status  string `json:"status"`
+	Master  bool   `json:"master"`
+}
+
+// Rules returns the rules bound to the appchain
+func (c *Client) Rules(key crypto.PrivateKey, chainID string) ([]*Rule, error) {
+	ret, err := c.ViewBVM(key, RuleMgrContractAddr, "Rules", String(chainID))
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []*Rule
+	if err := json.Unmarshal(ret, &rules); err != nil {
+		return nil, fmt.Errorf("unmarshal rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// RuleAddress returns the address of the only rule of the appchain, for BitXHub before v1.8.0
+func (c *Client) RuleAddress(key crypto.PrivateKey, chainID, chainType string) (string, error) {
+	ret, err := c.ViewBVM(key, RuleMgrContractAddr, "GetRuleAddress", String(chainID), String(chainType))
+	if err != nil {
+		return "", err
+	}
+
+	return string(ret), nil
+}
+
+// RegisterRule registers the rule of addr to the appchain, and returns the id of the proposal it submits
+func (c *Client) RegisterRule(key crypto.PrivateKey, chainID, addr string) (string, error) {
+	return c.submit(key, RuleMgrContractAddr, "RegisterRule", String(chainID), String(addr))
+}
+
+// BindRule binds the rule of addr to the appchain, and returns the id of the proposal it submits
+func (c *Client) BindRule(key crypto.PrivateKey, chainID, addr string) (string, error) {
+	return c.submit(key, RuleMgrContractAddr, "BindRule", String(chainID), String(addr))
+}
+
+// UnbindRule unbinds the rule of addr from the appchain, and returns the id of the proposal it submits
+func (c *Client) UnbindRule(key crypto.PrivateKey, chainID, addr string) (string, error) {
+	return c.submit(key, RuleMgrContractAddr, "UnbindRule", String(chainID), String(addr))
+}
+
+// UpdateMasterRule makes the rule of addr the master rule of the appchain, and returns the id of the
+// proposal it submits
+func (c *Client) UpdateMasterRule(key crypto.PrivateKey, chainID, addr string) (string, error) {
+	return c.submit(key, RuleMgrContractAddr, "UpdateMasterRule", String(chainID), String(addr))
+}
@@ -0,0 +1,101 @@
+package bxh
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+)
+
+// ServiceMgrContractAddr is the address of the built-in service manager contract
+const ServiceMgrContractAddr = "0x0000000000000000000000000000000000000016"
+
+// ServiceTypes are the types of interchain services
+var ServiceTypes = []string{"CallContract", "DepositCertificate", "DataMigration"}
+
+// Service is an interchain service an appchain exposes
+type Service struct {
+	ChainID    string              `json:"chain_id"`
+	ServiceID  string              `json:"service_id"`
+	Name       string              `json:"name"`
+	Type       string              `json:"type"`
+	Intro      string              `json:"intro"`
+	Ordered    bool                `json:"ordered"`
+	Permission map[string]struct{} `json:"permission"`
+	Details    string              `json:"details"`
+	Status     string              `json:"status"`
+}
+
+// ChainServiceID is the id of the service among the services of all appchains
+func (s *Service) ChainServiceID() string {
+	return fmt.Sprintf("%s:%s", s.ChainID, s.ServiceID)
+}
+
+// Services returns the services of the appchain
+func (c *Client) Services(key crypto.PrivateKey, chainID string) ([]*Service, error) {
+	ret, err := c.ViewBVM(key, ServiceMgrContractAddr, "GetServicesByAppchainID", String(chainID))
+	if err != nil {
+		return nil, err
+	}
+
+	var services []*Service
+	if err := json.Unmarshal(ret, &services); err != nil {
+		return nil, fmt.Errorf("unmarshal services: %w", err)
+	}
+
+	return services, nil
+}
+
+// RegisterService registers the service, permitted to the appchains of its permission only if there are
+// any, and returns the id of the proposal it submits
+func (c *Client) RegisterService(key crypto.PrivateKey, s *Service, reason string) (string, error) {
+	return c.submit(key, ServiceMgrContractAddr, "RegisterService",
+		String(s.ChainID),
+		String(s.ServiceID),
+		String(s.Name),
+		String(s.Type),
+		String(s.Intro),
+		Bool(s.Ordered),
+		String(permits(s)),
+		String(s.Details),
+		String(reason),
+	)
+}
+
+// UpdateService updates the name, intro, order, permission and details of the service, and returns the id
+// of the proposal it submits
+func (c *Client) UpdateService(key crypto.PrivateKey, s *Service, reason string) (string, error) {
+	return c.submit(key, ServiceMgrContractAddr, "UpdateService",
+		String(s.ChainServiceID()),
+		String(s.Name),
+		String(s.Intro),
+		Bool(s.Ordered),
+		String(permits(s)),
+		String(s.Details),
+		String(reason),
+	)
+}
+
+// FreezeService freezes the service of chainServiceID, and returns the id of the proposal it submits
+func (c *Client) FreezeService(key crypto.PrivateKey, chainServiceID, reason string) (string, error) {
+	return c.submit(key, ServiceMgrContractAddr, "FreezeService", String(chainServiceID), String(reason))
+}
+
+// ActivateService activates the frozen service of chainServiceID, and returns the id of the proposal it
+// submits
+func (c *Client) ActivateService(key crypto.PrivateKey, chainServiceID, reason string) (string, error) {
+	return c.submit(key, ServiceMgrContractAddr, "ActivateService", String(chainServiceID), String(reason))
+}
+
+// permits joins the appchains the service is permitted to with commas, as the service manager takes them
+func permits(s *Service) string {
+	var chains []string
+	for chain := range s.Permission {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+
+	return strings.Join(chains, ",")
+}
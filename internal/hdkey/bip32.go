@@ -0,0 +1,88 @@
+package hdkey
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	ecdsa2 "github.com/meshplus/bitxhub-kit/crypto/asym/ecdsa"
+)
+
+// EthereumPath is the BIP-44 path of ethereum accounts, the index of an account is appended to it
+const EthereumPath = "m/44'/60'/0'/0"
+
+// Hardened is added to the index of hardened steps
+const Hardened uint32 = 1 << 31
+
+// bip32Key keys the HMAC turning a BIP-39 seed into the BIP-32 master key
+var bip32Key = []byte("Bitcoin seed")
+
+// ParsePath parses a BIP-32 path like m/44'/60'/0'/0, hardened steps end with ' or h
+func ParsePath(path string) ([]uint32, error) {
+	elems := strings.Split(strings.TrimSpace(path), "/")
+	if elems[0] != "m" {
+		return nil, fmt.Errorf("path %s does not start with m", path)
+	}
+
+	var indexes []uint32
+	for _, elem := range elems[1:] {
+		offset := uint32(0)
+		if strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h") {
+			offset = Hardened
+			elem = elem[:len(elem)-1]
+		}
+		index, err := strconv.ParseUint(elem, 10, 32)
+		if err != nil || uint32(index) >= Hardened {
+			return nil, fmt.Errorf("invalid element %s of path %s", elem, path)
+		}
+		indexes = append(indexes, uint32(index)+offset)
+	}
+
+	return indexes, nil
+}
+
+// BIP32 derives the Secp256k1 key at path from seed as BIP-32 does, so wallets derive the same accounts
+// from the same mnemonic
+func BIP32(seed []byte, path []uint32) (*ecdsa.PrivateKey, error) {
+	curve := ecdsa2.S256()
+	n := curve.Params().N
+
+	sum := hmacSum(bip32Key, seed)
+	k := new(big.Int).SetBytes(sum[:32])
+	chain := sum[32:]
+	if k.Sign() == 0 || k.Cmp(n) >= 0 {
+		return nil, fmt.Errorf("invalid master key")
+	}
+
+	for _, index := range path {
+		var data []byte
+		if index >= Hardened {
+			data = append([]byte{0}, ecdsa2.PaddedBigBytes(k, 32)...)
+		} else {
+			x, y := curve.ScalarBaseMult(ecdsa2.PaddedBigBytes(k, 32))
+			data = append([]byte{byte(2 + y.Bit(0))}, ecdsa2.PaddedBigBytes(x, 32)...)
+		}
+		var suffix [4]byte
+		binary.BigEndian.PutUint32(suffix[:], index)
+		sum := hmacSum(chain, append(data, suffix[:]...))
+
+		il := new(big.Int).SetBytes(sum[:32])
+		if il.Cmp(n) >= 0 {
+			return nil, fmt.Errorf("invalid child %d, use another index", index)
+		}
+		k = il.Add(il, k).Mod(il, n)
+		if k.Sign() == 0 {
+			return nil, fmt.Errorf("invalid child %d, use another index", index)
+		}
+		chain = sum[32:]
+	}
+
+	priv := &ecdsa.PrivateKey{D: k}
+	priv.Curve = curve
+	priv.X, priv.Y = curve.ScalarBaseMult(ecdsa2.PaddedBigBytes(k, 32))
+
+	return priv, nil
+}
@@ -0,0 +1,125 @@
+// Package hdkey derives keys from a seed along paths like bitxhub/node/node1, so that the keys of a
+// generated repo are the same whenever it is generated with the same seed
+package hdkey
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/meshplus/bitxhub-kit/crypto/asym"
+	ecdsa2 "github.com/meshplus/bitxhub-kit/crypto/asym/ecdsa"
+)
+
+// masterKey keys the HMAC turning a seed into the master node
+var masterKey = []byte("goduck seed")
+
+var curves = map[crypto.KeyType]elliptic.Curve{
+	crypto.Secp256k1:  ecdsa2.S256(),
+	crypto.ECDSA_P256: elliptic.P256(),
+	crypto.ECDSA_P384: elliptic.P384(),
+	crypto.ECDSA_P521: elliptic.P521(),
+}
+
+// Deriver is a node of the derivation tree. A nil Deriver generates random keys, so callers
+// without a seed go through the same code.
+type Deriver struct {
+	key   []byte
+	chain []byte
+}
+
+// New returns the master node of seed, or nil if seed is empty
+func New(seed string) *Deriver {
+	if seed == "" {
+		return nil
+	}
+
+	return split(hmacSum(masterKey, []byte(seed)))
+}
+
+// Child returns the node at path below d, every element is a hardened step like in BIP-32
+func (d *Deriver) Child(path ...string) *Deriver {
+	if d == nil {
+		return nil
+	}
+
+	child := d
+	for _, elem := range path {
+		data := append(append(append([]byte{}, child.key...), 0), elem...)
+		child = split(hmacSum(child.chain, data))
+	}
+
+	return child
+}
+
+// ECDSA returns the key of curve at path below d
+func (d *Deriver) ECDSA(curve elliptic.Curve, path ...string) (*ecdsa.PrivateKey, error) {
+	if d == nil {
+		return ecdsa.GenerateKey(curve, rand.Reader)
+	}
+
+	node := d.Child(path...)
+	params := curve.Params()
+	size := (params.BitSize + 7) / 8
+	// candidates out of [1, N-1] are skipped, which happens with a negligible probability
+	for counter := uint32(0); ; counter++ {
+		k := new(big.Int).SetBytes(node.expand([]byte(params.Name), counter, size))
+		k.Rsh(k, uint(size*8-params.BitSize))
+		if k.Sign() == 0 || k.Cmp(params.N) >= 0 {
+			continue
+		}
+
+		priv := &ecdsa.PrivateKey{D: k}
+		priv.Curve = curve
+		priv.X, priv.Y = curve.ScalarBaseMult(k.Bytes())
+		return priv, nil
+	}
+}
+
+// Key returns the key of typ at path below d
+func (d *Deriver) Key(typ crypto.KeyType, path ...string) (crypto.PrivateKey, error) {
+	if d == nil {
+		return asym.GenerateKeyPair(typ)
+	}
+	curve, ok := curves[typ]
+	if !ok {
+		return asym.GenerateKeyPair(typ)
+	}
+
+	priv, err := d.ECDSA(curve, path...)
+	if err != nil {
+		return nil, err
+	}
+
+	return asym.PrivateKeyFromStdKey(priv)
+}
+
+// expand returns size bytes derived from the node for label and counter
+func (d *Deriver) expand(label []byte, counter uint32, size int) []byte {
+	var out []byte
+	for block := uint32(0); len(out) < size; block++ {
+		var suffix [8]byte
+		binary.BigEndian.PutUint32(suffix[:4], counter)
+		binary.BigEndian.PutUint32(suffix[4:], block)
+		data := append(append(append([]byte{}, d.key...), label...), suffix[:]...)
+		out = append(out, hmacSum(d.chain, data)...)
+	}
+
+	return out[:size]
+}
+
+func split(sum []byte) *Deriver {
+	return &Deriver{key: sum[:32], chain: sum[32:]}
+}
+
+func hmacSum(key, data []byte) []byte {
+	mac := hmac.New(sha512.New, key)
+	mac.Write(data)
+
+	return mac.Sum(nil)
+}
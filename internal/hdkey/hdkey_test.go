@@ -0,0 +1,84 @@
+package hdkey
+
+import (
+	"crypto/elliptic"
+	"encoding/hex"
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerive(t *testing.T) {
+	tests := []struct {
+		name   string
+		seed   string
+		path   []string
+		other  string
+		opath  []string
+		sameAs bool
+	}{
+		{"same seed and path", "ci-fixture", []string{"bitxhub", "node", "node1"}, "ci-fixture", []string{"bitxhub", "node", "node1"}, true},
+		{"different path", "ci-fixture", []string{"bitxhub", "node", "node1"}, "ci-fixture", []string{"bitxhub", "node", "node2"}, false},
+		{"different seed", "ci-fixture", []string{"bitxhub", "node", "node1"}, "ci-fixture2", []string{"bitxhub", "node", "node1"}, false},
+		{"path elements are not concatenated", "ci-fixture", []string{"bitxhub", "node1"}, "ci-fixture", []string{"bitxhubnode", "1"}, false},
+		{"parent and child", "ci-fixture", []string{"bitxhub"}, "ci-fixture", []string{"bitxhub", "node"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.seed).ECDSA(elliptic.P256(), tt.path...)
+			require.Nil(t, err)
+			b, err := New(tt.other).ECDSA(elliptic.P256(), tt.opath...)
+			require.Nil(t, err)
+			require.Equal(t, tt.sameAs, a.D.Cmp(b.D) == 0)
+
+			k1, err := New(tt.seed).Key(crypto.Secp256k1, tt.path...)
+			require.Nil(t, err)
+			k2, err := New(tt.other).Key(crypto.Secp256k1, tt.opath...)
+			require.Nil(t, err)
+			addr1, err := k1.PublicKey().Address()
+			require.Nil(t, err)
+			addr2, err := k2.PublicKey().Address()
+			require.Nil(t, err)
+			require.Equal(t, tt.sameAs, addr1.String() == addr2.String())
+		})
+	}
+}
+
+func TestChild(t *testing.T) {
+	keys := New("ci-fixture")
+	a, err := keys.Child("bitxhub").ECDSA(elliptic.P256(), "node", "node1")
+	require.Nil(t, err)
+	b, err := keys.ECDSA(elliptic.P256(), "bitxhub", "node", "node1")
+	require.Nil(t, err)
+	require.Equal(t, a.D, b.D)
+	require.True(t, elliptic.P256().IsOnCurve(a.X, a.Y))
+
+	// without a seed keys are random
+	require.Nil(t, New(""))
+	require.Nil(t, New("").Child("bitxhub"))
+	c, err := New("").ECDSA(elliptic.P256(), "node")
+	require.Nil(t, err)
+	d, err := New("").ECDSA(elliptic.P256(), "node")
+	require.Nil(t, err)
+	require.NotEqual(t, c.D, d.D)
+}
+
+func TestBIP32(t *testing.T) {
+	// test vector 1 of BIP-32
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.Nil(t, err)
+	path, err := ParsePath("m/0'/1")
+	require.Nil(t, err)
+	require.Equal(t, []uint32{Hardened, 1}, path)
+
+	priv, err := BIP32(seed, path)
+	require.Nil(t, err)
+	require.Equal(t, "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368", hex.EncodeToString(priv.D.Bytes()))
+
+	for _, p := range []string{"44'/60'", "m/x", "m/2147483648"} {
+		_, err := ParsePath(p)
+		require.NotNil(t, err, p)
+	}
+}
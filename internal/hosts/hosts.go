@@ -0,0 +1,99 @@
+// Package hosts handles hosts of nodes, which are IPv4 addresses, IPv6 addresses or DNS names
+package hosts
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Separator separates the hosts of one node, e.g. 10.0.0.1|node1.example.com
+const Separator = "|"
+
+// Split returns the hosts of a node given as host1|host2
+func Split(entry string) []string {
+	var hosts []string
+	for _, h := range strings.Split(entry, Separator) {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, strings.Trim(h, "[]"))
+		}
+	}
+
+	return hosts
+}
+
+// Check reports whether the host is an IP address or a valid DNS name
+func Check(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+
+	name := strings.TrimSuffix(host, ".")
+	if name == "" || len(name) > 253 {
+		return fmt.Errorf("%q is not an IP address or a DNS name", host)
+	}
+	numeric := true
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("%q is not an IP address or a DNS name", host)
+		}
+		for _, c := range label {
+			switch {
+			case c >= '0' && c <= '9':
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-':
+				numeric = false
+			default:
+				return fmt.Errorf("%q is not an IP address or a DNS name", host)
+			}
+		}
+	}
+	if numeric {
+		return fmt.Errorf("%q is not a valid IPv4 address", host)
+	}
+
+	return nil
+}
+
+// CheckAll checks every host of the node entries
+func CheckAll(entries []string) error {
+	for _, entry := range entries {
+		hosts := Split(entry)
+		if len(hosts) == 0 {
+			return fmt.Errorf("empty host")
+		}
+		for _, h := range hosts {
+			if err := Check(h); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Protocol returns the multiaddr protocol of the host, one of ip4, ip6 or dns4
+func Protocol(host string) string {
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "dns4"
+	case ip.To4() != nil:
+		return "ip4"
+	default:
+		return "ip6"
+	}
+}
+
+// P2PAddr returns the libp2p multiaddr of the host like /ip4/127.0.0.1/tcp/4001/p2p/<pid>
+func P2PAddr(host string, port int, pid string) string {
+	return fmt.Sprintf("/%s/%s/tcp/%d/p2p/%s", Protocol(host), host, port, pid)
+}
+
+// SCPTarget returns the scp destination user@host:path, IPv6 addresses are put in brackets
+func SCPTarget(user, host, path string) string {
+	if Protocol(host) == "ip6" {
+		host = "[" + host + "]"
+	}
+
+	return fmt.Sprintf("%s@%s:%s", user, host, path)
+}
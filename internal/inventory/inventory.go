@@ -0,0 +1,105 @@
+// Package inventory records the BitXHub nodes and piers goduck deployed to remote servers
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/hosts"
+)
+
+// FileName is the name of the inventory in the deploy directory
+const FileName = "inventory.json"
+
+// Host is a BitXHub node or a pier deployed to a server
+type Host struct {
+	Name string `json:"name"`
+	Host string `json:"host"`
+	User string `json:"user"`
+	// Repo is the repo directory on the server, relative to the home of user
+	Repo string `json:"repo"`
+	// PidFile is the file recording the pid on the server, relative to the home of user
+	PidFile string `json:"pid_file,omitempty"`
+	Version string `json:"version"`
+}
+
+// Who returns the ssh destination user@host
+func (h *Host) Who() string {
+	return fmt.Sprintf("%s@%s", h.User, h.Host)
+}
+
+// SCPTarget returns the scp destination of the path relative to repo
+func (h *Host) SCPTarget(path string) string {
+	return hosts.SCPTarget(h.User, h.Host, filepath.Join(h.Repo, path))
+}
+
+// BitXHub is the deployed BitXHub cluster
+type BitXHub struct {
+	Version   string `json:"version"`
+	Consensus string `json:"consensus"`
+	// Config is the local directory of the generated configs, which holds the CA of the cluster
+	Config string  `json:"config"`
+	Nodes  []*Host `json:"nodes"`
+}
+
+// Inventory is what goduck deployed from a repo
+type Inventory struct {
+	BitXHub *BitXHub `json:"bitxhub,omitempty"`
+	Piers   []*Host  `json:"piers,omitempty"`
+}
+
+// Dir returns the directory of deployed configs and the inventory
+func Dir(repoRoot string) string {
+	return filepath.Join(repoRoot, "deploy")
+}
+
+// Path returns the path of the inventory
+func Path(repoRoot string) string {
+	return filepath.Join(Dir(repoRoot), FileName)
+}
+
+// Load reads the inventory of the repo, which is empty if nothing was deployed
+func Load(repoRoot string) (*Inventory, error) {
+	inv := &Inventory{}
+	if !fileutil.Exist(Path(repoRoot)) {
+		return inv, nil
+	}
+
+	data, err := ioutil.ReadFile(Path(repoRoot))
+	if err != nil {
+		return nil, fmt.Errorf("read inventory: %w", err)
+	}
+	if err := json.Unmarshal(data, inv); err != nil {
+		return nil, fmt.Errorf("parse inventory: %w", err)
+	}
+
+	return inv, nil
+}
+
+// Save writes the inventory of the repo
+func (inv *Inventory) Save(repoRoot string) error {
+	data, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(Dir(repoRoot), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(Path(repoRoot), data, 0644)
+}
+
+// SetPier records the pier, replacing the one of the same name
+func (inv *Inventory) SetPier(pier *Host) {
+	for i, p := range inv.Piers {
+		if p.Name == pier.Name {
+			inv.Piers[i] = pier
+			return
+		}
+	}
+	inv.Piers = append(inv.Piers, pier)
+}
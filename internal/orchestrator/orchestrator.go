@@ -0,0 +1,361 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+)
+
+const (
+	// LabelProject marks every docker object goduck creates with the project ID of its repo
+	LabelProject = "goduck.project"
+	// LabelComponent is the component the object belongs to, e.g. bitxhub or pier
+	LabelComponent = "goduck.component"
+	// LabelName is the name goduck knows the container by, e.g. node1 or pier-ethereum
+	LabelName = "goduck.name"
+)
+
+// NetworkSpec describes a bridge network
+type NetworkSpec struct {
+	Name    string
+	Subnet  string
+	Gateway string
+}
+
+// ContainerSpec describes a container and the objects it depends on
+type ContainerSpec struct {
+	Name       string
+	Component  string
+	Image      string
+	Cmd        []string
+	Env        []string
+	WorkingDir string
+	// Binds are host paths mounted into the container, in the form of host:container
+	Binds []string
+	// Volumes are named volumes created for the container, keyed by volume name
+	Volumes map[string]string
+	// Ports are published ports, in the form of host:container
+	Ports   []string
+	Network string
+	IP      string
+	// RestartPolicy is the docker restart policy like always, containers are not restarted if it is empty
+	RestartPolicy string
+}
+
+// Orchestrator manages containers of a goduck project through Docker Engine API
+type Orchestrator struct {
+	cli     *client.Client
+	project string
+}
+
+// New connects to the docker daemon from environment and manages objects of the project
+func New(project string) (*Orchestrator, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, fmt.Errorf("create docker client: %w", err)
+	}
+	cli.NegotiateAPIVersion(context.Background())
+
+	return &Orchestrator{cli: cli, project: project}, nil
+}
+
+// Client returns the underlying docker client
+func (o *Orchestrator) Client() *client.Client {
+	return o.cli
+}
+
+// Available reports whether the docker daemon can be reached
+func (o *Orchestrator) Available(ctx context.Context) bool {
+	_, err := o.cli.Ping(ctx)
+	return err == nil
+}
+
+func (o *Orchestrator) labels(component, name string) map[string]string {
+	labels := map[string]string{
+		LabelProject:   o.project,
+		LabelComponent: component,
+	}
+	if name != "" {
+		labels[LabelName] = name
+	}
+
+	return labels
+}
+
+func (o *Orchestrator) filter(component, name string) filters.Args {
+	args := filters.NewArgs()
+	args.Add("label", fmt.Sprintf("%s=%s", LabelProject, o.project))
+	if component != "" {
+		args.Add("label", fmt.Sprintf("%s=%s", LabelComponent, component))
+	}
+	if name != "" {
+		args.Add("label", fmt.Sprintf("%s=%s", LabelName, name))
+	}
+
+	return args
+}
+
+// EnsureNetwork creates the network if it does not exist yet
+func (o *Orchestrator) EnsureNetwork(ctx context.Context, component string, spec *NetworkSpec) error {
+	args := filters.NewArgs()
+	args.Add("name", spec.Name)
+	networks, err := o.cli.NetworkList(ctx, types.NetworkListOptions{Filters: args})
+	if err != nil {
+		return fmt.Errorf("list networks: %w", err)
+	}
+	for _, n := range networks {
+		if n.Name == spec.Name {
+			return nil
+		}
+	}
+
+	options := types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Labels:         o.labels(component, spec.Name),
+	}
+	if spec.Subnet != "" {
+		options.IPAM = &network.IPAM{
+			Config: []network.IPAMConfig{{Subnet: spec.Subnet, Gateway: spec.Gateway}},
+		}
+	}
+
+	if _, err := o.cli.NetworkCreate(ctx, spec.Name, options); err != nil {
+		return fmt.Errorf("create network %s: %w", spec.Name, err)
+	}
+
+	return nil
+}
+
+// EnsureImage pulls the image if it is not present locally
+func (o *Orchestrator) EnsureImage(ctx context.Context, image string) error {
+	args := filters.NewArgs()
+	args.Add("reference", image)
+	images, err := o.cli.ImageList(ctx, types.ImageListOptions{Filters: args})
+	if err != nil {
+		return fmt.Errorf("list images: %w", err)
+	}
+	if len(images) != 0 {
+		return nil
+	}
+
+	fmt.Printf("pull image %s\n", image)
+	reader, err := o.cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("pull image %s: %w", image, err)
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(ioutil.Discard, reader); err != nil {
+		return fmt.Errorf("pull image %s: %w", image, err)
+	}
+
+	return nil
+}
+
+// Up creates and starts the container described by spec, returns the container ID
+func (o *Orchestrator) Up(ctx context.Context, spec *ContainerSpec) (string, error) {
+	if err := o.EnsureImage(ctx, spec.Image); err != nil {
+		return "", err
+	}
+
+	binds := append([]string{}, spec.Binds...)
+	for name, path := range spec.Volumes {
+		_, err := o.cli.VolumeCreate(ctx, volumetypes.VolumeCreateBody{
+			Name:   name,
+			Driver: "local",
+			Labels: o.labels(spec.Component, spec.Name),
+		})
+		if err != nil {
+			return "", fmt.Errorf("create volume %s: %w", name, err)
+		}
+		binds = append(binds, fmt.Sprintf("%s:%s", name, path))
+	}
+
+	exposed, bindings, err := nat.ParsePortSpecs(spec.Ports)
+	if err != nil {
+		return "", fmt.Errorf("parse ports of %s: %w", spec.Name, err)
+	}
+
+	config := &container.Config{
+		Image:        spec.Image,
+		Cmd:          spec.Cmd,
+		Env:          spec.Env,
+		WorkingDir:   spec.WorkingDir,
+		Tty:          true,
+		ExposedPorts: exposed,
+		Labels:       o.labels(spec.Component, spec.Name),
+	}
+
+	hostConfig := &container.HostConfig{
+		Binds:         binds,
+		PortBindings:  bindings,
+		RestartPolicy: container.RestartPolicy{Name: spec.RestartPolicy},
+	}
+
+	var networkingConfig *network.NetworkingConfig
+	if spec.Network != "" {
+		endpoint := &network.EndpointSettings{}
+		if spec.IP != "" {
+			endpoint.IPAMConfig = &network.EndpointIPAMConfig{IPv4Address: spec.IP}
+		}
+		hostConfig.NetworkMode = container.NetworkMode(spec.Network)
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{spec.Network: endpoint},
+		}
+	}
+
+	created, err := o.cli.ContainerCreate(ctx, config, hostConfig, networkingConfig, spec.Name)
+	if err != nil {
+		return "", fmt.Errorf("create container %s: %w", spec.Name, err)
+	}
+
+	if err := o.cli.ContainerStart(ctx, created.ID, types.ContainerStartOptions{}); err != nil {
+		return "", fmt.Errorf("start container %s: %w", spec.Name, err)
+	}
+
+	return created.ID, nil
+}
+
+// List returns all containers of the component in this project, including stopped ones
+func (o *Orchestrator) List(ctx context.Context, component string) ([]types.Container, error) {
+	return o.list(ctx, o.filter(component, ""))
+}
+
+func (o *Orchestrator) list(ctx context.Context, args filters.Args) ([]types.Container, error) {
+	containers, err := o.cli.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+
+	return containers, nil
+}
+
+// Stop stops running containers of the component in this project, all of them if name is empty
+func (o *Orchestrator) Stop(ctx context.Context, component, name string, timeout time.Duration) error {
+	containers, err := o.list(ctx, o.filter(component, name))
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		if err := o.cli.ContainerStop(ctx, c.ID, &timeout); err != nil {
+			return fmt.Errorf("stop container %s: %w", c.Labels[LabelName], err)
+		}
+		fmt.Printf("container %s(%s) stop\n", c.Labels[LabelName], c.ID[:12])
+	}
+
+	return nil
+}
+
+// Restart restarts the container of the component in this project
+func (o *Orchestrator) Restart(ctx context.Context, component, name string, timeout time.Duration) error {
+	containers, err := o.list(ctx, o.filter(component, name))
+	if err != nil {
+		return err
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("container %s does not exist", name)
+	}
+
+	for _, c := range containers {
+		if err := o.cli.ContainerRestart(ctx, c.ID, &timeout); err != nil {
+			return fmt.Errorf("restart container %s: %w", name, err)
+		}
+		fmt.Printf("container %s(%s) restart\n", name, c.ID[:12])
+	}
+
+	return nil
+}
+
+// Remove removes the container of the component in this project and the volumes created for it
+func (o *Orchestrator) Remove(ctx context.Context, component, name string) error {
+	return o.remove(ctx, o.filter(component, name))
+}
+
+// Down removes containers, volumes and networks of the component in this project
+func (o *Orchestrator) Down(ctx context.Context, component string) error {
+	args := o.filter(component, "")
+	if err := o.remove(ctx, args); err != nil {
+		return err
+	}
+
+	networks, err := o.cli.NetworkList(ctx, types.NetworkListOptions{Filters: args})
+	if err != nil {
+		return fmt.Errorf("list networks: %w", err)
+	}
+	for _, n := range networks {
+		if err := o.cli.NetworkRemove(ctx, n.ID); err != nil {
+			return fmt.Errorf("remove network %s: %w", n.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) remove(ctx context.Context, args filters.Args) error {
+	containers, err := o.list(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		if err := o.cli.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("remove container %s: %w", c.Labels[LabelName], err)
+		}
+		fmt.Printf("container %s(%s) removed\n", c.Labels[LabelName], c.ID[:12])
+	}
+
+	volumes, err := o.cli.VolumeList(ctx, args)
+	if err != nil {
+		return fmt.Errorf("list volumes: %w", err)
+	}
+	for _, v := range volumes.Volumes {
+		if err := o.cli.VolumeRemove(ctx, v.Name, true); err != nil {
+			return fmt.Errorf("remove volume %s: %w", v.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// SubnetIP returns the nth address of an IPv4 subnet, e.g. the 2nd address of 172.19.0.0/16 is 172.19.0.2
+func SubnetIP(subnet string, n int) (string, error) {
+	ip, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return "", fmt.Errorf("parse subnet %s: %w", subnet, err)
+	}
+	if ip.To4() == nil {
+		return "", fmt.Errorf("subnet %s is not an IPv4 subnet", subnet)
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	// the last address is reserved for broadcast
+	if n <= 0 || uint64(n) >= uint64(1)<<uint(bits-ones)-1 {
+		return "", fmt.Errorf("subnet %s does not have enough addresses for %d", subnet, n)
+	}
+
+	base := binary.BigEndian.Uint32(ipNet.IP.To4())
+	addr := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(addr, base+uint32(n))
+
+	return addr.String(), nil
+}
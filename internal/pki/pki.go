@@ -0,0 +1,707 @@
+// Package pki manages the certificate authority of BitXHub nodes and the index of the certs it issued
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/bitxhub/pkg/cert"
+	"github.com/meshplus/goduck/internal/hdkey"
+	"github.com/meshplus/goduck/internal/repo"
+)
+
+const (
+	// IndexName is the name of the index of issued certs in the CA directory
+	IndexName = "certs.json"
+
+	RoleCA     = "ca"
+	RoleAgency = "agency"
+	RoleNode   = "node"
+	RolePier   = "pier"
+
+	StatusValid   = "valid"
+	StatusRenewed = "renewed"
+	StatusRevoked = "revoked"
+
+	// DefaultValidity is the validity of certs if it is not specified
+	DefaultValidity = 50 * 365 * 24 * time.Hour
+)
+
+// Roles are the roles certs are issued for
+var Roles = []string{RoleAgency, RoleNode, RolePier}
+
+// Record is a cert issued by the authority
+type Record struct {
+	Serial string `json:"serial"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Org    string `json:"org"`
+	// Issuer is the name of the issuing cert, it is empty for the CA
+	Issuer string `json:"issuer,omitempty"`
+	// Cert and Key are relative to the CA directory if they are inside it
+	Cert      string     `json:"cert"`
+	Key       string     `json:"key"`
+	NotBefore time.Time  `json:"not_before"`
+	NotAfter  time.Time  `json:"not_after"`
+	Status    string     `json:"status"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// Expired reports whether the cert is expired at t
+func (r *Record) Expired(t time.Time) bool {
+	return t.After(r.NotAfter)
+}
+
+// Request describes a cert to issue
+type Request struct {
+	Name string
+	Role string
+	Org  string
+	// Issuer is the name of the issuing agency of node and pier certs, the default agency if it is empty
+	Issuer string
+	// Dir is where the key and cert are written, the default directory of the role if it is empty
+	Dir      string
+	Validity time.Duration
+}
+
+// Authority is the CA in a directory together with its index
+type Authority struct {
+	dir string
+	// keys derives the keys of issued certs by role and name, they are random if it is nil
+	keys    *hdkey.Deriver
+	Records []*Record `json:"certs"`
+}
+
+// Exist reports whether the directory has a CA
+func Exist(dir string) bool {
+	return fileutil.Exist(repo.GetCACertPath(dir)) && fileutil.Exist(repo.GetCAPrivKeyPath(dir))
+}
+
+// Init creates the CA of org and the default agency in dir, keys derives the keys of the CA and
+// the certs it issues if it is not nil
+func Init(dir, org string, validity time.Duration, keys *hdkey.Deriver) (*Authority, error) {
+	if Exist(dir) {
+		return nil, fmt.Errorf("CA already exists in %s", dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if validity == 0 {
+		validity = DefaultValidity
+	}
+
+	priv, err := writeKey(repo.GetCAPrivKeyPath(dir), keys, RoleCA, RoleCA)
+	if err != nil {
+		return nil, fmt.Errorf("generate CA key: %w", err)
+	}
+	template, err := cert.GenerateCert(priv, true, org)
+	if err != nil {
+		return nil, err
+	}
+	if err := setValidity(template, time.Now(), validity); err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+	if err != nil {
+		return nil, fmt.Errorf("create CA cert: %w", err)
+	}
+	if err := writeCert(repo.GetCACertPath(dir), der); err != nil {
+		return nil, err
+	}
+
+	a := &Authority{dir: dir, keys: keys}
+	a.Records = append(a.Records, &Record{
+		Serial:    serialString(template.SerialNumber),
+		Name:      RoleCA,
+		Role:      RoleCA,
+		Org:       org,
+		Cert:      a.rel(repo.GetCACertPath(dir)),
+		Key:       a.rel(repo.GetCAPrivKeyPath(dir)),
+		NotBefore: template.NotBefore,
+		NotAfter:  template.NotAfter,
+		Status:    StatusValid,
+	})
+
+	if _, err := a.Issue(&Request{Name: repo.AgencyName, Role: RoleAgency, Org: strings.ToUpper(repo.AgencyName), Validity: validity}); err != nil {
+		return nil, fmt.Errorf("issue agency cert: %w", err)
+	}
+
+	return a, nil
+}
+
+// Open loads the authority in dir, a CA without index is adopted with the certs found next to it
+func Open(dir string) (*Authority, error) {
+	if !Exist(dir) {
+		return nil, fmt.Errorf("no CA in %s, run `goduck cert ca init` first", dir)
+	}
+
+	a := &Authority{dir: dir}
+	data, err := ioutil.ReadFile(filepath.Join(dir, IndexName))
+	if os.IsNotExist(err) {
+		if err := a.adopt(); err != nil {
+			return nil, fmt.Errorf("adopt existing certs: %w", err)
+		}
+		return a, a.Save()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", IndexName, err)
+	}
+
+	return a, nil
+}
+
+// Dir returns the CA directory
+func (a *Authority) Dir() string {
+	return a.dir
+}
+
+// Save writes the index
+func (a *Authority) Save() error {
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(a.dir, IndexName), data, 0644)
+}
+
+// Path returns the path of a cert or key of the index
+func (a *Authority) Path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(a.dir, p)
+}
+
+// Find returns the record of serial or of the valid cert named ref
+func (a *Authority) Find(ref string) (*Record, error) {
+	var found *Record
+	for _, r := range a.Records {
+		if strings.EqualFold(r.Serial, ref) {
+			return r, nil
+		}
+		if r.Name == ref && r.Status == StatusValid {
+			found = r
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no valid cert named %s or with serial %s", ref, ref)
+	}
+
+	return found, nil
+}
+
+// Certificate loads the cert of the record
+func (a *Authority) Certificate(r *Record) (*x509.Certificate, error) {
+	return ReadCert(a.Path(r.Cert))
+}
+
+// Issue generates a key and issues a cert for it, node and pier certs are written with the CA and agency certs
+func (a *Authority) Issue(req *Request) (*Record, error) {
+	if err := a.checkRequest(req); err != nil {
+		return nil, err
+	}
+	issuer, err := a.issuerOf(req.Role, req.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, file := req.Dir, req.Name
+	switch req.Role {
+	case RoleAgency:
+		if dir == "" {
+			dir = a.dir
+		}
+	default:
+		if dir == "" {
+			dir = filepath.Join(a.dir, req.Name, "certs")
+		}
+		file = req.Role
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	keyPath, certPath := repo.GetPrivKeyPath(file, dir), repo.GetCertPath(file, dir)
+	for _, p := range []string{keyPath, certPath} {
+		if fileutil.Exist(p) {
+			return nil, fmt.Errorf("%s already exists", p)
+		}
+	}
+
+	priv, err := writeKey(keyPath, a.keys, req.Role, req.Name)
+	if err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	r := &Record{
+		Name:   req.Name,
+		Role:   req.Role,
+		Org:    req.Org,
+		Issuer: issuer.Name,
+		Cert:   a.rel(certPath),
+		Key:    a.rel(keyPath),
+	}
+	if err := a.sign(r, issuer, &priv.PublicKey, subject(req.Org), req.Validity); err != nil {
+		return nil, err
+	}
+
+	if req.Role != RoleAgency {
+		if err := a.copyChain(issuer, dir); err != nil {
+			return nil, err
+		}
+	}
+
+	a.Records = append(a.Records, r)
+
+	return r, a.Save()
+}
+
+// Renew issues a new cert with the key and subject of the cert ref, which is marked as renewed
+func (a *Authority) Renew(ref string, validity time.Duration) (*Record, error) {
+	old, err := a.Find(ref)
+	if err != nil {
+		return nil, err
+	}
+	if old.Status != StatusValid {
+		return nil, fmt.Errorf("cert %s is %s", old.Serial, old.Status)
+	}
+	oldCert, err := a.Certificate(old)
+	if err != nil {
+		return nil, err
+	}
+	if validity == 0 {
+		validity = old.NotAfter.Sub(old.NotBefore)
+	}
+
+	r := *old
+	r.RevokedAt, r.Reason = nil, ""
+	if old.Role == RoleCA {
+		priv, err := readKey(a.Path(old.Key))
+		if err != nil {
+			return nil, err
+		}
+		template, err := cert.GenerateCert(priv, true, old.Org)
+		if err != nil {
+			return nil, err
+		}
+		template.Subject = oldCert.Subject
+		if err := setValidity(template, time.Now(), validity); err != nil {
+			return nil, err
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, priv.Public(), priv)
+		if err != nil {
+			return nil, fmt.Errorf("create CA cert: %w", err)
+		}
+		if err := writeCert(a.Path(r.Cert), der); err != nil {
+			return nil, err
+		}
+		r.Serial, r.NotBefore, r.NotAfter = serialString(template.SerialNumber), template.NotBefore, template.NotAfter
+	} else {
+		issuer, err := a.Find(old.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("issuer of %s: %w", old.Name, err)
+		}
+		if err := a.sign(&r, issuer, oldCert.PublicKey, oldCert.Subject, validity); err != nil {
+			return nil, err
+		}
+	}
+
+	old.Status = StatusRenewed
+	a.Records = append(a.Records, &r)
+
+	return &r, a.Save()
+}
+
+// Revoke revokes the cert ref and rewrites the CRL of its issuer
+func (a *Authority) Revoke(ref, reason string) (*Record, error) {
+	r, err := a.Find(ref)
+	if err != nil {
+		return nil, err
+	}
+	if r.Role == RoleCA {
+		return nil, fmt.Errorf("the CA cert can not be revoked")
+	}
+	if r.Status == StatusRevoked {
+		return nil, fmt.Errorf("cert %s is already revoked", r.Serial)
+	}
+
+	now := time.Now().UTC()
+	r.Status, r.RevokedAt, r.Reason = StatusRevoked, &now, reason
+	if err := a.Save(); err != nil {
+		return nil, err
+	}
+
+	issuer, err := a.Find(r.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("issuer of %s: %w", r.Name, err)
+	}
+
+	return r, a.WriteCRL(issuer)
+}
+
+// CRLPath returns the path of the CRL of the issuer
+func (a *Authority) CRLPath(issuer *Record) string {
+	return filepath.Join(a.dir, issuer.Name+".crl")
+}
+
+// WriteCRL writes the CRL of the certs revoked by the issuer
+func (a *Authority) WriteCRL(issuer *Record) error {
+	issuerCert, err := a.Certificate(issuer)
+	if err != nil {
+		return err
+	}
+	priv, err := readKey(a.Path(issuer.Key))
+	if err != nil {
+		return err
+	}
+
+	var revoked []pkix.RevokedCertificate
+	for _, r := range a.Records {
+		if r.Issuer != issuer.Name || r.Status != StatusRevoked {
+			continue
+		}
+		sn, ok := new(big.Int).SetString(r.Serial, 16)
+		if !ok {
+			return fmt.Errorf("invalid serial %s", r.Serial)
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: sn, RevocationTime: *r.RevokedAt})
+	}
+
+	now := time.Now().UTC()
+	der, err := issuerCert.CreateCRL(rand.Reader, priv, revoked, now, now.Add(365*24*time.Hour))
+	if err != nil {
+		return fmt.Errorf("create CRL: %w", err)
+	}
+
+	return writePEM(a.CRLPath(issuer), "X509 CRL", der, 0644)
+}
+
+// Verify checks the cert is signed by the chain of the authority and not revoked
+func (a *Authority) Verify(c *x509.Certificate) error {
+	for _, r := range a.Records {
+		if r.Serial == serialString(c.SerialNumber) && r.Status == StatusRevoked {
+			return fmt.Errorf("cert %s is revoked", r.Serial)
+		}
+	}
+
+	roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+	for _, r := range a.Records {
+		if r.Status != StatusValid || (r.Role != RoleCA && r.Role != RoleAgency) {
+			continue
+		}
+		ic, err := a.Certificate(r)
+		if err != nil {
+			return err
+		}
+		if r.Role == RoleCA {
+			roots.AddCert(ic)
+		} else {
+			intermediates.AddCert(ic)
+		}
+	}
+	_, err := c.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+
+	return err
+}
+
+func (a *Authority) checkRequest(req *Request) error {
+	if req.Name == "" {
+		return fmt.Errorf("empty cert name")
+	}
+	if req.Name == RoleCA || strings.ContainsAny(req.Name, `/\`) {
+		return fmt.Errorf("invalid cert name %q", req.Name)
+	}
+	valid := false
+	for _, role := range Roles {
+		valid = valid || role == req.Role
+	}
+	if !valid {
+		return fmt.Errorf("unknown role %q, choose one of %v", req.Role, Roles)
+	}
+	for _, r := range a.Records {
+		if r.Name == req.Name && r.Status == StatusValid {
+			return fmt.Errorf("cert %s already exists with serial %s", req.Name, r.Serial)
+		}
+	}
+	if req.Org == "" {
+		req.Org = strings.ToUpper(req.Name[:1]) + req.Name[1:]
+	}
+	if req.Validity == 0 {
+		req.Validity = DefaultValidity
+	}
+
+	return nil
+}
+
+// issuerOf returns the record issuing certs of role, agencies are issued by the CA, nodes and piers by agencies
+func (a *Authority) issuerOf(role, name string) (*Record, error) {
+	if role == RoleAgency {
+		return a.Find(RoleCA)
+	}
+	if name == "" {
+		name = repo.AgencyName
+	}
+	issuer, err := a.Find(name)
+	if err != nil {
+		return nil, err
+	}
+	if issuer.Role != RoleAgency {
+		return nil, fmt.Errorf("%s is not an agency", name)
+	}
+
+	return issuer, nil
+}
+
+// sign issues the cert of the record for the public key, and sets its serial and validity
+func (a *Authority) sign(r *Record, issuer *Record, pub interface{}, sub pkix.Name, validity time.Duration) error {
+	issuerCert, err := a.Certificate(issuer)
+	if err != nil {
+		return err
+	}
+	issuerKey, err := readKey(a.Path(issuer.Key))
+	if err != nil {
+		return err
+	}
+
+	template, err := issueCert(a.Path(r.Cert), r.Role == RoleAgency, pub, sub, validity, issuerCert, issuerKey)
+	if err != nil {
+		return err
+	}
+	r.Serial, r.NotBefore, r.NotAfter, r.Status = serialString(template.SerialNumber), template.NotBefore, template.NotAfter, StatusValid
+
+	return nil
+}
+
+// issueCert writes the cert of the public key signed by the issuer to path
+func issueCert(path string, isCA bool, pub interface{}, sub pkix.Name, validity time.Duration, issuerCert *x509.Certificate, issuerKey *ecdsa.PrivateKey) (*x509.Certificate, error) {
+	template := &x509.Certificate{
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		Issuer:                issuerCert.Subject,
+		KeyUsage: x509.KeyUsageDigitalSignature |
+			x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign |
+			x509.KeyUsageCRLSign,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		Subject:     sub,
+	}
+	if err := setValidity(template, time.Now(), validity); err != nil {
+		return nil, err
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuerCert, pub, issuerKey)
+	if err != nil {
+		return nil, fmt.Errorf("create cert: %w", err)
+	}
+
+	return template, writeCert(path, der)
+}
+
+// copyChain copies the CA cert and the issuing agency cert, which nodes read as agency.cert, into dir
+func (a *Authority) copyChain(issuer *Record, dir string) error {
+	for src, dst := range map[string]string{
+		repo.GetCACertPath(a.dir): repo.GetCACertPath(dir),
+		a.Path(issuer.Cert):       repo.GetCertPath(repo.AgencyName, dir),
+	} {
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(dst, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// adopt indexes the CA, the agencies next to it and the node certs of the nodes in dir
+func (a *Authority) adopt() error {
+	caCert, err := ReadCert(repo.GetCACertPath(a.dir))
+	if err != nil {
+		return err
+	}
+	ca := a.adoptCert(RoleCA, RoleCA, "", repo.GetCACertPath(a.dir), repo.GetCAPrivKeyPath(a.dir), caCert)
+
+	agencies := map[string]*x509.Certificate{}
+	certs, err := filepath.Glob(filepath.Join(a.dir, "*.cert"))
+	if err != nil {
+		return err
+	}
+	for _, p := range certs {
+		name := strings.TrimSuffix(filepath.Base(p), ".cert")
+		c, err := ReadCert(p)
+		if err != nil || name == RoleCA || !c.IsCA || c.CheckSignatureFrom(caCert) != nil {
+			continue
+		}
+		a.adoptCert(name, RoleAgency, ca.Name, p, repo.GetPrivKeyPath(name, a.dir), c)
+		agencies[name] = c
+	}
+
+	nodeCerts, err := filepath.Glob(repo.GetCertPath(RoleNode, filepath.Join(a.dir, "*", "certs")))
+	if err != nil {
+		return err
+	}
+	for _, p := range nodeCerts {
+		c, err := ReadCert(p)
+		if err != nil {
+			continue
+		}
+		for name, agency := range agencies {
+			if c.CheckSignatureFrom(agency) == nil {
+				a.adoptCert(filepath.Base(filepath.Dir(filepath.Dir(p))), RoleNode, name, p, repo.GetPrivKeyPath(RoleNode, filepath.Dir(p)), c)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *Authority) adoptCert(name, role, issuer, certPath, keyPath string, c *x509.Certificate) *Record {
+	org := ""
+	if len(c.Subject.Organization) != 0 {
+		org = c.Subject.Organization[0]
+	}
+	r := &Record{
+		Serial:    serialString(c.SerialNumber),
+		Name:      name,
+		Role:      role,
+		Org:       org,
+		Issuer:    issuer,
+		Cert:      a.rel(certPath),
+		Key:       a.rel(keyPath),
+		NotBefore: c.NotBefore,
+		NotAfter:  c.NotAfter,
+		Status:    StatusValid,
+	}
+	a.Records = append(a.Records, r)
+
+	return r
+}
+
+// rel returns the path relative to the CA directory if it is inside it
+func (a *Authority) rel(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return p
+	}
+	dir, err := filepath.Abs(a.dir)
+	if err != nil {
+		return abs
+	}
+	if rel, err := filepath.Rel(dir, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+
+	return abs
+}
+
+// subject returns the subject of certs issued for org
+func subject(org string) pkix.Name {
+	return pkix.Name{
+		Country:            []string{"CN"},
+		Locality:           []string{"HangZhou"},
+		Province:           []string{"ZheJiang"},
+		OrganizationalUnit: []string{"BitXHub"},
+		Organization:       []string{org},
+		StreetAddress:      []string{"street", "address"},
+		PostalCode:         []string{"324000"},
+		CommonName:         "BitXHub",
+	}
+}
+
+func setValidity(template *x509.Certificate, now time.Time, validity time.Duration) error {
+	sn, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+	template.SerialNumber = sn
+	template.NotBefore = now.Add(-5 * time.Minute).UTC()
+	template.NotAfter = template.NotBefore.Add(validity).UTC()
+
+	return nil
+}
+
+func serialString(sn *big.Int) string {
+	return fmt.Sprintf("%x", sn)
+}
+
+// writeKey writes the P-256 key of the cert of role and name in the format of node keys,
+// it is derived by keys or random if keys is nil
+func writeKey(path string, keys *hdkey.Deriver, role, name string) (*ecdsa.PrivateKey, error) {
+	priv, err := keys.ECDSA(elliptic.P256(), role, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return priv, WriteKey(path, priv)
+}
+
+// WriteKey writes the key in the format of node keys
+func WriteKey(path string, priv *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return err
+	}
+
+	return writePEM(path, "EC PRIVATE KEY", der, 0600)
+}
+
+func readKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	priv, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", path, err)
+	}
+
+	return priv, nil
+}
+
+// ReadCert loads a PEM encoded cert
+func ReadCert(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cert: %w", err)
+	}
+	c, err := cert.ParseCert(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse cert %s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+func writeCert(path string, der []byte) error {
+	return writePEM(path, "CERTIFICATE", der, 0644)
+}
+
+func writePEM(path, typ string, der []byte, perm os.FileMode) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), perm)
+}
@@ -0,0 +1,248 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	"github.com/meshplus/goduck/internal/hdkey"
+	"github.com/meshplus/goduck/internal/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func newAuthority(t *testing.T) *Authority {
+	dir, err := ioutil.TempDir("", "pki")
+	require.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	a, err := Init(dir, "Hyperchain", 0, nil)
+	require.Nil(t, err)
+
+	return a
+}
+
+func TestIssue(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    *Request
+		file   string
+		org    string
+		issuer string
+		chain  bool
+	}{
+		{"node", &Request{Name: "node1", Role: RoleNode}, "node", "Node1", repo.AgencyName, true},
+		{"pier", &Request{Name: "pier1", Role: RolePier, Org: "Pier"}, "pier", "Pier", repo.AgencyName, true},
+		{"agency", &Request{Name: "agency2", Role: RoleAgency}, "agency2", "Agency2", RoleCA, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthority(t)
+			r, err := a.Issue(tt.req)
+			require.Nil(t, err)
+			require.Equal(t, tt.issuer, r.Issuer)
+			require.Equal(t, tt.org, r.Org)
+			require.Equal(t, StatusValid, r.Status)
+
+			c, err := a.Certificate(r)
+			require.Nil(t, err)
+			require.Equal(t, r.Serial, serialString(c.SerialNumber))
+			require.Equal(t, []string{tt.org}, c.Subject.Organization)
+			require.Nil(t, a.Verify(c))
+
+			priv, err := readKey(a.Path(r.Key))
+			require.Nil(t, err)
+			require.True(t, samePublicKey(&priv.PublicKey, c.PublicKey))
+			require.Equal(t, repo.GetCertPath(tt.file, filepath.Dir(a.Path(r.Cert))), a.Path(r.Cert))
+
+			// nodes and piers read the chain next to their certs
+			if tt.chain {
+				certDir := filepath.Dir(a.Path(r.Cert))
+				require.True(t, fileutil.Exist(repo.GetCACertPath(certDir)))
+				require.True(t, fileutil.Exist(repo.GetCertPath(repo.AgencyName, certDir)))
+			}
+
+			// the index is reloaded with the cert
+			reopened, err := Open(a.Dir())
+			require.Nil(t, err)
+			found, err := reopened.Find(tt.req.Name)
+			require.Nil(t, err)
+			require.Equal(t, r.Serial, found.Serial)
+		})
+	}
+}
+
+func TestIssueErrors(t *testing.T) {
+	a := newAuthority(t)
+	node, err := a.Issue(&Request{Name: "node1", Role: RoleNode})
+	require.Nil(t, err)
+
+	tests := []struct {
+		name string
+		req  *Request
+	}{
+		{"empty name", &Request{Role: RoleNode}},
+		{"name of the CA", &Request{Name: RoleCA, Role: RoleAgency}},
+		{"path in name", &Request{Name: "../node2", Role: RoleNode}},
+		{"unknown role", &Request{Name: "node2", Role: "admin"}},
+		{"name of a valid cert", &Request{Name: "node1", Role: RoleNode, Dir: filepath.Join(a.Dir(), "other")}},
+		{"issuer is not an agency", &Request{Name: "node2", Role: RoleNode, Issuer: "node1"}},
+		{"unknown issuer", &Request{Name: "node2", Role: RoleNode, Issuer: "agency9"}},
+		{"files exist", &Request{Name: "node2", Role: RoleNode, Dir: filepath.Dir(a.Path(node.Cert))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := a.Issue(tt.req)
+			require.NotNil(t, err)
+		})
+	}
+}
+
+func TestRenew(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  string
+		req  *Request
+	}{
+		{"node", "node1", &Request{Name: "node1", Role: RoleNode}},
+		{"agency", repo.AgencyName, nil},
+		{"CA", RoleCA, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthority(t)
+			if tt.req != nil {
+				_, err := a.Issue(tt.req)
+				require.Nil(t, err)
+			}
+			old, err := a.Find(tt.ref)
+			require.Nil(t, err)
+			oldCert, err := a.Certificate(old)
+			require.Nil(t, err)
+
+			r, err := a.Renew(tt.ref, 0)
+			require.Nil(t, err)
+			require.NotEqual(t, old.Serial, r.Serial)
+			require.Equal(t, StatusRenewed, old.Status)
+			require.Equal(t, StatusValid, r.Status)
+			require.Equal(t, old.NotAfter.Sub(old.NotBefore), r.NotAfter.Sub(r.NotBefore))
+
+			c, err := a.Certificate(r)
+			require.Nil(t, err)
+			require.Equal(t, oldCert.Subject.String(), c.Subject.String())
+			require.True(t, samePublicKey(oldCert.PublicKey, c.PublicKey))
+			require.Nil(t, a.Verify(c))
+
+			found, err := a.Find(tt.ref)
+			require.Nil(t, err)
+			require.Equal(t, r.Serial, found.Serial)
+			_, err = a.Renew(old.Serial, 0)
+			require.NotNil(t, err)
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	a := newAuthority(t)
+	r, err := a.Issue(&Request{Name: "node1", Role: RoleNode})
+	require.Nil(t, err)
+	c, err := a.Certificate(r)
+	require.Nil(t, err)
+
+	_, err = a.Revoke("node1", "key compromise")
+	require.Nil(t, err)
+	require.Equal(t, StatusRevoked, r.Status)
+	require.Equal(t, "key compromise", r.Reason)
+	require.NotNil(t, a.Verify(c))
+
+	agency, err := a.Find(repo.AgencyName)
+	require.Nil(t, err)
+	data, err := ioutil.ReadFile(a.CRLPath(agency))
+	require.Nil(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	crl, err := x509.ParseCRL(block.Bytes)
+	require.Nil(t, err)
+	require.Len(t, crl.TBSCertList.RevokedCertificates, 1)
+	require.Equal(t, 0, crl.TBSCertList.RevokedCertificates[0].SerialNumber.Cmp(c.SerialNumber))
+	agencyCert, err := a.Certificate(agency)
+	require.Nil(t, err)
+	require.Nil(t, agencyCert.CheckCRLSignature(crl))
+
+	_, err = a.Revoke(r.Serial, "")
+	require.NotNil(t, err)
+	_, err = a.Revoke(RoleCA, "")
+	require.NotNil(t, err)
+
+	// the name is free again once its cert is revoked
+	_, err = a.Issue(&Request{Name: "node1", Role: RoleNode, Dir: filepath.Join(a.Dir(), "node1", "new")})
+	require.Nil(t, err)
+}
+
+func TestOpenAdoptsExistingCA(t *testing.T) {
+	a := newAuthority(t)
+	node, err := a.Issue(&Request{Name: "node1", Role: RoleNode})
+	require.Nil(t, err)
+	_, err = a.Issue(&Request{Name: "agency2", Role: RoleAgency})
+	require.Nil(t, err)
+	require.Nil(t, os.Remove(filepath.Join(a.Dir(), IndexName)))
+
+	adopted, err := Open(a.Dir())
+	require.Nil(t, err)
+	require.True(t, fileutil.Exist(filepath.Join(a.Dir(), IndexName)))
+
+	roles := make(map[string]*Record)
+	for _, r := range adopted.Records {
+		roles[r.Name] = r
+	}
+	require.Len(t, roles, 4)
+	require.Equal(t, RoleCA, roles[RoleCA].Role)
+	require.Equal(t, RoleAgency, roles[repo.AgencyName].Role)
+	require.Equal(t, RoleCA, roles["agency2"].Issuer)
+	require.Equal(t, RoleNode, roles["node1"].Role)
+	require.Equal(t, repo.AgencyName, roles["node1"].Issuer)
+	require.Equal(t, node.Serial, roles["node1"].Serial)
+	require.Equal(t, node.Key, roles["node1"].Key)
+
+	// adopted certs are managed like issued ones
+	_, err = adopted.Revoke("node1", "")
+	require.Nil(t, err)
+}
+
+func TestInitWithKeys(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 2; i++ {
+		dir, err := ioutil.TempDir("", "pki")
+		require.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		a, err := Init(dir, "Hyperchain", 0, hdkey.New("ci-fixture"))
+		require.Nil(t, err)
+		_, err = Init(dir, "Hyperchain", 0, nil)
+		require.NotNil(t, err)
+		r, err := a.Issue(&Request{Name: "node1", Role: RoleNode})
+		require.Nil(t, err)
+		priv, err := readKey(a.Path(r.Key))
+		require.Nil(t, err)
+		keys = append(keys, priv)
+	}
+
+	require.Equal(t, keys[0].D, keys[1].D)
+}
+
+func samePublicKey(a, b interface{}) bool {
+	pa, ok := a.(*ecdsa.PublicKey)
+	if !ok {
+		return false
+	}
+	pb, ok := b.(*ecdsa.PublicKey)
+
+	return ok && pa.X.Cmp(pb.X) == 0 && pa.Y.Cmp(pb.Y) == 0
+}
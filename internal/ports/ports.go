@@ -0,0 +1,266 @@
+package ports
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/meshplus/goduck/internal/types"
+)
+
+// the ports of the first node, later nodes take the next free ones
+const (
+	BaseJsonRpc = 8881
+	BaseGrpc    = 60011
+	BaseGateway = 9091
+	BaseP2P     = 4001
+	BasePprof   = 53121
+	BaseMonitor = 40011
+
+	maxPort = 65535
+)
+
+// NodePorts is the ports assigned to a BitXHub node
+type NodePorts struct {
+	// Repo is the absolute repo path of the node
+	Repo    string `json:"repo"`
+	Host    string `json:"host"`
+	JsonRpc int    `json:"jsonrpc"`
+	Grpc    int    `json:"grpc"`
+	Gateway int    `json:"gateway"`
+	P2P     int    `json:"p2p"`
+	Pprof   int    `json:"pprof"`
+	Monitor int    `json:"monitor"`
+}
+
+func (p *NodePorts) all() []int {
+	return []int{p.JsonRpc, p.Grpc, p.Gateway, p.P2P, p.Pprof, p.Monitor}
+}
+
+// Manifest records the ports assigned to nodes of a goduck repo
+type Manifest struct {
+	Nodes []*NodePorts `json:"nodes"`
+}
+
+// ManifestPath returns the path of the port manifest of the goduck repo
+func ManifestPath(repoRoot string) string {
+	return filepath.Join(repoRoot, types.PortsManifest)
+}
+
+// Load reads the manifest at path, an empty manifest is returned if it does not exist
+func Load(path string) (*Manifest, error) {
+	m := &Manifest{}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read port manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("unmarshal port manifest: %w", err)
+	}
+
+	return m, nil
+}
+
+// Save writes the manifest to path
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Lookup returns the ports of the node whose repo is nodeRepo
+func (m *Manifest) Lookup(nodeRepo string) *NodePorts {
+	nodeRepo = absPath(nodeRepo)
+	for _, n := range m.Nodes {
+		if n.Repo == nodeRepo {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// Release drops the ports of nodes whose repo is dir or under it
+func (m *Manifest) Release(dir string) {
+	dir = absPath(dir)
+	nodes := m.Nodes[:0]
+	for _, n := range m.Nodes {
+		if n.Repo == dir || strings.HasPrefix(n.Repo, dir+string(filepath.Separator)) {
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	m.Nodes = nodes
+}
+
+// Allocator assigns ports to nodes, skipping ports which are recorded in the manifest
+// or already taken on this machine
+type Allocator struct {
+	manifest *Manifest
+	used     map[string]map[int]bool
+}
+
+// NewAllocator creates an allocator which takes the ports recorded in m as used
+func NewAllocator(m *Manifest) *Allocator {
+	a := &Allocator{
+		manifest: m,
+		used:     make(map[string]map[int]bool),
+	}
+	for _, n := range m.Nodes {
+		a.use(n.Host, n.all()...)
+	}
+
+	return a
+}
+
+func (a *Allocator) use(host string, ports ...int) {
+	host = hostKey(host)
+	if a.used[host] == nil {
+		a.used[host] = make(map[int]bool)
+	}
+	for _, p := range ports {
+		a.used[host][p] = true
+	}
+}
+
+// isUsed reports whether the port on host is assigned to a node
+func (a *Allocator) isUsed(host string, port int) bool {
+	return a.used[hostKey(host)][port]
+}
+
+// hostKey returns the key ports of host are recorded by, all local addresses share the ports of this machine,
+// so docker nodes recorded without a host and binary nodes on 127.0.0.1 do not take the same ports
+func hostKey(host string) string {
+	if IsLocal(host) {
+		return ""
+	}
+
+	return host
+}
+
+// Allocate assigns ports to the node in nodeRepo on host and records them in the manifest.
+// Ports on host are checked to be free on this machine if check is true, which makes sense
+// only when host is a local address.
+func (a *Allocator) Allocate(nodeRepo, host string, check bool) (*NodePorts, error) {
+	nodeRepo = absPath(nodeRepo)
+	a.manifest.Release(nodeRepo)
+
+	next := func(base int) (int, error) {
+		for p := base; p <= maxPort; p++ {
+			if a.isUsed(host, p) {
+				continue
+			}
+			if check && !IsFree(host, p) {
+				continue
+			}
+			a.use(host, p)
+			return p, nil
+		}
+		return 0, fmt.Errorf("no free port from %d on %s", base, host)
+	}
+
+	n := &NodePorts{Repo: nodeRepo, Host: host}
+	for _, item := range []struct {
+		base int
+		dst  *int
+	}{
+		{BaseJsonRpc, &n.JsonRpc},
+		{BaseGrpc, &n.Grpc},
+		{BaseGateway, &n.Gateway},
+		{BaseP2P, &n.P2P},
+		{BasePprof, &n.Pprof},
+		{BaseMonitor, &n.Monitor},
+	} {
+		p, err := next(item.base)
+		if err != nil {
+			return nil, err
+		}
+		*item.dst = p
+	}
+
+	a.manifest.Nodes = append(a.manifest.Nodes, n)
+	return n, nil
+}
+
+// Reserve records the ports a node is already configured with in the manifest. It fails if one of them is
+// assigned to another node on the host or, if check is true, already taken on this machine.
+func (a *Allocator) Reserve(p *NodePorts, check bool) error {
+	p.Repo = absPath(p.Repo)
+	a.manifest.Release(p.Repo)
+
+	for _, port := range p.all() {
+		if port == 0 {
+			continue
+		}
+		if a.isUsed(p.Host, port) {
+			return fmt.Errorf("port %d on %s is assigned to another node", port, p.Host)
+		}
+		if check && !IsFree(p.Host, port) {
+			return fmt.Errorf("port %d on %s is already taken", port, p.Host)
+		}
+	}
+
+	a.use(p.Host, p.all()...)
+	a.manifest.Nodes = append(a.manifest.Nodes, p)
+	return nil
+}
+
+// IsFree reports whether the tcp port can be listened on host
+func IsFree(host string, port int) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return false
+	}
+	l.Close()
+
+	return true
+}
+
+// WaitListening waits until the tcp port accepts connections on host
+func WaitListening(host string, port int, timeout time.Duration) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s is not listening after %s", addr, timeout)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// IsLocal reports whether ip is an address ports can be checked on, i.e. a loopback or unspecified one
+func IsLocal(ip string) bool {
+	if ip == "" || ip == "localhost" {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+
+	return parsed != nil && (parsed.IsLoopback() || parsed.IsUnspecified())
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+
+	return abs
+}
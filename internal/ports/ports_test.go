@@ -0,0 +1,96 @@
+package ports
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateManyNodesOnOneHost(t *testing.T) {
+	// the port the sixth node would take is held by another process
+	taken := BaseGateway + 5
+	l, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(taken)))
+	if err != nil {
+		t.Skipf("port %d is not available for the test: %v", taken, err)
+	}
+	defer l.Close()
+
+	m := &Manifest{}
+	a := NewAllocator(m)
+	used := make(map[int]string)
+	for i := 1; i <= 12; i++ {
+		nodeRepo := fmt.Sprintf("/tmp/goduck/node%d", i)
+		p, err := a.Allocate(nodeRepo, "127.0.0.1", true)
+		require.Nil(t, err)
+		for _, port := range p.all() {
+			require.NotEqual(t, taken, port)
+			require.Empty(t, used[port], "port %d is assigned to %s and %s", port, used[port], nodeRepo)
+			used[port] = nodeRepo
+		}
+	}
+
+	require.Len(t, m.Nodes, 12)
+	require.Equal(t, BaseJsonRpc+11, m.Lookup("/tmp/goduck/node12").JsonRpc)
+	require.Equal(t, BaseGateway+12, m.Lookup("/tmp/goduck/node12").Gateway)
+	require.Equal(t, BaseGateway+4, m.Lookup("/tmp/goduck/node5").Gateway)
+	require.Equal(t, BaseGateway+6, m.Lookup("/tmp/goduck/node6").Gateway)
+}
+
+func TestAllocateSkipsManifestPorts(t *testing.T) {
+	m := &Manifest{}
+	a := NewAllocator(m)
+	_, err := a.Allocate("/tmp/goduck/node1", "10.0.0.1", false)
+	require.Nil(t, err)
+
+	// the manifest is reloaded by later commands, its ports stay taken
+	a = NewAllocator(m)
+	p, err := a.Allocate("/tmp/goduck/node2", "10.0.0.1", false)
+	require.Nil(t, err)
+	require.Equal(t, BaseGrpc+1, p.Grpc)
+
+	// ports on other hosts do not collide
+	p, err = a.Allocate("/tmp/goduck/node3", "10.0.0.2", false)
+	require.Nil(t, err)
+	require.Equal(t, BaseGrpc, p.Grpc)
+}
+
+func TestReserve(t *testing.T) {
+	m := &Manifest{}
+	a := NewAllocator(m)
+	p := &NodePorts{Repo: "/tmp/goduck/node1", Host: "10.0.0.1", Grpc: 60011, Gateway: 9091, P2P: 4001}
+	require.Nil(t, a.Reserve(p, false))
+	require.Equal(t, p, m.Lookup("/tmp/goduck/node1"))
+
+	err := a.Reserve(&NodePorts{Repo: "/tmp/goduck/node2", Host: "10.0.0.1", Grpc: 60012, Gateway: 9091}, false)
+	require.NotNil(t, err)
+	require.Nil(t, a.Reserve(&NodePorts{Repo: "/tmp/goduck/node3", Host: "10.0.0.2", Gateway: 9091}, false))
+
+	next, err := a.Allocate("/tmp/goduck/node4", "10.0.0.1", false)
+	require.Nil(t, err)
+	require.Equal(t, BaseGrpc+1, next.Grpc)
+	require.Equal(t, BaseGateway+1, next.Gateway)
+}
+
+func TestAllocateDockerAndBinaryNodes(t *testing.T) {
+	m := &Manifest{}
+	a := NewAllocator(m)
+	// docker nodes are recorded without a host, binary nodes on a local address
+	docker, err := a.Allocate("/tmp/goduck/docker/node1", "", false)
+	require.Nil(t, err)
+
+	a = NewAllocator(m)
+	for _, host := range []string{"127.0.0.1", "localhost", "0.0.0.0"} {
+		p, err := a.Allocate("/tmp/goduck/binary/"+host, host, false)
+		require.Nil(t, err)
+		require.NotEqual(t, docker.Grpc, p.Grpc)
+		require.NotEqual(t, docker.P2P, p.P2P)
+	}
+	require.Equal(t, BaseGrpc+3, m.Lookup("/tmp/goduck/binary/0.0.0.0").Grpc)
+
+	err = a.Reserve(&NodePorts{Repo: "/tmp/goduck/binary/node9", Host: "127.0.0.1", Gateway: docker.Gateway}, false)
+	require.NotNil(t, err)
+	require.Nil(t, a.Reserve(&NodePorts{Repo: "/tmp/goduck/remote/node1", Host: "10.0.0.1", Gateway: docker.Gateway}, false))
+}
@@ -1,6 +1,9 @@
 package repo
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -34,12 +37,16 @@ const (
 	AdminKeyName = "admin.json"
 	// Pier config name
 	PierConfigName = "pier.toml"
-	// KeyPassword
+	// KeyPassword is the password BitXHub nodes and piers decrypt their key.json with
 	KeyPassword = "bitxhub"
 	// private key name
 	KeyPriv = "key"
+	// KeyDirName is the key store of the repo, where keys are named by their labels
+	KeyDirName = "key"
 	// proposal strategy: simple majority
 	SimpleMajority = "SimpleMajority"
+	// proposal strategy: proposals pass without votes
+	ZeroPermission = "ZeroPermission"
 )
 
 func PathRoot() (string, error) {
@@ -78,3 +85,31 @@ func GetCSRPath(name, dir string) string {
 func GetCertPath(name, dir string) string {
 	return filepath.Join(dir, name+".cert")
 }
+
+// ProjectID identifies the docker objects goduck creates for the repo
+func ProjectID(repoRoot string) string {
+	if abs, err := filepath.Abs(repoRoot); err == nil {
+		repoRoot = abs
+	}
+	hash := sha256.Sum256([]byte(repoRoot))
+
+	return "goduck-" + hex.EncodeToString(hash[:])[:12]
+}
+
+// ResolveKey returns the file of key, which is either a path or the label of a key in the key store of the repo
+func ResolveKey(repoRoot, key string) (string, error) {
+	if _, err := os.Stat(key); err == nil {
+		return key, nil
+	}
+
+	dir := filepath.Join(repoRoot, KeyDirName)
+	matches, err := filepath.Glob(filepath.Join(dir, key+".*"))
+	if err != nil {
+		return "", err
+	}
+	if len(matches) != 1 {
+		return "", fmt.Errorf("key %s is not a file and there are %d keys labeled so in %s", key, len(matches), dir)
+	}
+
+	return matches[0], nil
+}
@@ -0,0 +1,245 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/meshplus/bitxhub-kit/fileutil"
+	gops "github.com/shirou/gopsutil/process"
+)
+
+const (
+	// DefaultStopTimeout is how long Stop waits after SIGTERM before sending SIGKILL
+	DefaultStopTimeout = 10 * time.Second
+
+	pollInterval = 200 * time.Millisecond
+)
+
+// Spec describes a process which should be launched and supervised
+type Spec struct {
+	Name      string
+	Component string
+	Version   string
+	Bin       string
+	Args      []string
+	Env       []string
+	Dir       string
+	// Repo is used to recognize the process among all running ones, it must appear in the command line
+	Repo string
+	Log  string
+}
+
+// State is what goduck records about a process it started
+type State struct {
+	Name      string    `json:"name"`
+	Component string    `json:"component"`
+	Version   string    `json:"version"`
+	Pid       int       `json:"pid"`
+	Bin       string    `json:"bin"`
+	Args      []string  `json:"args"`
+	Env       []string  `json:"env"`
+	Dir       string    `json:"dir"`
+	Repo      string    `json:"repo"`
+	Log       string    `json:"log"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Start launches the process in its own process group so that it outlives goduck,
+// stdout and stderr are appended to the log file of the spec.
+func Start(spec *Spec) (*State, error) {
+	if err := os.MkdirAll(filepath.Dir(spec.Log), 0755); err != nil {
+		return nil, fmt.Errorf("create log directory: %w", err)
+	}
+
+	logFile, err := os.OpenFile(spec.Log, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open log file: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(spec.Bin, spec.Args...)
+	cmd.Dir = spec.Dir
+	cmd.Env = append(os.Environ(), spec.Env...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", spec.Name, err)
+	}
+
+	state := &State{
+		Name:      spec.Name,
+		Component: spec.Component,
+		Version:   spec.Version,
+		Pid:       cmd.Process.Pid,
+		Bin:       spec.Bin,
+		Args:      spec.Args,
+		Env:       spec.Env,
+		Dir:       spec.Dir,
+		Repo:      spec.Repo,
+		Log:       spec.Log,
+		StartedAt: time.Now(),
+	}
+
+	if err := cmd.Process.Release(); err != nil {
+		return nil, fmt.Errorf("release %s: %w", spec.Name, err)
+	}
+
+	return state, nil
+}
+
+// Spec returns the spec the process was started with, used to restart it
+func (s *State) Spec() *Spec {
+	return &Spec{
+		Name:      s.Name,
+		Component: s.Component,
+		Version:   s.Version,
+		Bin:       s.Bin,
+		Args:      s.Args,
+		Env:       s.Env,
+		Dir:       s.Dir,
+		Repo:      s.Repo,
+		Log:       s.Log,
+	}
+}
+
+// Running reports whether the recorded process is still alive. A pid which
+// has been reused by another program is not considered running.
+func (s *State) Running() bool {
+	if s.Pid <= 0 {
+		return false
+	}
+
+	if err := syscall.Kill(s.Pid, 0); err != nil && err != syscall.EPERM {
+		return false
+	}
+
+	process, err := gops.NewProcess(int32(s.Pid))
+	if err != nil {
+		return false
+	}
+
+	if st, err := process.Status(); err == nil && st == "Z" {
+		return false
+	}
+
+	if s.Repo == "" {
+		return true
+	}
+
+	cmdline, err := process.Cmdline()
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(cmdline, s.Repo)
+}
+
+// Stop sends SIGTERM to the process and SIGKILL if it is still alive after timeout
+func Stop(s *State, timeout time.Duration) error {
+	if !s.Running() {
+		return nil
+	}
+
+	if err := syscall.Kill(s.Pid, syscall.SIGTERM); err != nil {
+		return fmt.Errorf("send SIGTERM to %s(%d): %w", s.Name, s.Pid, err)
+	}
+
+	if waitExit(s, timeout) {
+		return nil
+	}
+
+	if err := syscall.Kill(s.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return fmt.Errorf("send SIGKILL to %s(%d): %w", s.Name, s.Pid, err)
+	}
+
+	if !waitExit(s, timeout) {
+		return fmt.Errorf("%s(%d) is still alive after SIGKILL", s.Name, s.Pid)
+	}
+
+	return nil
+}
+
+func waitExit(s *State, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if !s.Running() {
+			return true
+		}
+		time.Sleep(pollInterval)
+	}
+
+	return !s.Running()
+}
+
+// LoadStates reads the states recorded in path, a missing file means no state
+func LoadStates(path string) ([]*State, error) {
+	if !fileutil.Exist(path) {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+
+	var states []*State
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, fmt.Errorf("unmarshal state file: %w", err)
+	}
+
+	return states, nil
+}
+
+// SaveStates writes states into path, together with a pid file that keeps one pid per line
+func SaveStates(path, pidPath string, states []*State) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+
+	if pidPath == "" {
+		return nil
+	}
+
+	var pids string
+	for _, s := range states {
+		pids += fmt.Sprintf("%d\n", s.Pid)
+	}
+
+	if err := ioutil.WriteFile(pidPath, []byte(pids), 0644); err != nil {
+		return fmt.Errorf("write pid file: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveStates removes the state file and the pid file
+func RemoveStates(path, pidPath string) error {
+	for _, p := range []string{path, pidPath} {
+		if p == "" || !fileutil.Exist(p) {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,933 @@
+// Package tomledit edits toml files in place, keeping comments and the order of keys.
+// The file is parsed by go-toml to locate keys, and only the lines of edited keys are rewritten.
+package tomledit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// headerRe matches a table or array of tables header like [log.module] or [[hosts.host]]
+var headerRe = regexp.MustCompile(`^\s*(\[\[?)\s*([A-Za-z0-9_\-. ]+?)\s*\]\]?\s*(#.*)?$`)
+
+// Document is a toml file which is edited line by line
+type Document struct {
+	lines []string
+	tree  *toml.Tree
+}
+
+// Load reads the toml file
+func Load(path string) (*Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	return doc, nil
+}
+
+// Parse parses the toml content
+func Parse(data []byte) (*Document, error) {
+	content := strings.ReplaceAll(string(data), "\r\n", "\n")
+	tree, err := toml.Load(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{lines: strings.Split(content, "\n"), tree: tree}, nil
+}
+
+// Tree returns the parsed toml tree, which must not be modified
+func (d *Document) Tree() *toml.Tree {
+	return d.tree
+}
+
+// Get returns the value of the dotted key, which is a *toml.Tree for tables
+func (d *Document) Get(key string) (interface{}, bool) {
+	keys := splitKey(key)
+	if err := d.checkPath(keys); err != nil {
+		return nil, false
+	}
+	value := d.tree.GetPath(keys)
+
+	return value, value != nil
+}
+
+// Set sets the value of the dotted key, the key is added to its table if it does not exist
+func (d *Document) Set(key string, value interface{}) error {
+	keys := splitKey(key)
+	if len(keys) == 0 {
+		return fmt.Errorf("empty key")
+	}
+	if err := d.checkPath(keys); err != nil {
+		return err
+	}
+	text, err := Format(value)
+	if err != nil {
+		return fmt.Errorf("set %s: %w", key, err)
+	}
+
+	if n := d.inlineRoot(keys); n > 0 && n < len(keys) {
+		lines, err := d.editInline(keys, n, text, false)
+		if err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+		return d.apply(key, lines)
+	}
+
+	var lines []string
+	switch d.tree.GetPath(keys).(type) {
+	case nil:
+		lines = d.insert(keys, text)
+	case *toml.Tree:
+		return fmt.Errorf("%s is a table, set its keys instead", key)
+	case []*toml.Tree:
+		return fmt.Errorf("%s is an array of tables, set it with SetTables", key)
+	default:
+		lines, err = d.replace(keys, value, text)
+		if err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+	}
+
+	return d.apply(key, lines)
+}
+
+// SetTables replaces the array of tables of the dotted key by tables, a slice of structs or maps with plain
+// values which are encoded as go-toml does. The tables are written where the first of the old ones was,
+// at the end of the parent table if there were none, and the array is removed if tables is empty.
+func (d *Document) SetTables(key string, tables interface{}) error {
+	keys := splitKey(key)
+	if len(keys) == 0 {
+		return fmt.Errorf("empty key")
+	}
+	if err := d.checkPath(keys); err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(tables)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return fmt.Errorf("set %s: %T is not a slice of tables", key, tables)
+	}
+
+	name := strings.Join(keys, ".")
+	existing := d.sections(name, true)
+	switch d.tree.GetPath(keys).(type) {
+	case nil:
+	case []*toml.Tree:
+		if len(existing) == 0 {
+			return fmt.Errorf("%s is an array of inline tables, which is not supported", key)
+		}
+	default:
+		return fmt.Errorf("%s is not an array of tables", key)
+	}
+
+	var parent [][2]int
+	if len(keys) > 1 {
+		parent = d.sections(strings.Join(keys[:len(keys)-1], "."), false)
+	}
+	headerIndent, keyIndent, blank := "", "  ", false
+	if len(existing) > 0 {
+		first := existing[0]
+		headerIndent = leadingSpace(d.lines[first[0]])
+		keyIndent = headerIndent + "  "
+		if l := firstKeyLine(d.lines, first[0]+1, first[1]); l >= 0 {
+			keyIndent = leadingSpace(d.lines[l])
+		}
+		blank = len(existing) > 1 && strings.TrimSpace(d.lines[existing[1][0]-1]) == ""
+	} else if len(parent) > 0 {
+		headerIndent = leadingSpace(d.lines[parent[0][0]]) + "  "
+		if l := firstKeyLine(d.lines, parent[0][0]+1, parent[0][1]); l >= 0 {
+			headerIndent = leadingSpace(d.lines[l])
+		}
+		keyIndent = headerIndent + "  "
+	}
+
+	var block []string
+	for i := 0; i < rv.Len(); i++ {
+		data, err := toml.Marshal(rv.Index(i).Interface())
+		if err != nil {
+			return fmt.Errorf("set %s: %w", key, err)
+		}
+		if i > 0 && blank {
+			block = append(block, "")
+		}
+		block = append(block, headerIndent+"[["+name+"]]")
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if line != "" {
+				block = append(block, keyIndent+line)
+			}
+		}
+	}
+
+	var lines []string
+	switch {
+	case len(existing) > 0:
+		// blank lines between the old tables go with them
+		for i := 1; i < len(existing); i++ {
+			if !allBlank(d.lines[existing[i-1][1]:existing[i][0]]) {
+				return fmt.Errorf("tables of %s are not next to each other", key)
+			}
+		}
+		lines = append([]string{}, d.lines[:existing[0][0]]...)
+		lines = append(lines, block...)
+		lines = append(lines, d.lines[existing[len(existing)-1][1]:]...)
+	case len(block) == 0:
+		return nil
+	case len(parent) > 0:
+		lines = append([]string{}, d.lines[:parent[0][1]]...)
+		lines = append(lines, block...)
+		lines = append(lines, d.lines[parent[0][1]:]...)
+	default:
+		lines = appendSection(d.lines, block)
+	}
+
+	return d.apply(key, lines)
+}
+
+// CopyTable replaces the table of the dotted key, with its sub-tables and arrays of tables, by the one in src.
+// The table is appended if the document does not have it.
+func (d *Document) CopyTable(key string, src *Document) error {
+	name := normalize(key)
+	from := src.sections(name, false)
+	if len(from) == 0 {
+		return fmt.Errorf("table %s is not found", key)
+	}
+	section := src.lines[from[0][0]:from[0][1]]
+
+	var lines []string
+	if to := d.sections(name, false); len(to) > 0 {
+		lines = append([]string{}, d.lines[:to[0][0]]...)
+		lines = append(lines, section...)
+		lines = append(lines, d.lines[to[0][1]:]...)
+	} else {
+		if d.tree.GetPath(splitKey(key)) != nil {
+			return fmt.Errorf("table %s has no header, which is not supported", key)
+		}
+		lines = appendSection(d.lines, section)
+	}
+
+	return d.apply(key, lines)
+}
+
+// Unset removes the dotted key, the table with its sub-tables if the key is a table with a header,
+// or every table of an array of tables
+func (d *Document) Unset(key string) error {
+	keys := splitKey(key)
+	if len(keys) == 0 {
+		return fmt.Errorf("empty key")
+	}
+	if err := d.checkPath(keys); err != nil {
+		return err
+	}
+
+	// keys in inline tables are removed from the table, an inline table with the line of its assignment
+	if n := d.inlineRoot(keys); n > 0 && n < len(keys) {
+		lines, err := d.editInline(keys, n, "", true)
+		if err != nil {
+			return fmt.Errorf("unset %s: %w", key, err)
+		}
+		return d.apply(key, lines)
+	} else if n == len(keys) {
+		line, col, err := d.locate(keys)
+		if err != nil {
+			return fmt.Errorf("unset %s: %w", key, err)
+		}
+		end, _ := skipBrackets(d.lines, line, col)
+		return d.apply(key, remove(d.lines, line, end+1))
+	}
+
+	var lines []string
+	switch d.tree.GetPath(keys).(type) {
+	case nil:
+		return fmt.Errorf("key %s is not found", key)
+	case *toml.Tree:
+		sections := d.sections(strings.Join(keys, "."), false)
+		if len(sections) == 0 {
+			return fmt.Errorf("table %s has no header, unset its keys instead", key)
+		}
+		lines = remove(d.lines, sections[0][0], sections[0][1])
+	case []*toml.Tree:
+		return d.SetTables(key, []interface{}{})
+	default:
+		start, _, end, _, err := d.span(keys)
+		if err != nil {
+			return fmt.Errorf("unset %s: %w", key, err)
+		}
+		lines = remove(d.lines, start, end+1)
+	}
+
+	return d.apply(key, lines)
+}
+
+// Bytes returns the content of the document
+func (d *Document) Bytes() []byte {
+	return []byte(strings.Join(d.lines, "\n"))
+}
+
+// Save writes the document to path
+func (d *Document) Save(path string) error {
+	return ioutil.WriteFile(path, d.Bytes(), 0644)
+}
+
+// apply parses the edited lines again so that an edit never produces an invalid file
+func (d *Document) apply(key string, lines []string) error {
+	tree, err := toml.Load(strings.Join(lines, "\n"))
+	if err != nil {
+		return fmt.Errorf("edit %s: %w", key, err)
+	}
+	d.lines, d.tree = lines, tree
+
+	return nil
+}
+
+func (d *Document) checkPath(keys []string) error {
+	for i := 1; i < len(keys); i++ {
+		switch d.tree.GetPath(keys[:i]).(type) {
+		case nil, *toml.Tree:
+		case []*toml.Tree:
+			return fmt.Errorf("%s is an array of tables, which is not supported", strings.Join(keys[:i], "."))
+		default:
+			return fmt.Errorf("%s is not a table", strings.Join(keys[:i], "."))
+		}
+	}
+
+	return nil
+}
+
+// replace rewrites the value of an existing key, the comment after the value is kept
+func (d *Document) replace(keys []string, value interface{}, text string) ([]string, error) {
+	startLine, startCol, endLine, endCol, err := d.span(keys)
+	if err != nil {
+		return nil, err
+	}
+	if endLine > startLine && d.lines[startLine][startCol] == '[' {
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+			return d.replaceArray(startLine, startCol, endLine, endCol, rv)
+		}
+	}
+
+	return replaceText(d.lines, startLine, startCol, endLine, endCol, text), nil
+}
+
+// arrayItem is an item of a multi-line array with the comments on the lines before it and the one after it
+type arrayItem struct {
+	comments []string
+	trailing string
+}
+
+// replaceArray rewrites a multi-line array with one item per line. The comments before an item and after
+// it on its line stay with the item at the same index, the comments after the last item are kept.
+func (d *Document) replaceArray(startLine, startCol, endLine, endCol int, rv reflect.Value) ([]string, error) {
+	var (
+		items         []*arrayItem
+		pending       []string
+		indent        string
+		itemLine      = -1
+		trailingComma bool
+	)
+	for l, c := startLine, startCol+1; l < endLine || l == endLine && c < endCol-1; {
+		line := d.lines[l]
+		if c >= len(line) {
+			l, c = l+1, 0
+			continue
+		}
+		switch line[c] {
+		case ' ', '\t':
+			c++
+		case ',':
+			trailingComma = true
+			c++
+		case '#':
+			if comment := strings.TrimRight(line[c:], " \t"); itemLine == l {
+				items[len(items)-1].trailing = comment
+			} else {
+				pending = append(pending, comment)
+			}
+			c = len(line)
+		default:
+			if len(items) == 0 && strings.TrimSpace(line[:c]) == "" {
+				indent = line[:c]
+			}
+			items = append(items, &arrayItem{comments: pending})
+			pending, trailingComma = nil, false
+			l, c = skipItem(d.lines, l, c)
+			itemLine = l
+		}
+	}
+
+	if indent == "" {
+		indent = leadingSpace(d.lines[startLine]) + "  "
+	}
+	closing := leadingSpace(d.lines[startLine])
+	if strings.TrimSpace(d.lines[endLine][:endCol-1]) == "" {
+		closing = d.lines[endLine][:endCol-1]
+	}
+
+	lines := append([]string{}, d.lines[:startLine]...)
+	lines = append(lines, d.lines[startLine][:startCol]+"[")
+	for i := 0; i < rv.Len(); i++ {
+		text, err := Format(rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		line := indent + text
+		if i < rv.Len()-1 || trailingComma {
+			line += ","
+		}
+		if i < len(items) {
+			for _, comment := range items[i].comments {
+				lines = append(lines, indent+comment)
+			}
+			if items[i].trailing != "" {
+				line += " " + items[i].trailing
+			}
+		}
+		lines = append(lines, line)
+	}
+	for _, comment := range pending {
+		lines = append(lines, indent+comment)
+	}
+	lines = append(lines, closing+"]"+d.lines[endLine][endCol:])
+
+	return append(lines, d.lines[endLine+1:]...), nil
+}
+
+// inlineRoot returns the number of keys of the outermost inline table which the key is in or is, 0 if there
+// is none. go-toml records no positions of inline tables.
+func (d *Document) inlineRoot(keys []string) int {
+	for i := 1; i <= len(keys); i++ {
+		tree, ok := d.tree.GetPath(keys[:i]).(*toml.Tree)
+		if !ok {
+			return 0
+		}
+		if tree.Position().Invalid() {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// editInline sets the key in the inline table of its first n keys to text, or removes it if unset is true
+func (d *Document) editInline(keys []string, n int, text string, unset bool) ([]string, error) {
+	line, col, err := d.locate(keys[:n])
+	if err != nil {
+		return nil, err
+	}
+	endLine, endCol := skipBrackets(d.lines, line, col)
+
+	var table string
+	if line == endLine {
+		table = d.lines[line][col:endCol]
+	} else {
+		table = strings.Join(append(append([]string{d.lines[line][col:]}, d.lines[line+1:endLine]...), d.lines[endLine][:endCol]), "\n")
+	}
+	edited, err := editInlineTable(table, keys[n:], text, unset)
+	if err != nil {
+		return nil, err
+	}
+
+	return replaceText(d.lines, line, col, endLine, endCol, edited), nil
+}
+
+// locate returns where the value of the key starts by looking for its assignment in the table it is in,
+// for keys whose position go-toml does not record
+func (d *Document) locate(keys []string) (int, int, error) {
+	start, table := 0, 0
+	for j := len(keys) - 1; j > 0; j-- {
+		if h := d.header(keys[:j]); h >= 0 {
+			start, table = h+1, j
+			break
+		}
+	}
+
+	for l := start; l < len(d.lines); l++ {
+		line := d.lines[l]
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if headerRe.MatchString(line) {
+			break
+		}
+		eq := keyEnd(line, 0)
+		if eq < 0 {
+			continue
+		}
+		c := eq + 1
+		for c < len(line) && (line[c] == ' ' || line[c] == '\t') {
+			c++
+		}
+		if equalKeys(parseKey(line[:eq]), keys[table:]) {
+			return l, c, nil
+		}
+		l, _ = scanValue(d.lines, l, c)
+	}
+
+	return 0, 0, fmt.Errorf("assignment of the key is not found")
+}
+
+// sections returns the lines of the tables with the header name, with their sub-tables, as [start, end)
+// ranges. Arrays of tables have a range for each table. Blank lines before the next table are left out.
+func (d *Document) sections(name string, array bool) [][2]int {
+	var sections [][2]int
+	for i := 0; i < len(d.lines); i++ {
+		m := headerRe.FindStringSubmatch(d.lines[i])
+		if m == nil || normalize(m[2]) != name || (m[1] == "[[") != array {
+			continue
+		}
+		end := i + 1
+		for ; end < len(d.lines); end++ {
+			if m := headerRe.FindStringSubmatch(d.lines[end]); m != nil && !strings.HasPrefix(normalize(m[2]), name+".") {
+				break
+			}
+		}
+		for end > i+1 && strings.TrimSpace(d.lines[end-1]) == "" {
+			end--
+		}
+		sections = append(sections, [2]int{i, end})
+	}
+
+	return sections
+}
+
+// insert adds the key into the nearest table with a header, a new table is appended for a new top-level table
+func (d *Document) insert(keys []string, text string) []string {
+	parent := len(keys) - 1
+	for ; parent > 0; parent-- {
+		if d.header(keys[:parent]) >= 0 {
+			break
+		}
+	}
+
+	if parent == 0 && len(keys) > 1 && d.tree.GetPath(keys[:1]) == nil {
+		return appendSection(d.lines, []string{
+			"[" + strings.Join(keys[:len(keys)-1], ".") + "]",
+			fmt.Sprintf("%s = %s", keys[len(keys)-1], text),
+		})
+	}
+
+	start := 0
+	if parent > 0 {
+		start = d.header(keys[:parent]) + 1
+	}
+	end := start
+	for end < len(d.lines) && !headerRe.MatchString(d.lines[end]) {
+		end++
+	}
+
+	at, indent := start, ""
+	if start > 0 {
+		indent = leadingSpace(d.lines[start-1])
+	}
+	foundIndent := false
+	for i := start; i < end; i++ {
+		trimmed := strings.TrimSpace(d.lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		at = i + 1
+		if !foundIndent && strings.Contains(trimmed, "=") {
+			indent, foundIndent = leadingSpace(d.lines[i]), true
+		}
+	}
+
+	line := fmt.Sprintf("%s%s = %s", indent, strings.Join(keys[parent:], "."), text)
+	lines := append([]string{}, d.lines[:at]...)
+	lines = append(lines, line)
+
+	return append(lines, d.lines[at:]...)
+}
+
+// header returns the line of the header of the table, -1 if the table has no header
+func (d *Document) header(keys []string) int {
+	name := strings.Join(keys, ".")
+	for i, line := range d.lines {
+		if m := headerRe.FindStringSubmatch(line); m != nil && m[1] == "[" && normalize(m[2]) == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// span returns where the value of the key starts and ends, the end column is exclusive
+func (d *Document) span(keys []string) (int, int, int, int, error) {
+	pos := d.tree.GetPositionPath(keys)
+	if pos.Invalid() {
+		return 0, 0, 0, 0, fmt.Errorf("position of the key is unknown")
+	}
+
+	startLine := pos.Line - 1
+	line := d.lines[startLine]
+	eq := strings.Index(line[pos.Col-1:], "=")
+	if eq < 0 {
+		return 0, 0, 0, 0, fmt.Errorf("assignment of the key is not found at line %d", pos.Line)
+	}
+	startCol := pos.Col - 1 + eq + 1
+	for startCol < len(line) && (line[startCol] == ' ' || line[startCol] == '\t') {
+		startCol++
+	}
+
+	endLine, endCol := scanValue(d.lines, startLine, startCol)
+
+	return startLine, startCol, endLine, endCol, nil
+}
+
+// scanValue finds the end of the value starting at the line and column, values may span lines
+// like arrays and multi-line strings
+func scanValue(lines []string, l, c int) (int, int) {
+	depth := 0
+	lastLine, lastCol := l, c
+	for ; l < len(lines); l, c = l+1, 0 {
+		line := lines[l]
+		for c < len(line) {
+			ch := line[c]
+			switch {
+			case strings.HasPrefix(line[c:], `"""`) || strings.HasPrefix(line[c:], `'''`):
+				l, c = skipMultiline(lines, l, c+3, line[c:c+3])
+				line = lines[l]
+				lastLine, lastCol = l, c
+				continue
+			case ch == '"' || ch == '\'':
+				c = skipString(line, c+1, ch)
+				lastLine, lastCol = l, c
+				continue
+			case ch == '#':
+				c = len(line)
+				continue
+			case ch == '[' || ch == '{':
+				depth++
+			case ch == ']' || ch == '}':
+				depth--
+			}
+			c++
+			if ch != ' ' && ch != '\t' {
+				lastLine, lastCol = l, c
+			}
+		}
+		if depth <= 0 {
+			break
+		}
+	}
+
+	return lastLine, lastCol
+}
+
+// skipBrackets returns the line and the column after the array or inline table starting at the line and column
+func skipBrackets(lines []string, l, c int) (int, int) {
+	depth := 0
+	for ; l < len(lines); l, c = l+1, 0 {
+		line := lines[l]
+		for c < len(line) {
+			ch := line[c]
+			switch {
+			case strings.HasPrefix(line[c:], `"""`) || strings.HasPrefix(line[c:], `'''`):
+				l, c = skipMultiline(lines, l, c+3, line[c:c+3])
+				line = lines[l]
+				continue
+			case ch == '"' || ch == '\'':
+				c = skipString(line, c+1, ch)
+				continue
+			case ch == '#':
+				c = len(line)
+				continue
+			case ch == '[' || ch == '{':
+				depth++
+			case ch == ']' || ch == '}':
+				depth--
+				if depth == 0 {
+					return l, c + 1
+				}
+			}
+			c++
+		}
+	}
+
+	return len(lines) - 1, len(lines[len(lines)-1])
+}
+
+// skipItem returns the line and the column after the array item starting at the line and column
+func skipItem(lines []string, l, c int) (int, int) {
+	line := lines[l]
+	switch {
+	case strings.HasPrefix(line[c:], `"""`) || strings.HasPrefix(line[c:], `'''`):
+		return skipMultiline(lines, l, c+3, line[c:c+3])
+	case line[c] == '"' || line[c] == '\'':
+		return l, skipString(line, c+1, line[c])
+	case line[c] == '[' || line[c] == '{':
+		return skipBrackets(lines, l, c)
+	}
+	for c < len(line) && !strings.ContainsRune(", \t]#", rune(line[c])) {
+		c++
+	}
+
+	return l, c
+}
+
+// editInlineTable sets the key in the inline table to text, or removes it if unset is true.
+// A key which is not in the table is added after the last one.
+func editInlineTable(table string, keys []string, text string, unset bool) (string, error) {
+	type entry struct {
+		keys              []string
+		start, value, end int
+	}
+
+	var entries []entry
+	for i := skipSpace(table, 1); i < len(table) && table[i] != '}'; {
+		eq := keyEnd(table, i)
+		if eq < 0 {
+			return "", fmt.Errorf("invalid inline table %s", table)
+		}
+		e := entry{keys: parseKey(table[i:eq]), start: i, value: skipSpace(table, eq+1)}
+		if e.value >= len(table) {
+			return "", fmt.Errorf("invalid inline table %s", table)
+		}
+		e.end = valueEnd(table, e.value)
+		entries = append(entries, e)
+
+		if i = skipSpace(table, e.end); i < len(table) && table[i] == ',' {
+			i = skipSpace(table, i+1)
+		}
+	}
+
+	for i, e := range entries {
+		switch {
+		case equalKeys(e.keys, keys) && !unset:
+			return table[:e.value] + text + table[e.end:], nil
+		case equalKeys(e.keys, keys) && len(entries) == 1:
+			return "{}", nil
+		case equalKeys(e.keys, keys) && i < len(entries)-1:
+			return table[:e.start] + table[entries[i+1].start:], nil
+		case equalKeys(e.keys, keys):
+			return table[:entries[i-1].end] + table[e.end:], nil
+		case len(e.keys) < len(keys) && equalKeys(e.keys, keys[:len(e.keys)]) && table[e.value] == '{':
+			value, err := editInlineTable(table[e.value:e.end], keys[len(e.keys):], text, unset)
+			if err != nil {
+				return "", err
+			}
+			return table[:e.value] + value + table[e.end:], nil
+		}
+	}
+	if unset {
+		return "", fmt.Errorf("key %s is not found", strings.Join(keys, "."))
+	}
+
+	added := fmt.Sprintf("%s = %s", keys[len(keys)-1], text)
+	for i := len(keys) - 2; i >= 0; i-- {
+		added = fmt.Sprintf("%s = { %s }", keys[i], added)
+	}
+	if len(entries) == 0 {
+		return "{ " + added + " }", nil
+	}
+	last := entries[len(entries)-1].end
+
+	return table[:last] + ", " + added + table[last:], nil
+}
+
+// valueEnd returns the offset after the value starting at i in s
+func valueEnd(s string, i int) int {
+	switch s[i] {
+	case '"', '\'':
+		if strings.HasPrefix(s[i:], `"""`) || strings.HasPrefix(s[i:], `'''`) {
+			delim := s[i : i+3]
+			if j := strings.Index(s[i+3:], delim); j >= 0 {
+				return i + 3 + j + 3
+			}
+			return len(s)
+		}
+		return skipString(s, i+1, s[i])
+	case '[', '{':
+		depth := 0
+		for j := i; j < len(s); j++ {
+			switch s[j] {
+			case '"', '\'':
+				j = skipString(s, j+1, s[j]) - 1
+			case '[', '{':
+				depth++
+			case ']', '}':
+				if depth--; depth == 0 {
+					return j + 1
+				}
+			}
+		}
+		return len(s)
+	}
+
+	j := i
+	for j < len(s) && !strings.ContainsRune(",}] \t\n#", rune(s[j])) {
+		j++
+	}
+
+	return j
+}
+
+// keyEnd returns the offset of the "=" after the key starting at i in s, -1 if there is none
+func keyEnd(s string, i int) int {
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			i = skipString(s, i+1, s[i]) - 1
+		case '=':
+			return i
+		case '\n', '#', '[', '{', '}', ',':
+			return -1
+		}
+	}
+
+	return -1
+}
+
+// parseKey splits a key as written in toml, which may be dotted and quoted
+func parseKey(raw string) []string {
+	var keys []string
+	start := 0
+	for i := 0; i <= len(raw); i++ {
+		if i < len(raw) && (raw[i] == '"' || raw[i] == '\'') {
+			i = skipString(raw, i+1, raw[i]) - 1
+			continue
+		}
+		if i == len(raw) || raw[i] == '.' {
+			k := strings.TrimSpace(raw[start:i])
+			if len(k) >= 2 && (k[0] == '"' || k[0] == '\'') && k[len(k)-1] == k[0] {
+				k = k[1 : len(k)-1]
+			}
+			keys = append(keys, k)
+			start = i + 1
+		}
+	}
+
+	return keys
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// replaceText replaces the text between the positions, the end column is exclusive
+func replaceText(lines []string, startLine, startCol, endLine, endCol int, text string) []string {
+	edited := lines[startLine][:startCol] + text + lines[endLine][endCol:]
+	result := append([]string{}, lines[:startLine]...)
+	result = append(result, strings.Split(edited, "\n")...)
+
+	return append(result, lines[endLine+1:]...)
+}
+
+// appendSection appends the lines of a table at the end, after a blank line
+func appendSection(lines []string, section []string) []string {
+	result := append([]string{}, lines...)
+	for len(result) > 0 && strings.TrimSpace(result[len(result)-1]) == "" {
+		result = result[:len(result)-1]
+	}
+	if len(result) > 0 {
+		result = append(result, "")
+	}
+	result = append(result, section...)
+
+	return append(result, "")
+}
+
+// firstKeyLine returns the first line in [start, end) which is not blank or a comment, -1 if there is none
+func firstKeyLine(lines []string, start, end int) int {
+	for l := start; l < end; l++ {
+		if trimmed := strings.TrimSpace(lines[l]); trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return l
+		}
+	}
+
+	return -1
+}
+
+func allBlank(lines []string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.ContainsRune(" \t\n", rune(s[i])) {
+		i++
+	}
+
+	return i
+}
+
+// skipString returns the column after the closing quote of a single line string
+func skipString(line string, c int, quote byte) int {
+	for ; c < len(line); c++ {
+		if quote == '"' && line[c] == '\\' {
+			c++
+			continue
+		}
+		if line[c] == quote {
+			return c + 1
+		}
+	}
+
+	return c
+}
+
+// skipMultiline returns the position after the closing delimiter of a multi-line string
+func skipMultiline(lines []string, l, c int, delim string) (int, int) {
+	for ; l < len(lines); l, c = l+1, 0 {
+		line := lines[l]
+		for ; c < len(line); c++ {
+			if delim == `"""` && line[c] == '\\' {
+				c++
+				continue
+			}
+			if strings.HasPrefix(line[c:], delim) {
+				return l, c + len(delim)
+			}
+		}
+	}
+
+	return len(lines) - 1, len(lines[len(lines)-1])
+}
+
+func splitKey(key string) []string {
+	var keys []string
+	for _, k := range strings.Split(key, ".") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+func normalize(name string) string {
+	return strings.Join(splitKey(name), ".")
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+func remove(lines []string, start, end int) []string {
+	result := append([]string{}, lines[:start]...)
+
+	return append(result, lines[end:]...)
+}
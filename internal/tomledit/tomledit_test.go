@@ -0,0 +1,341 @@
+package tomledit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type admin struct {
+	Address string `toml:"address"`
+	Weight  uint64 `toml:"weight"`
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		edit func(d *Document) error
+		want string
+	}{
+		{
+			name: "replace keeps comments",
+			doc: `# ports
+[port]
+  grpc = 60011 # grpc port
+  gateway = 9091
+`,
+			edit: func(d *Document) error { return d.Set("port.grpc", int64(60021)) },
+			want: `# ports
+[port]
+  grpc = 60021 # grpc port
+  gateway = 9091
+`,
+		},
+		{
+			name: "insert into existing table",
+			doc: `[port]
+  grpc = 60011
+
+[log]
+  level = "info"
+`,
+			edit: func(d *Document) error { return d.Set("port.pprof", int64(53121)) },
+			want: `[port]
+  grpc = 60011
+  pprof = 53121
+
+[log]
+  level = "info"
+`,
+		},
+		{
+			name: "insert into missing table",
+			doc: `title = "bitxhub"
+
+[port]
+  grpc = 60011
+`,
+			edit: func(d *Document) error { return d.Set("order.type", "raft") },
+			want: `title = "bitxhub"
+
+[port]
+  grpc = 60011
+
+[order]
+type = "raft"
+`,
+		},
+		{
+			name: "unset key",
+			doc: `[port]
+  # grpc port
+  grpc = 60011
+  gateway = 9091
+`,
+			edit: func(d *Document) error { return d.Unset("port.gateway") },
+			want: `[port]
+  # grpc port
+  grpc = 60011
+`,
+		},
+		{
+			name: "unset table with sub-tables",
+			doc: `[log]
+  level = "info"
+  [log.module]
+    p2p = "info"
+
+[port]
+  grpc = 60011
+`,
+			edit: func(d *Document) error { return d.Unset("log") },
+			want: `
+[port]
+  grpc = 60011
+`,
+		},
+		{
+			name: "set in inline table",
+			doc: `[a]
+  b = { c = 1, d = { e = "x" } } # inline
+`,
+			edit: func(d *Document) error { return d.Set("a.b.c", int64(2)) },
+			want: `[a]
+  b = { c = 2, d = { e = "x" } } # inline
+`,
+		},
+		{
+			name: "set in nested inline table",
+			doc: `[a]
+  b = { c = 1, d = { e = "x" } }
+`,
+			edit: func(d *Document) error { return d.Set("a.b.d.e", "y") },
+			want: `[a]
+  b = { c = 1, d = { e = "y" } }
+`,
+		},
+		{
+			name: "insert into inline table",
+			doc: `b = { c = 1 }
+`,
+			edit: func(d *Document) error { return d.Set("b.f.g", true) },
+			want: `b = { c = 1, f = { g = true } }
+`,
+		},
+		{
+			name: "unset in inline table",
+			doc: `[a]
+  b = { c = 1, d = 2, e = 3 }
+`,
+			edit: func(d *Document) error {
+				if err := d.Unset("a.b.d"); err != nil {
+					return err
+				}
+				return d.Unset("a.b.e")
+			},
+			want: `[a]
+  b = { c = 1 }
+`,
+		},
+		{
+			name: "unset inline table",
+			doc: `[a]
+  b = { c = 1 }
+  x = 2
+`,
+			edit: func(d *Document) error { return d.Unset("a.b") },
+			want: `[a]
+  x = 2
+`,
+		},
+		{
+			name: "dotted keys",
+			doc: `p.q.r = 1 # dotted
+[a]
+  f.g = 5
+`,
+			edit: func(d *Document) error {
+				if err := d.Set("p.q.r", int64(2)); err != nil {
+					return err
+				}
+				if err := d.Set("a.f.g", int64(6)); err != nil {
+					return err
+				}
+				return d.Set("p.q.s", "new")
+			},
+			want: `p.q.r = 2 # dotted
+p.q.s = "new"
+[a]
+  f.g = 6
+`,
+		},
+		{
+			name: "multi-line array keeps comments",
+			doc: `hosts = [
+  # the first node
+  "/ip4/127.0.0.1/tcp/4001/p2p/", # local
+  # the second node
+  "/ip4/127.0.0.1/tcp/4002/p2p/",
+  # more nodes go here
+] # hosts
+id = 1
+`,
+			edit: func(d *Document) error {
+				return d.Set("hosts", []string{"/ip4/10.0.0.1/tcp/4001/p2p/", "/ip4/10.0.0.2/tcp/4002/p2p/", "/ip4/10.0.0.3/tcp/4003/p2p/"})
+			},
+			want: `hosts = [
+  # the first node
+  "/ip4/10.0.0.1/tcp/4001/p2p/", # local
+  # the second node
+  "/ip4/10.0.0.2/tcp/4002/p2p/",
+  "/ip4/10.0.0.3/tcp/4003/p2p/",
+  # more nodes go here
+] # hosts
+id = 1
+`,
+		},
+		{
+			name: "multi-line array shrinks",
+			doc: `[genesis]
+  addresses = [
+    "0x1", # node1
+    # node2
+    "0x2"
+  ]
+`,
+			edit: func(d *Document) error { return d.Set("genesis.addresses", []string{"0x3"}) },
+			want: `[genesis]
+  addresses = [
+    "0x3" # node1
+  ]
+`,
+		},
+		{
+			name: "replace array of tables",
+			doc: `[genesis]
+  dider = "0x1"
+  # admins
+  [[genesis.admins]]
+    address = "0x1"
+    weight = 1 # weight
+  [[genesis.admins]]
+    address = "0x2"
+    weight = 1
+  [genesis.strategy]
+    AppchainMgr = "SimpleMajority"
+`,
+			edit: func(d *Document) error {
+				return d.SetTables("genesis.admins", []*admin{{"0x3", 2}})
+			},
+			want: `[genesis]
+  dider = "0x1"
+  # admins
+  [[genesis.admins]]
+    address = "0x3"
+    weight = 2
+  [genesis.strategy]
+    AppchainMgr = "SimpleMajority"
+`,
+		},
+		{
+			name: "add array of tables",
+			doc: `id = 1
+n = 1
+`,
+			edit: func(d *Document) error {
+				return d.SetTables("nodes", []map[string]interface{}{{"id": int64(1), "pid": "Qm1"}})
+			},
+			want: `id = 1
+n = 1
+
+[[nodes]]
+  id = 1
+  pid = "Qm1"
+`,
+		},
+		{
+			name: "unset array of tables",
+			doc: `id = 1
+
+[[nodes]]
+  id = 1
+
+[[nodes]]
+  id = 2
+`,
+			edit: func(d *Document) error { return d.Unset("nodes") },
+			want: `id = 1
+
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := Parse([]byte(tt.doc))
+			require.Nil(t, err)
+			require.Nil(t, tt.edit(d))
+			require.Equal(t, tt.want, string(d.Bytes()))
+		})
+	}
+}
+
+func TestCopyTable(t *testing.T) {
+	src, err := Parse([]byte(`[genesis]
+  dider = "0x1"
+  [[genesis.admins]]
+    address = "0x1"
+    weight = 1
+  [genesis.strategy]
+    AppchainMgr = "SimpleMajority"
+
+[port]
+  grpc = 60011
+`))
+	require.Nil(t, err)
+	d, err := Parse([]byte(`# node5
+[port]
+  grpc = 60015 # grpc
+
+[genesis]
+  dider = "0x5"
+
+[log]
+  level = "info"
+`))
+	require.Nil(t, err)
+
+	require.Nil(t, d.CopyTable("genesis", src))
+	require.Equal(t, `# node5
+[port]
+  grpc = 60015 # grpc
+
+[genesis]
+  dider = "0x1"
+  [[genesis.admins]]
+    address = "0x1"
+    weight = 1
+  [genesis.strategy]
+    AppchainMgr = "SimpleMajority"
+
+[log]
+  level = "info"
+`, string(d.Bytes()))
+}
+
+func TestEditErrors(t *testing.T) {
+	d, err := Parse([]byte(`[port]
+  grpc = 60011
+[[nodes]]
+  id = 1
+`))
+	require.Nil(t, err)
+
+	require.NotNil(t, d.Set("port", int64(1)))
+	require.NotNil(t, d.Set("port.grpc.x", int64(1)))
+	require.NotNil(t, d.Set("nodes", int64(1)))
+	require.NotNil(t, d.Set("nodes.id", int64(1)))
+	require.NotNil(t, d.Unset("port.pprof"))
+	require.NotNil(t, d.SetTables("port.grpc", []*admin{}))
+}
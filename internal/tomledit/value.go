@@ -0,0 +1,190 @@
+package tomledit
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Format returns the toml representation of a value
+func Format(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return quote(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return "", fmt.Errorf("integer %d overflows", v)
+		}
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return formatFloat(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case nil:
+		return "", fmt.Errorf("nil value")
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return "", fmt.Errorf("unsupported value type %T", value)
+	}
+	items := make([]string, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		item, err := Format(rv.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		items = append(items, item)
+	}
+
+	return "[" + strings.Join(items, ", ") + "]", nil
+}
+
+// ParseValue parses a toml value like 1, true or ["a", "b"], raw is taken as a string if it is not a toml value
+func ParseValue(raw string) interface{} {
+	tree, err := toml.Load("v = " + raw)
+	if err != nil {
+		return raw
+	}
+
+	return tree.Get("v")
+}
+
+// ParseAs parses raw into the type of like, which is a value decoded by go-toml.
+// An array may also be given as comma separated items.
+func ParseAs(raw string, like interface{}) (interface{}, error) {
+	switch like.(type) {
+	case string:
+		return raw, nil
+	case nil:
+		return ParseValue(raw), nil
+	case []interface{}:
+		return parseArray(raw, like.([]interface{}))
+	}
+
+	value := ParseValue(raw)
+	switch like.(type) {
+	case int64:
+		if v, ok := value.(int64); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%q is not an integer", raw)
+	case float64:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int64:
+			return float64(v), nil
+		}
+		return nil, fmt.Errorf("%q is not a float", raw)
+	case bool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", raw)
+	case time.Time:
+		if v, ok := value.(time.Time); ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("%q is not a datetime", raw)
+	case *toml.Tree, []*toml.Tree:
+		return nil, fmt.Errorf("tables can not be set as a value")
+	}
+
+	return value, nil
+}
+
+func parseArray(raw string, like []interface{}) (interface{}, error) {
+	var elem interface{}
+	if len(like) > 0 {
+		elem = like[0]
+	}
+
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasPrefix(trimmed, "[") {
+		value, ok := ParseValue(trimmed).([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%q is not an array", raw)
+		}
+		if elem == nil {
+			return value, nil
+		}
+		for _, item := range value {
+			if reflect.TypeOf(item) != reflect.TypeOf(elem) {
+				return nil, fmt.Errorf("item %v of %q is not a %T", item, raw, elem)
+			}
+		}
+		return value, nil
+	}
+
+	items := []interface{}{}
+	if trimmed == "" {
+		return items, nil
+	}
+	for _, s := range strings.Split(trimmed, ",") {
+		item, err := ParseAs(strings.TrimSpace(s), elem)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "nan"
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+
+	return s
+}
@@ -0,0 +1,245 @@
+package topology
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/meshplus/goduck/internal/types"
+	"github.com/meshplus/goduck/internal/versions"
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v2"
+)
+
+// Topology describes a whole interchain environment: a BitXHub cluster, appchains and piers connecting them
+type Topology struct {
+	BitXHub   *BitXHub    `yaml:"bitxhub" toml:"bitxhub"`
+	Appchains []*Appchain `yaml:"appchains" toml:"appchains"`
+	Piers     []*Pier     `yaml:"piers" toml:"piers"`
+}
+
+// BitXHub describes the relay chain
+type BitXHub struct {
+	Version string `yaml:"version" toml:"version"`
+	Type    string `yaml:"type" toml:"type"`
+	Mode    string `yaml:"mode" toml:"mode"`
+	// Consensus is the consensus plugin, solo in solo mode and raft in cluster mode if it is not set
+	Consensus string `yaml:"consensus" toml:"consensus"`
+	Nodes     int    `yaml:"nodes" toml:"nodes"`
+	TLS       bool   `yaml:"tls" toml:"tls"`
+	// IPs are hosts of nodes as IPv4, IPv6 or DNS names, hosts of a node are joined by |
+	IPs []string `yaml:"ips" toml:"ips"`
+	// Subnet is the subnet of the docker network in cluster mode
+	Subnet string `yaml:"subnet" toml:"subnet"`
+	// Genesis is the genesis spec file, relative to the topology file
+	Genesis string `yaml:"genesis" toml:"genesis"`
+	// Seed derives the keys of the CA, nodes and accounts, they are random if it is not set
+	Seed string `yaml:"seed" toml:"seed"`
+}
+
+// Appchain describes an appchain started by goduck
+type Appchain struct {
+	Name string `yaml:"name" toml:"name"`
+	// Chain is the chain type, one of ethereum or fabric
+	Chain string `yaml:"chain" toml:"chain"`
+	Type  string `yaml:"type" toml:"type"`
+	// CryptoConfig is the crypto-config directory of fabric
+	CryptoConfig string `yaml:"crypto_config" toml:"crypto_config"`
+	IP           string `yaml:"ip" toml:"ip"`
+	Addr         string `yaml:"addr" toml:"addr"`
+	Ports        string `yaml:"ports" toml:"ports"`
+	ContractAddr string `yaml:"contract_addr" toml:"contract_addr"`
+}
+
+// Pier describes a pier connecting an appchain
+type Pier struct {
+	// Appchain is the name of the appchain the pier connects
+	Appchain   string   `yaml:"appchain" toml:"appchain"`
+	Version    string   `yaml:"version" toml:"version"`
+	Type       string   `yaml:"type" toml:"type"`
+	Mode       string   `yaml:"mode" toml:"mode"`
+	Method     string   `yaml:"method" toml:"method"`
+	Port       string   `yaml:"port" toml:"port"`
+	Peers      []string `yaml:"peers" toml:"peers"`
+	Connectors []string `yaml:"connectors" toml:"connectors"`
+	Providers  string   `yaml:"providers" toml:"providers"`
+	TLS        bool     `yaml:"tls" toml:"tls"`
+	HttpPort   string   `yaml:"http_port" toml:"http_port"`
+	PprofPort  string   `yaml:"pprof_port" toml:"pprof_port"`
+	ApiPort    string   `yaml:"api_port" toml:"api_port"`
+	// Seed derives the keys of the pier, they are generated by pier if it is not set
+	Seed string `yaml:"seed" toml:"seed"`
+}
+
+// Load reads a topology from a yaml or toml file, the format is chosen by file extension
+func Load(path string) (*Topology, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read topology: %w", err)
+	}
+
+	t := &Topology{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, t)
+	case ".toml":
+		err = toml.Unmarshal(data, t)
+	default:
+		return nil, fmt.Errorf("unsupported topology format %s, use yaml or toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse topology: %w", err)
+	}
+
+	t.setDefaults()
+	if t.BitXHub != nil && t.BitXHub.Genesis != "" && !filepath.IsAbs(t.BitXHub.Genesis) {
+		t.BitXHub.Genesis = filepath.Join(filepath.Dir(path), t.BitXHub.Genesis)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Appchain returns the appchain with the name
+func (t *Topology) Appchain(name string) *Appchain {
+	for _, a := range t.Appchains {
+		if a.Name == name {
+			return a
+		}
+	}
+
+	return nil
+}
+
+func (t *Topology) setDefaults() {
+	if b := t.BitXHub; b != nil {
+		if b.Version == "" {
+			b.Version = "v1.6.1"
+		}
+		if b.Type == "" {
+			b.Type = types.TypeBinary
+		}
+		if b.Mode == "" {
+			b.Mode = types.SoloMode
+		}
+		if b.Mode == types.SoloMode {
+			b.Nodes = 1
+		}
+		if b.Mode == types.ClusterMode && b.Nodes == 0 {
+			b.Nodes = 4
+		}
+	}
+
+	for _, a := range t.Appchains {
+		if a.Name == "" {
+			a.Name = a.Chain
+		}
+		if a.Type == "" {
+			a.Type = types.TypeDocker
+		}
+	}
+
+	for _, p := range t.Piers {
+		if p.Version == "" && t.BitXHub != nil {
+			p.Version = t.BitXHub.Version
+		}
+		if p.Type == "" {
+			p.Type = types.TypeBinary
+		}
+		if p.Mode == "" {
+			p.Mode = types.PierModeRelay
+		}
+		if p.Method == "" {
+			p.Method = "appchain"
+		}
+		if p.Port == "" {
+			p.Port = "5001"
+		}
+		if p.Providers == "" {
+			p.Providers = "1"
+		}
+		if p.HttpPort == "" {
+			p.HttpPort = "44544"
+		}
+		if p.PprofPort == "" {
+			p.PprofPort = "44550"
+		}
+		if p.ApiPort == "" {
+			p.ApiPort = "8080"
+		}
+	}
+}
+
+// Validate checks the topology is complete and consistent
+func (t *Topology) Validate() error {
+	if b := t.BitXHub; b != nil {
+		if b.Type != types.TypeBinary && b.Type != types.TypeDocker {
+			return fmt.Errorf("bitxhub: invalid type %s, choose one of binary or docker", b.Type)
+		}
+		if b.Mode != types.SoloMode && b.Mode != types.ClusterMode {
+			return fmt.Errorf("bitxhub: invalid mode %s, choose one of solo or cluster", b.Mode)
+		}
+		if b.Mode == types.ClusterMode && b.Nodes < 3 {
+			return fmt.Errorf("bitxhub: there are at least 3 nodes in cluster mode")
+		}
+		release, err := versions.LookupBitXHub(b.Version)
+		if err != nil {
+			return fmt.Errorf("bitxhub: %w", err)
+		}
+		if b.Consensus != "" {
+			c, err := release.LookupConsensus(b.Consensus)
+			if err != nil {
+				return fmt.Errorf("bitxhub: %w", err)
+			}
+			if err := c.CheckNodes(b.Nodes); err != nil {
+				return fmt.Errorf("bitxhub: %w", err)
+			}
+		}
+	}
+
+	names := make(map[string]bool)
+	for _, a := range t.Appchains {
+		if a.Chain != types.ChainTypeEther && a.Chain != types.ChainTypeFabric {
+			return fmt.Errorf("appchain %s: invalid chain %s, choose one of ethereum or fabric", a.Name, a.Chain)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("appchain %s: duplicated name", a.Name)
+		}
+		names[a.Name] = true
+	}
+
+	chains := make(map[string]bool)
+	for _, p := range t.Piers {
+		a := t.Appchain(p.Appchain)
+		if a == nil {
+			return fmt.Errorf("pier of %s: appchain is not defined", p.Appchain)
+		}
+		// piers are kept under $repo/pier/.pier_$chain, so there is one pier per chain type
+		if chains[a.Chain] {
+			return fmt.Errorf("pier of %s: only one pier of %s is supported", p.Appchain, a.Chain)
+		}
+		chains[a.Chain] = true
+
+		if p.Type != types.TypeBinary && p.Type != types.TypeDocker {
+			return fmt.Errorf("pier of %s: invalid type %s, choose one of binary or docker", p.Appchain, p.Type)
+		}
+		if p.Version == "" {
+			return fmt.Errorf("pier of %s: version is needed", p.Appchain)
+		}
+		release, err := versions.LookupPier(p.Version)
+		if err != nil {
+			return fmt.Errorf("pier of %s: %w", p.Appchain, err)
+		}
+		if !release.SupportsMode(p.Mode) {
+			return fmt.Errorf("pier of %s: invalid mode %s, choose one of %s", p.Appchain, p.Mode, strings.Join(release.Modes(), ", "))
+		}
+		if p.Mode == types.PierModeRelay && t.BitXHub == nil {
+			return fmt.Errorf("pier of %s: bitxhub is needed in relay mode", p.Appchain)
+		}
+	}
+
+	return nil
+}
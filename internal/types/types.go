@@ -35,6 +35,18 @@ const (
 	TmpPath                    = "tmp"
 	FabricConfig               = "config.yaml"
 	QuickStartBitxhubCofigPath = "docker/quick_start/bxhConfig/%s"
+	BitxhubStateFile           = "bitxhub.state"
+	BitxhubPidFile             = "bitxhub.pid"
+	BitxhubVersionFile         = "bitxhub.version"
+	BitxhubCidFile             = "bitxhub.cid"
+	PierStateFile              = "pier.state"
+	BitxhubImage               = "meshplus/bitxhub:%s"
+	BitxhubSoloImage           = "meshplus/bitxhub-solo:%s"
+	PierImage                  = "meshplus/pier:%s"
+	LogsDir                    = "logs"
+	TopologyStateFile          = "topology.state"
+	PortsManifest              = "ports.json"
+	PierRegistration           = "registration.json"
 
 	Pier           = "pier"
 	BitXHub        = "bitxhub"
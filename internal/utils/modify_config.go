@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// GetModifyConfigValues returns the values of key in a modify config file such as
+// bxh_modify_config.toml, in the order they appear. These files are read line by
+// line like the shell scripts do, since values are not always valid toml.
+func GetModifyConfigValues(path, key string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open modify config: %w", err)
+	}
+	defer f.Close()
+
+	var values []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != key {
+			continue
+		}
+
+		value := kv[1]
+		if idx := strings.Index(value, "#"); idx != -1 {
+			value = value[:idx]
+		}
+		values = append(values, strings.Trim(strings.TrimSpace(value), "\""))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read modify config: %w", err)
+	}
+
+	return values, nil
+}
+
+// GetModifyConfigValue returns the first value of key in a modify config file
+func GetModifyConfigValue(path, key string) (string, error) {
+	values, err := GetModifyConfigValues(path, key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(values) == 0 {
+		return "", fmt.Errorf("%s is not found in %s", key, path)
+	}
+
+	return values[0], nil
+}
+
+// SetModifyConfigValue writes the modify config at path into dst with the first value of key
+// replaced, other lines are kept as they are
+func SetModifyConfigValue(path, dst, key, value string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read modify config: %w", err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	found := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != key {
+			continue
+		}
+
+		comment := ""
+		if idx := strings.Index(kv[1], "#"); idx != -1 {
+			comment = " " + kv[1][idx:]
+		}
+		lines[i] = fmt.Sprintf("%s= %s%s", kv[0], value, comment)
+		found = true
+		break
+	}
+	if !found {
+		return fmt.Errorf("%s is not found in %s", key, path)
+	}
+
+	return ioutil.WriteFile(dst, []byte(strings.Join(lines, "\n")), 0644)
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// PasswordSource is where a key password comes from: the value of a flag or of the env var bound to it,
+// or the first line of a file. The password is asked on the terminal if none is given.
+type PasswordSource struct {
+	Password string
+	File     string
+}
+
+// Given reports whether the password is given by a flag, an env var or a file
+func (s *PasswordSource) Given() bool {
+	return s.Password != "" || s.File != ""
+}
+
+// Read returns the given password, or asks it on the terminal with prompt, twice if confirm is set
+func (s *PasswordSource) Read(prompt string, confirm bool) (string, error) {
+	if s.Password != "" {
+		return s.Password, nil
+	}
+	if s.File != "" {
+		data, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("read password file: %w", err)
+		}
+		return strings.TrimRight(strings.SplitN(string(data), "\n", 2)[0], "\r"), nil
+	}
+
+	password, err := AskSecret(prompt)
+	if err == ErrNoTerminal {
+		return "", fmt.Errorf("no password is given, use a password flag, its env var or a password file")
+	}
+	if err != nil {
+		return "", err
+	}
+	if !confirm {
+		return password, nil
+	}
+	again, err := AskSecret("Repeat " + strings.ToLower(prompt[:1]) + prompt[1:])
+	if err != nil {
+		return "", err
+	}
+	if again != password {
+		return "", fmt.Errorf("passwords do not match")
+	}
+
+	return password, nil
+}
+
+// Unlock calls open with the given password. If no password is given, open is tried with the defaults
+// keys are written with by older goduck or other tools, and then with the password asked on the terminal.
+func (s *PasswordSource) Unlock(prompt string, defaults []string, open func(password string) error) error {
+	if !s.Given() {
+		for _, password := range defaults {
+			if open(password) == nil {
+				return nil
+			}
+		}
+	}
+
+	password, err := s.Read(prompt, false)
+	if err != nil {
+		return err
+	}
+
+	return open(password)
+}
+
+// ErrNoTerminal is returned by AskSecret if there is nobody to ask
+var ErrNoTerminal = errors.New("no terminal to ask on")
+
+// AskSecret asks a password or another secret on the terminal without echoing it
+func AskSecret(prompt string) (string, error) {
+	if noInput || !terminal.IsTerminal(int(os.Stdin.Fd())) {
+		return "", ErrNoTerminal
+	}
+	fmt.Printf("%s: ", prompt)
+	secret, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", strings.ToLower(prompt), err)
+	}
+
+	return string(secret), nil
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+var (
+	assumeYes bool
+	noInput   bool
+)
+
+// SetPromptMode sets how confirmations are answered: yes answers them with yes, noInput answers
+// them with no, both never read stdin
+func SetPromptMode(yes, disableInput bool) {
+	assumeYes = yes
+	noInput = disableInput
+}
+
+// Confirm asks the question and reports whether it is answered with yes. The answer is taken from
+// the prompt mode if it is set, otherwise read from stdin, where an empty answer means no.
+// An error is returned rather than blocking if stdin is not a terminal.
+func Confirm(question string) (bool, error) {
+	fmt.Printf("%s, Y/N (default: N)?\n", question)
+
+	switch {
+	case assumeYes:
+		color.Blue("[Y] answered by --yes")
+		return true, nil
+	case noInput:
+		color.Blue("[N] answered by --no-input")
+		return false, nil
+	case !stdinIsTerminal():
+		return false, fmt.Errorf("stdin is not a terminal, use --yes to confirm or --no-input to decline")
+	}
+
+	input := bufio.NewScanner(os.Stdin)
+	if !input.Scan() {
+		return false, input.Err()
+	}
+	answer := strings.TrimSpace(input.Text())
+
+	return answer == "Y" || answer == "y", nil
+}
+
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
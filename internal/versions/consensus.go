@@ -0,0 +1,75 @@
+package versions
+
+import (
+	"fmt"
+
+	"github.com/meshplus/goduck/internal/types"
+)
+
+// consensuses are the consensus plugins of BitXHub
+var consensuses = []*Consensus{
+	{Name: "solo", MinNodes: 1, MaxNodes: 1},
+	{Name: "raft", MinNodes: 3},
+	// rbft tolerates f faulty nodes out of 3f+1
+	{Name: "rbft", MinNodes: 4, since: "v1.8.0"},
+}
+
+// Consensus is a consensus plugin of BitXHub
+type Consensus struct {
+	Name string
+	// MinNodes is the least number of nodes the consensus runs with
+	MinNodes int
+	// MaxNodes is the most number of nodes the consensus runs with, 0 means unlimited
+	MaxNodes int
+	// since is the first BitXHub version shipping the plugin
+	since string
+}
+
+// DefaultConsensus returns the consensus used when it is not specified for nodes in mode
+func DefaultConsensus(mode string) string {
+	if mode == types.SoloMode {
+		return "solo"
+	}
+
+	return "raft"
+}
+
+// Consensuses returns the consensus plugins shipped with the BitXHub version
+func (b *BitXHub) Consensuses() []string {
+	var names []string
+	for _, c := range consensuses {
+		if c.since == "" || b.AtLeast(c.since) {
+			names = append(names, c.Name)
+		}
+	}
+
+	return names
+}
+
+// LookupConsensus returns the consensus plugin shipped with the BitXHub version
+func (b *BitXHub) LookupConsensus(name string) (*Consensus, error) {
+	for _, c := range consensuses {
+		if c.Name == name && (c.since == "" || b.AtLeast(c.since)) {
+			return c, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported consensus %s for BitXHub %s, choose one of %v", name, b.Version, b.Consensuses())
+}
+
+// CheckNodes checks the consensus runs with num nodes
+func (c *Consensus) CheckNodes(num int) error {
+	if num < c.MinNodes {
+		return fmt.Errorf("consensus %s needs at least %d nodes, got %d", c.Name, c.MinNodes, num)
+	}
+	if c.MaxNodes != 0 && num > c.MaxNodes {
+		return fmt.Errorf("consensus %s runs at most %d nodes, got %d", c.Name, c.MaxNodes, num)
+	}
+
+	return nil
+}
+
+// PluginFile returns the file name of the consensus plugin
+func (c *Consensus) PluginFile() string {
+	return c.Name + ".so"
+}
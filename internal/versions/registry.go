@@ -0,0 +1,314 @@
+package versions
+
+import (
+	"fmt"
+
+	"github.com/meshplus/goduck/internal/types"
+)
+
+// NetworkFormat is the layout of network.toml of BitXHub nodes
+type NetworkFormat int
+
+const (
+	// NetworkAddrs lists nodes with their id and full address, used before v1.1.0-rc1
+	NetworkAddrs NetworkFormat = iota
+	// NetworkReadin lists the addresses of nodes only, used by v1.1.0-rc1
+	NetworkReadin
+	// NetworkHosts lists nodes with their pid, hosts and account
+	NetworkHosts
+)
+
+// GenesisFormat is the layout of the genesis of BitXHub
+type GenesisFormat int
+
+const (
+	// GenesisAddresses is a list of validator addresses
+	GenesisAddresses GenesisFormat = iota
+	// GenesisAdmins is a list of weighted admins with proposal strategies, used since v1.6.0
+	GenesisAdmins
+)
+
+// bitxhubReleases maps BitXHub releases shipped by goduck to their bxh_config script directory
+var bitxhubReleases = []struct {
+	version   string
+	configDir string
+}{
+	{"v1.6.1", "v1.6.1"},
+	{"v1.7.0", "v1.7.0"},
+	{"v1.8.0", "v1.8.0"},
+	{"v1.9.0", "v1.8.0"},
+}
+
+// pierReleases maps pier releases shipped by goduck to their pier_config script directory
+var pierReleases = []struct {
+	version   string
+	configDir string
+}{
+	{"v1.6.1", "v1.6.1"},
+	{"v1.7.0", "v1.6.1"},
+	{"v1.8.0", "v1.8.0"},
+	{"v1.9.0", "v1.8.0"},
+}
+
+// BitXHub is a BitXHub version with its capabilities
+type BitXHub struct {
+	Version
+	// ConfigDir is the bxh_config script directory, empty if the release is not shipped by goduck
+	ConfigDir string
+}
+
+// Pier is a pier version with its capabilities
+type Pier struct {
+	Version
+	// ConfigDir is the pier_config script directory, empty if the release is not shipped by goduck
+	ConfigDir string
+}
+
+// BitXHubOf parses a BitXHub version, which is not required to be shipped by goduck
+func BitXHubOf(s string) (*BitXHub, error) {
+	v, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &BitXHub{Version: v}
+	for _, r := range bitxhubReleases {
+		if MustParse(r.version) == v {
+			b.ConfigDir = r.configDir
+		}
+	}
+
+	return b, nil
+}
+
+// LookupBitXHub returns the BitXHub release shipped by goduck
+func LookupBitXHub(s string) (*BitXHub, error) {
+	b, err := BitXHubOf(s)
+	if err != nil || b.ConfigDir == "" {
+		return nil, fmt.Errorf("unsupport BitXHub verison %s, choose one of %v", s, BitXHubReleases())
+	}
+
+	return b, nil
+}
+
+// PierOf parses a pier version, which is not required to be shipped by goduck
+func PierOf(s string) (*Pier, error) {
+	v, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pier{Version: v}
+	for _, r := range pierReleases {
+		if MustParse(r.version) == v {
+			p.ConfigDir = r.configDir
+		}
+	}
+
+	return p, nil
+}
+
+// LookupPier returns the pier release shipped by goduck
+func LookupPier(s string) (*Pier, error) {
+	p, err := PierOf(s)
+	if err != nil || p.ConfigDir == "" {
+		return nil, fmt.Errorf("unsupport pier verison %s, choose one of %v", s, PierReleases())
+	}
+
+	return p, nil
+}
+
+// BitXHubReleases returns the BitXHub releases shipped by goduck
+func BitXHubReleases() []string {
+	var releases []string
+	for _, r := range bitxhubReleases {
+		releases = append(releases, r.version)
+	}
+
+	return releases
+}
+
+// PierReleases returns the pier releases shipped by goduck
+func PierReleases() []string {
+	var releases []string
+	for _, r := range pierReleases {
+		releases = append(releases, r.version)
+	}
+
+	return releases
+}
+
+// BitXHubConfigDirs returns the distinct bxh_config script directories
+func BitXHubConfigDirs() []string {
+	var dirs []string
+	for _, r := range bitxhubReleases {
+		dirs = appendOnce(dirs, r.configDir)
+	}
+
+	return dirs
+}
+
+// PierConfigDirs returns the distinct pier_config script directories
+func PierConfigDirs() []string {
+	var dirs []string
+	for _, r := range pierReleases {
+		dirs = appendOnce(dirs, r.configDir)
+	}
+
+	return dirs
+}
+
+// ConfigTemplates returns the directory of the node config templates
+func (b *BitXHub) ConfigTemplates() string {
+	switch {
+	case b.Before("v1.4.0"):
+		return "bitxhub/v1.1.0"
+	case b.Before("v1.6.0"):
+		return "bitxhub/v1.4.0"
+	default:
+		return "bitxhub/v1.6.0"
+	}
+}
+
+// HasAccountKey reports whether nodes use a secp256k1 account key apart from the p2p key
+func (b *BitXHub) HasAccountKey() bool {
+	return b.AtLeast("v1.4.0")
+}
+
+// NetworkFormat returns the layout of network.toml
+func (b *BitXHub) NetworkFormat() NetworkFormat {
+	switch {
+	case b.Before("v1.1.0-rc1"):
+		return NetworkAddrs
+	case b.Is("v1.1.0-rc1"):
+		return NetworkReadin
+	default:
+		return NetworkHosts
+	}
+}
+
+// GenesisFormat returns the layout of the genesis
+func (b *BitXHub) GenesisFormat() GenesisFormat {
+	if b.Before("v1.6.0") {
+		return GenesisAddresses
+	}
+
+	return GenesisAdmins
+}
+
+// HasJsonRpc reports whether nodes serve JSON-RPC
+func (b *BitXHub) HasJsonRpc() bool {
+	return b.AtLeast("v1.7.0")
+}
+
+// NodeManagement reports whether vp nodes join and leave a running cluster through the node manager
+func (b *BitXHub) NodeManagement() bool {
+	return b.AtLeast("v1.8.0")
+}
+
+// Modes returns the pier modes supported
+func (p *Pier) Modes() []string {
+	if p.Before("v1.4.0") {
+		return []string{types.PierModeDirect, types.PierModeRelay}
+	}
+
+	return []string{types.PierModeDirect, types.PierModeRelay, types.PierModeUnion}
+}
+
+// SupportsMode reports whether the pier mode is supported
+func (p *Pier) SupportsMode(mode string) bool {
+	for _, m := range p.Modes() {
+		if m == mode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasNodeKey reports whether pier has a p2p node key apart from its account key
+func (p *Pier) HasNodeKey() bool {
+	return p.AtLeast("v1.4.0")
+}
+
+// HasAdminKey reports whether pier has an admin.json for its administrators
+func (p *Pier) HasAdminKey() bool {
+	return p.AtLeast("v1.8.0")
+}
+
+// SharedObjectPlugin reports whether appchain plugins are shared objects loaded by their own file names
+func (p *Pier) SharedObjectPlugin() bool {
+	return p.Before("v1.1.0-rc1")
+}
+
+// SeparateClient reports whether appchain plugins are released apart from the pier package
+func (p *Pier) SeparateClient() bool {
+	return p.AtLeast("v1.7.0")
+}
+
+// ClientFile returns the file name of the appchain plugin as released
+func (p *Pier) ClientFile(chain string) string {
+	switch chain {
+	case types.ChainTypeFabric:
+		if p.SharedObjectPlugin() {
+			return types.FabricClientSo
+		}
+		return types.FabricClient
+	case types.ChainTypeEther:
+		if p.SharedObjectPlugin() {
+			return types.EthClientSo
+		}
+		return types.EthClient
+	}
+
+	return ""
+}
+
+// PluginFile returns the file name of the appchain plugin under the plugins directory of pier
+func (p *Pier) PluginFile(chain string) string {
+	if p.SharedObjectPlugin() {
+		return p.ClientFile(chain)
+	}
+
+	return "appchain_plugin"
+}
+
+// RegisterWithConsensus reports whether the appchain registration takes the consensus type of the appchain
+func (p *Pier) RegisterWithConsensus() bool {
+	return p.AtLeast("v1.7.0")
+}
+
+// RegisterByProposal reports whether the appchain registration is a proposal voted by BitXHub administrators
+func (p *Pier) RegisterByProposal() bool {
+	return p.AtLeast("v1.6.0")
+}
+
+// RegisterByMethod reports whether appchains are registered as DID methods of their admins
+func (p *Pier) RegisterByMethod() bool {
+	return p.AtLeast("v1.8.0")
+}
+
+// RuleBinding reports whether appchains have several rules, which are bound and unbound by proposals
+func (p *Pier) RuleBinding() bool {
+	return p.AtLeast("v1.8.0")
+}
+
+// MasterRule reports whether appchains validate with a master rule chosen among their rules
+func (p *Pier) MasterRule() bool {
+	return p.AtLeast("v1.9.0")
+}
+
+// InterchainServices reports whether appchains expose interchain services registered to the service manager
+func (p *Pier) InterchainServices() bool {
+	return p.AtLeast("v1.8.0")
+}
+
+func appendOnce(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+
+	return append(list, s)
+}
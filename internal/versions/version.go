@@ -0,0 +1,168 @@
+package versions
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version like v1.6.1 or v1.0.0-rc1
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	// Pre is the pre-release part without the leading "-", e.g. rc1
+	Pre string
+}
+
+// Parse parses a semantic version, the leading "v" is optional
+func Parse(s string) (Version, error) {
+	v := Version{}
+	core := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.Index(core, "-"); i >= 0 {
+		core, v.Pre = core[:i], core[i+1:]
+		if v.Pre == "" {
+			return Version{}, fmt.Errorf("invalid version %q: empty pre-release", s)
+		}
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version %q: expect major.minor.patch", s)
+	}
+	for i, dst := range []*int{&v.Major, &v.Minor, &v.Patch} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q: bad number %q", s, parts[i])
+		}
+		*dst = n
+	}
+
+	return v, nil
+}
+
+// MustParse is like Parse but panics if s is invalid
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return v
+}
+
+// String returns the version with the leading "v"
+func (v Version) String() string {
+	s := fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than o,
+// a pre-release is less than its release
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+
+	return comparePre(strings.Split(v.Pre, "."), strings.Split(o.Pre, "."))
+}
+
+// comparePre compares the dot-separated identifiers of pre-releases as semver does: numeric identifiers
+// by their numbers and before alphanumeric ones, and a shorter list first if it is a prefix of the other.
+// The digits in alphanumeric identifiers are compared as numbers too, as BitXHub tags rc10 after rc9.
+func comparePre(a, b []string) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		an, aErr := strconv.ParseUint(a[i], 10, 64)
+		bn, bErr := strconv.ParseUint(b[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareUint(an, bn); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := compareAlphanumeric(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
+
+// compareAlphanumeric compares runs of digits by their numbers and other runs byte-wise
+func compareAlphanumeric(a, b string) int {
+	for a != "" && b != "" {
+		ar, br := leadingRun(a), leadingRun(b)
+		an, aErr := strconv.ParseUint(ar, 10, 64)
+		bn, bErr := strconv.ParseUint(br, 10, 64)
+		c := strings.Compare(ar, br)
+		if aErr == nil && bErr == nil {
+			c = compareUint(an, bn)
+		}
+		if c != 0 {
+			return c
+		}
+		a, b = a[len(ar):], b[len(br):]
+	}
+
+	return compareUint(uint64(len(a)), uint64(len(b)))
+}
+
+// leadingRun returns the leading digits of s, or the leading characters which are not digits
+func leadingRun(s string) string {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	i := 1
+	for i < len(s) && isDigit(s[i]) == isDigit(s[0]) {
+		i++
+	}
+
+	return s[:i]
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Before reports whether v is less than s
+func (v Version) Before(s string) bool {
+	return v.Compare(MustParse(s)) < 0
+}
+
+// AtLeast reports whether v is greater than or equal to s
+func (v Version) AtLeast(s string) bool {
+	return v.Compare(MustParse(s)) >= 0
+}
+
+// Is reports whether v equals s
+func (v Version) Is(s string) bool {
+	return v.Compare(MustParse(s)) == 0
+}
@@ -0,0 +1,72 @@
+package versions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.6.1", "v1.6.1", 0},
+		{"1.6.1", "v1.6.1", 0},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v1.9.0", "v1.10.0", -1},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v1.0.10", "v1.0.9", 1},
+		{"v1.1.0-rc1", "v1.1.0", -1},
+		{"v1.1.0", "v1.1.0-rc1", 1},
+		{"v1.1.0-rc10", "v1.1.0-rc9", 1},
+		{"v1.1.0-rc9", "v1.1.0-rc10", -1},
+		{"v1.1.0-rc.10", "v1.1.0-rc.9", 1},
+		{"v1.1.0-rc.1", "v1.1.0-rc.1.1", -1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v1.0.0-alpha.1", "v1.0.0-alpha.beta", -1},
+		{"v1.0.0-alpha.beta", "v1.0.0-beta", -1},
+		{"v1.0.0-beta.2", "v1.0.0-beta.11", -1},
+		{"v1.0.0-beta.11", "v1.0.0-rc.1", -1},
+		{"v1.0.0-1", "v1.0.0-alpha", -1},
+		{"v1.1.0-rc1", "v1.0.9", 1},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, MustParse(tt.a).Compare(MustParse(tt.b)), "compare %s with %s", tt.a, tt.b)
+	}
+}
+
+func TestBeforeAndAtLeast(t *testing.T) {
+	tests := []struct {
+		v, s    string
+		before  bool
+		atLeast bool
+	}{
+		{"v1.10.0", "v1.9.0", false, true},
+		{"v1.9.0", "v1.10.0", true, false},
+		{"v1.6.0", "v1.6.0", false, true},
+		{"v1.6.0-rc1", "v1.6.0", true, false},
+		{"v1.1.0-rc10", "v1.1.0-rc2", false, true},
+		{"v1.1.0-rc1", "v1.1.0-rc1", false, true},
+		{"v1.8.0", "v1.1.0-rc1", false, true},
+	}
+
+	for _, tt := range tests {
+		v := MustParse(tt.v)
+		require.Equal(t, tt.before, v.Before(tt.s), "%s before %s", tt.v, tt.s)
+		require.Equal(t, tt.atLeast, v.AtLeast(tt.s), "%s at least %s", tt.v, tt.s)
+	}
+}
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.1.0-rc1")
+	require.Nil(t, err)
+	require.Equal(t, Version{Major: 1, Minor: 1, Patch: 0, Pre: "rc1"}, v)
+	require.Equal(t, "v1.1.0-rc1", v.String())
+
+	for _, s := range []string{"", "v1.6", "v1.6.x", "v1.6.0-", "v1.-1.0"} {
+		_, err := Parse(s)
+		require.NotNil(t, err, s)
+	}
+}
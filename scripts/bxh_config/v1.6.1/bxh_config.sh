@@ -84,6 +84,23 @@ function readConfig() {
     host_lables+=($a)
   done
 
+  # nodes without their own section take the ports next to the previous node and its host
+  for ((i = ${#GRPCPS[@]}; i < $NUM; i++)); do
+    GRPCPS+=($(expr ${GRPCPS[$i - 1]} + 1))
+  done
+  for ((i = ${#GATEWAYPS[@]}; i < $NUM; i++)); do
+    GATEWAYPS+=($(expr ${GATEWAYPS[$i - 1]} + 1))
+  done
+  for ((i = ${#PPROFPS[@]}; i < $NUM; i++)); do
+    PPROFPS+=($(expr ${PPROFPS[$i - 1]} + 1))
+  done
+  for ((i = ${#MONITORPS[@]}; i < $NUM; i++)); do
+    MONITORPS+=($(expr ${MONITORPS[$i - 1]} + 1))
+  done
+  for ((i = ${#host_lables[@]}; i < $NUM; i++)); do
+    host_lables+=(${host_lables[$i - 1]})
+  done
+
   NUM_1=0
   if [ $NUM != 1 ]; then
     NUM_1=$(expr $NUM - 1)
@@ -249,7 +266,8 @@ function rewriteNodeConfig() {
       a_line=$(sed -n "/account = \".*\"/=" ${TARGET}/$2/network.toml | head -n $i | tail -n 1)
       x_replace "$a_line s/account = \".*\"/account = \"$account\"/" ${TARGET}/$2/network.toml
       host_line=$(expr $a_line + 1)
-      x_replace "$host_line s/hosts = .*/hosts = [\"\/\ip4\/$ip\/tcp\/400$i\/p2p\/\"]/" ${TARGET}/$2/network.toml
+      p2p_port=$(expr 4000 + $i)
+      x_replace "$host_line s/hosts = .*/hosts = [\"\/\ip4\/$ip\/tcp\/$p2p_port\/p2p\/\"]/" ${TARGET}/$2/network.toml
       ii=$(expr $i + 1)
       id_line=$(expr $a_line + 2)
       x_replace "$id_line s/id = .*/id = $i/" ${TARGET}/$2/network.toml